@@ -17,7 +17,7 @@ const docTemplate = `{
     "paths": {
         "/": {
             "get": {
-                "description": "Returns a welcome message for Compliance as a Service",
+                "description": "Returns a welcome message for InSpec as a Service",
                 "produces": [
                     "application/json"
                 ],
@@ -38,7 +38,7 @@ const docTemplate = `{
         },
         "/add-profile": {
             "post": {
-                "description": "Adds a new InSpec profile by fetching details from a provided GitHub repository URL.",
+                "description": "Adds a new InSpec profile by fetching details from a provided GitHub repository URL. The repository must be on one of the configured GitHub or GitHub Enterprise Server hosts.",
                 "consumes": [
                     "application/json"
                 ],
@@ -69,7 +69,7 @@ const docTemplate = `{
                         }
                     },
                     "400": {
-                        "description": "Invalid request payload or missing inspec.yml",
+                        "description": "Invalid request payload, unknown GitHub host or missing inspec.yml",
                         "schema": {
                             "type": "object",
                             "additionalProperties": true
@@ -85,9 +85,225 @@ const docTemplate = `{
                 }
             }
         },
+        "/admin/profiles/{id}/review": {
+            "put": {
+                "security": [
+                    {
+                        "AdminToken": []
+                    }
+                ],
+                "description": "Sets the review status of a catalog profile to pending, approved or blocked, and records the review in its history. Reviews of profiles from git sources apply to a commit, ` + "`" + `ref` + "`" + ` or else the latest commit seen by the catalog sync, resolved from the default branch when approving a profile the sync hasn't checked yet; executions of an approved profile run that commit.",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "admin"
+                ],
+                "summary": "Review a profile",
+                "parameters": [
+                    {
+                        "type": "integer",
+                        "description": "Profile ID",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "description": "Review",
+                        "name": "request",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/api.reviewProfileRequest"
+                        }
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/models.Profile"
+                        }
+                    },
+                    "400": {
+                        "description": "Invalid request",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": true
+                        }
+                    },
+                    "401": {
+                        "description": "Invalid admin token",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": true
+                        }
+                    },
+                    "403": {
+                        "description": "Admin API disabled",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": true
+                        }
+                    },
+                    "404": {
+                        "description": "Profile not found",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": true
+                        }
+                    },
+                    "500": {
+                        "description": "Failed to review profile",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": true
+                        }
+                    }
+                }
+            }
+        },
+        "/admin/profiles/{id}/reviews": {
+            "get": {
+                "security": [
+                    {
+                        "AdminToken": []
+                    }
+                ],
+                "description": "Returns the review history of a catalog profile, latest first.",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "admin"
+                ],
+                "summary": "List profile reviews",
+                "parameters": [
+                    {
+                        "type": "integer",
+                        "description": "Profile ID",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "type": "array",
+                            "items": {
+                                "$ref": "#/definitions/models.ProfileReview"
+                            }
+                        }
+                    },
+                    "400": {
+                        "description": "Invalid profile ID",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": true
+                        }
+                    },
+                    "401": {
+                        "description": "Invalid admin token",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": true
+                        }
+                    },
+                    "403": {
+                        "description": "Admin API disabled",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": true
+                        }
+                    },
+                    "404": {
+                        "description": "Profile not found",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": true
+                        }
+                    },
+                    "500": {
+                        "description": "Failed to fetch reviews",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": true
+                        }
+                    }
+                }
+            }
+        },
+        "/controls": {
+            "get": {
+                "description": "Searches the controls of all catalog profiles. Controls are indexed from each profile's latest commit during catalog sync. Search results are ordered by relevance, other listings by impact, highest first.",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "controls"
+                ],
+                "summary": "Search controls",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Full-text search over control ID, title and description",
+                        "name": "q",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Tag name, or name:value to match a tag's value",
+                        "name": "tag",
+                        "in": "query"
+                    },
+                    {
+                        "type": "number",
+                        "description": "Minimum impact, between 0.0 and 1.0",
+                        "name": "impact_min",
+                        "in": "query"
+                    },
+                    {
+                        "type": "integer",
+                        "description": "Maximum number of controls to return (default 100, max 1000)",
+                        "name": "limit",
+                        "in": "query"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "type": "array",
+                            "items": {
+                                "$ref": "#/definitions/models.Control"
+                            }
+                        }
+                    },
+                    "400": {
+                        "description": "Invalid query parameter",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": true
+                        }
+                    },
+                    "500": {
+                        "description": "Failed to fetch controls",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": true
+                        }
+                    }
+                }
+            }
+        },
         "/execute-profile": {
             "post": {
-                "description": "Executes an InSpec profile on a remote host using SSH authentication.",
+                "description": "Queues an InSpec profile execution on a remote host using SSH authentication and returns the job ID. Unless the server allows any profile (REQUIRE_APPROVED_PROFILES=false), the profile must be an approved catalog profile of a git source, and it runs at its reviewed commit. The scan is stopped and recorded as timed out if it runs longer than ` + "`" + `timeout_seconds` + "`" + ` (or the server default). Set ` + "`" + `ref` + "`" + ` to a branch, tag or commit SHA to run the profile's git repository at that revision; the resolved commit and the profile's inspec.yml version are recorded on the job and its run.",
                 "consumes": [
                     "application/json"
                 ],
@@ -95,7 +311,7 @@ const docTemplate = `{
                     "application/json"
                 ],
                 "tags": [
-                    "profiles"
+                    "jobs"
                 ],
                 "summary": "Execute InSpec profile",
                 "parameters": [
@@ -104,12 +320,14 @@ const docTemplate = `{
                         "name": "request",
                         "in": "body",
                         "required": true,
-                        "schema": {}
+                        "schema": {
+                            "$ref": "#/definitions/api.executeProfileRequest"
+                        }
                     }
                 ],
                 "responses": {
-                    "200": {
-                        "description": "Execution results",
+                    "202": {
+                        "description": "Job queued",
                         "schema": {
                             "type": "object",
                             "additionalProperties": true
@@ -122,8 +340,15 @@ const docTemplate = `{
                             "additionalProperties": true
                         }
                     },
+                    "403": {
+                        "description": "Profile not approved",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": true
+                        }
+                    },
                     "500": {
-                        "description": "Execution failed",
+                        "description": "Failed to queue execution",
                         "schema": {
                             "type": "object",
                             "additionalProperties": true
@@ -134,7 +359,7 @@ const docTemplate = `{
         },
         "/fetch-profiles": {
             "get": {
-                "description": "Fetch profiles from the database or update from GitHub if not found",
+                "description": "Searches, filters and pages through the profile catalog. Forks and duplicates grouped under a canonical profile are left out unless collapse is false; the canonical profile counts them in duplicates. If the catalog is empty, a catalog sync is started in the background and its ID returned in the X-Sync-ID header. The total number of matching profiles is returned in the X-Total-Count header and the URL of the next page in the Link header (rel=\"next\").",
                 "produces": [
                     "application/json"
                 ],
@@ -142,6 +367,80 @@ const docTemplate = `{
                     "profiles"
                 ],
                 "summary": "Fetch profiles",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Full-text search over profile name and description",
+                        "name": "q",
+                        "in": "query"
+                    },
+                    {
+                        "type": "integer",
+                        "description": "Minimum number of GitHub stars",
+                        "name": "min_stars",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Supported platform, platform family or OS family from inspec.yml (e.g. linux, ubuntu, windows)",
+                        "name": "platform",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "User or organization owning the repository",
+                        "name": "owner",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Name of the catalog source profiles were found on",
+                        "name": "source",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Comma-separated profile statuses to include: active, archived or removed (default all)",
+                        "name": "status",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Comma-separated review statuses to include: pending, approved or blocked (default all)",
+                        "name": "review_status",
+                        "in": "query"
+                    },
+                    {
+                        "type": "boolean",
+                        "description": "Leave out forks and duplicates of other profiles (default true)",
+                        "name": "collapse",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Sort key: stars (default), name, updated or relevance (requires q)",
+                        "name": "sort",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Sort order: asc or desc (default depends on the sort key)",
+                        "name": "order",
+                        "in": "query"
+                    },
+                    {
+                        "type": "integer",
+                        "description": "Maximum number of profiles to return (default 100, max 500)",
+                        "name": "limit",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Cursor of the next page, taken from the Link header",
+                        "name": "cursor",
+                        "in": "query"
+                    }
+                ],
                 "responses": {
                     "200": {
                         "description": "OK",
@@ -150,6 +449,27 @@ const docTemplate = `{
                             "items": {
                                 "$ref": "#/definitions/models.Profile"
                             }
+                        },
+                        "headers": {
+                            "Link": {
+                                "type": "string",
+                                "description": "URL of the next page"
+                            },
+                            "X-Sync-ID": {
+                                "type": "integer",
+                                "description": "ID of the catalog sync started because the catalog is empty"
+                            },
+                            "X-Total-Count": {
+                                "type": "integer",
+                                "description": "Number of profiles matching the filters"
+                            }
+                        }
+                    },
+                    "400": {
+                        "description": "Invalid query parameter",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": true
                         }
                     },
                     "500": {
@@ -162,52 +482,1504 @@ const docTemplate = `{
                 }
             }
         },
-        "/update-profiles": {
-            "post": {
-                "description": "Initiates the process of updating profiles from GitHub and responds with a status message.",
+        "/hosts/{hostname}/latest": {
+            "get": {
+                "description": "Returns the most recent scan run against a host, with its control results. Use ` + "`" + `at` + "`" + ` to see the host's compliance state as of a given time.",
                 "produces": [
                     "application/json"
                 ],
                 "tags": [
-                    "profiles"
+                    "runs"
+                ],
+                "summary": "Get latest run for a host",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Hostname",
+                        "name": "hostname",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "type": "string",
+                        "description": "Only consider runs of this profile",
+                        "name": "profile",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Latest run created at or before this time (default now)",
+                        "name": "at",
+                        "in": "query"
+                    }
                 ],
-                "summary": "Update profiles",
                 "responses": {
                     "200": {
                         "description": "OK",
-                        "schema": {}
+                        "schema": {
+                            "$ref": "#/definitions/models.Run"
+                        }
+                    },
+                    "400": {
+                        "description": "Invalid query parameter",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": true
+                        }
+                    },
+                    "404": {
+                        "description": "No run found for host",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": true
+                        }
                     },
                     "500": {
-                        "description": "Internal Server Error",
-                        "schema": {}
+                        "description": "Failed to fetch run",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": true
+                        }
                     }
                 }
             }
-        }
-    },
-    "definitions": {
-        "models.Profile": {
-            "type": "object",
-            "properties": {
-                "description": {
-                    "type": "string"
-                },
-                "id": {
-                    "type": "integer"
-                },
-                "last_updated": {
-                    "type": "string"
-                },
-                "name": {
-                    "type": "string"
-                },
-                "stars": {
-                    "type": "integer"
-                },
-                "url": {
-                    "type": "string"
-                }
-            }
+        },
+        "/jobs/queue": {
+            "get": {
+                "description": "Returns the number of queued and running executions and the concurrency limit.",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "jobs"
+                ],
+                "summary": "Get execution queue status",
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/scheduler.Stats"
+                        }
+                    }
+                }
+            }
+        },
+        "/jobs/{id}": {
+            "get": {
+                "description": "Returns the status, timestamps, exit code, raw output and structured results of a profile execution job. Queued jobs also report their position in the queue.",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "jobs"
+                ],
+                "summary": "Get job status",
+                "parameters": [
+                    {
+                        "type": "integer",
+                        "description": "Job ID",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/models.Job"
+                        }
+                    },
+                    "400": {
+                        "description": "Invalid job ID",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": true
+                        }
+                    },
+                    "404": {
+                        "description": "Job not found",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": true
+                        }
+                    },
+                    "500": {
+                        "description": "Failed to fetch job",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": true
+                        }
+                    }
+                }
+            },
+            "delete": {
+                "description": "Cancels a queued or running profile execution. A queued job is cancelled immediately; a running scan has its InSpec process group terminated and is recorded as cancelled once it has stopped.",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "jobs"
+                ],
+                "summary": "Cancel job",
+                "parameters": [
+                    {
+                        "type": "integer",
+                        "description": "Job ID",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "202": {
+                        "description": "Cancellation requested",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": true
+                        }
+                    },
+                    "400": {
+                        "description": "Invalid job ID",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": true
+                        }
+                    },
+                    "404": {
+                        "description": "Job not found",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": true
+                        }
+                    },
+                    "409": {
+                        "description": "Job already finished",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": true
+                        }
+                    },
+                    "500": {
+                        "description": "Failed to cancel job",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": true
+                        }
+                    }
+                }
+            }
+        },
+        "/jobs/{id}/stream": {
+            "get": {
+                "description": "Streams the output of a profile execution as Server-Sent Events. Each ` + "`" + `line` + "`" + ` event carries one line of InSpec output and uses the line number as its event ID, so clients reconnecting with ` + "`" + `Last-Event-ID` + "`" + ` (or ` + "`" + `?from=` + "`" + `) resume where they left off. Once the job has finished, a ` + "`" + `control` + "`" + ` event is sent for every control of the report, followed by a ` + "`" + `done` + "`" + ` event with the final job. The output of jobs running on another instance is sent once they have finished.",
+                "produces": [
+                    "text/event-stream"
+                ],
+                "tags": [
+                    "jobs"
+                ],
+                "summary": "Stream job output",
+                "parameters": [
+                    {
+                        "type": "integer",
+                        "description": "Job ID",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "type": "integer",
+                        "description": "Number of lines already received",
+                        "name": "from",
+                        "in": "query"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "Event stream",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "400": {
+                        "description": "Invalid job ID",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": true
+                        }
+                    },
+                    "404": {
+                        "description": "Job not found",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": true
+                        }
+                    },
+                    "500": {
+                        "description": "Failed to fetch job",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": true
+                        }
+                    }
+                }
+            }
+        },
+        "/profiles/{id}": {
+            "get": {
+                "description": "Returns a catalog profile with the metadata parsed from its inspec.yml: title, version, maintainer, license, summary, supported platforms, required InSpec version, inputs and dependencies.",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "profiles"
+                ],
+                "summary": "Get profile",
+                "parameters": [
+                    {
+                        "type": "integer",
+                        "description": "Profile ID",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/models.Profile"
+                        }
+                    },
+                    "400": {
+                        "description": "Invalid profile ID",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": true
+                        }
+                    },
+                    "404": {
+                        "description": "Profile not found",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": true
+                        }
+                    },
+                    "500": {
+                        "description": "Failed to fetch profile",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": true
+                        }
+                    }
+                }
+            }
+        },
+        "/profiles/{id}/controls": {
+            "get": {
+                "description": "Returns the indexed controls of a catalog profile. Accepts the same filters as /controls.",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "controls"
+                ],
+                "summary": "List profile controls",
+                "parameters": [
+                    {
+                        "type": "integer",
+                        "description": "Profile ID",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "type": "string",
+                        "description": "Full-text search over control ID, title and description",
+                        "name": "q",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Tag name, or name:value to match a tag's value",
+                        "name": "tag",
+                        "in": "query"
+                    },
+                    {
+                        "type": "number",
+                        "description": "Minimum impact, between 0.0 and 1.0",
+                        "name": "impact_min",
+                        "in": "query"
+                    },
+                    {
+                        "type": "integer",
+                        "description": "Maximum number of controls to return (default 100, max 1000)",
+                        "name": "limit",
+                        "in": "query"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "type": "array",
+                            "items": {
+                                "$ref": "#/definitions/models.Control"
+                            }
+                        }
+                    },
+                    "400": {
+                        "description": "Invalid profile ID or query parameter",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": true
+                        }
+                    },
+                    "404": {
+                        "description": "Profile not found",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": true
+                        }
+                    },
+                    "500": {
+                        "description": "Failed to fetch controls",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": true
+                        }
+                    }
+                }
+            }
+        },
+        "/profiles/{id}/duplicates": {
+            "get": {
+                "description": "Returns the forks and duplicates grouped under a catalog profile, most stars first. Forks are grouped with the profile of the repository they were forked from, and profiles with the same fingerprint (the hash of their inspec.yml and control IDs) with each other.",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "profiles"
+                ],
+                "summary": "List profile duplicates",
+                "parameters": [
+                    {
+                        "type": "integer",
+                        "description": "Profile ID",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "type": "array",
+                            "items": {
+                                "$ref": "#/definitions/models.Profile"
+                            }
+                        }
+                    },
+                    "400": {
+                        "description": "Invalid profile ID",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": true
+                        }
+                    },
+                    "404": {
+                        "description": "Profile not found",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": true
+                        }
+                    },
+                    "500": {
+                        "description": "Failed to fetch profiles",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": true
+                        }
+                    }
+                }
+            }
+        },
+        "/runs": {
+            "get": {
+                "description": "Returns stored scan runs, newest first, optionally filtered by host, profile and time range. Dates may be given as RFC 3339 timestamps or as YYYY-MM-DD; a date-only ` + "`" + `until` + "`" + ` includes the whole day.",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "runs"
+                ],
+                "summary": "List scan runs",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Hostname the run targeted",
+                        "name": "host",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Profile that was executed",
+                        "name": "profile",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Only runs created at or after this time (RFC 3339, or a date in UTC)",
+                        "name": "since",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Only runs created at or before this time (RFC 3339, or a date in UTC)",
+                        "name": "until",
+                        "in": "query"
+                    },
+                    {
+                        "type": "integer",
+                        "description": "Maximum number of runs to return (default 50, max 500)",
+                        "name": "limit",
+                        "in": "query"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "type": "array",
+                            "items": {
+                                "$ref": "#/definitions/models.Run"
+                            }
+                        }
+                    },
+                    "400": {
+                        "description": "Invalid query parameter",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": true
+                        }
+                    },
+                    "500": {
+                        "description": "Failed to fetch runs",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": true
+                        }
+                    }
+                }
+            }
+        },
+        "/runs/{id}": {
+            "get": {
+                "description": "Returns a stored scan run with all of its profiles and control results.",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "runs"
+                ],
+                "summary": "Get scan run",
+                "parameters": [
+                    {
+                        "type": "integer",
+                        "description": "Run ID",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/models.Run"
+                        }
+                    },
+                    "400": {
+                        "description": "Invalid run ID",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": true
+                        }
+                    },
+                    "404": {
+                        "description": "Run not found",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": true
+                        }
+                    },
+                    "500": {
+                        "description": "Failed to fetch run",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": true
+                        }
+                    }
+                }
+            }
+        },
+        "/runs/{id}/diff/{other}": {
+            "get": {
+                "description": "Compares the control results of two stored runs and reports the controls that started failing, started passing, became skipped, were added or were removed when going from the first run to the second.",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "runs"
+                ],
+                "summary": "Diff two scan runs",
+                "parameters": [
+                    {
+                        "type": "integer",
+                        "description": "Baseline run ID",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "type": "integer",
+                        "description": "Run ID to compare against the baseline",
+                        "name": "other",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/models.RunDiff"
+                        }
+                    },
+                    "400": {
+                        "description": "Invalid run ID",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": true
+                        }
+                    },
+                    "404": {
+                        "description": "Run not found",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": true
+                        }
+                    },
+                    "500": {
+                        "description": "Failed to fetch run",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": true
+                        }
+                    }
+                }
+            }
+        },
+        "/sync": {
+            "get": {
+                "description": "Returns the most recent catalog syncs, newest first.",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "sync"
+                ],
+                "summary": "List catalog syncs",
+                "parameters": [
+                    {
+                        "type": "integer",
+                        "description": "Maximum number of syncs to return (default 20, max 100)",
+                        "name": "limit",
+                        "in": "query"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "type": "array",
+                            "items": {
+                                "$ref": "#/definitions/models.SyncRun"
+                            }
+                        }
+                    },
+                    "400": {
+                        "description": "Invalid limit",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": true
+                        }
+                    },
+                    "500": {
+                        "description": "Failed to fetch syncs",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": true
+                        }
+                    }
+                }
+            },
+            "post": {
+                "description": "Starts syncing the profile catalog from the configured sources in the background. Only one sync runs at a time; if one is already running, status 409 is returned with its ID, which a sync just started by another instance may not have yet. Poll the status URL for progress.",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "sync"
+                ],
+                "summary": "Start a catalog sync",
+                "parameters": [
+                    {
+                        "type": "array",
+                        "items": {
+                            "type": "string"
+                        },
+                        "collectionFormat": "multi",
+                        "description": "Names of the sources to sync (default all)",
+                        "name": "source",
+                        "in": "query"
+                    }
+                ],
+                "responses": {
+                    "202": {
+                        "description": "Sync started, with sync_id, status and status_url",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": true
+                        }
+                    },
+                    "400": {
+                        "description": "Unknown source",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": true
+                        }
+                    },
+                    "409": {
+                        "description": "A sync is already running, with its sync_id and status_url if it has been recorded",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": true
+                        }
+                    },
+                    "500": {
+                        "description": "Failed to start sync",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": true
+                        }
+                    }
+                }
+            }
+        },
+        "/sync/{id}": {
+            "get": {
+                "description": "Returns the status, timestamps, counts and error of a catalog sync.",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "sync"
+                ],
+                "summary": "Get catalog sync status",
+                "parameters": [
+                    {
+                        "type": "integer",
+                        "description": "Sync ID",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/models.SyncRun"
+                        }
+                    },
+                    "400": {
+                        "description": "Invalid sync ID",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": true
+                        }
+                    },
+                    "404": {
+                        "description": "Sync not found",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": true
+                        }
+                    },
+                    "500": {
+                        "description": "Failed to fetch sync",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": true
+                        }
+                    }
+                }
+            }
+        },
+        "/update-profiles": {
+            "get": {
+                "description": "Starts updating the profiles from the configured sources in the background and responds with the ID and status URL of the sync. Deprecated in favour of POST /sync.",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "profiles"
+                ],
+                "summary": "Update profiles",
+                "responses": {
+                    "200": {
+                        "description": "A sync is already running, with its sync_id and status_url",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": true
+                        }
+                    },
+                    "202": {
+                        "description": "Sync started, with sync_id and status_url",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": true
+                        }
+                    },
+                    "500": {
+                        "description": "Failed to start sync",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": true
+                        }
+                    }
+                }
+            }
+        }
+    },
+    "definitions": {
+        "api.executeProfileRequest": {
+            "type": "object",
+            "required": [
+                "hostname",
+                "private_key",
+                "profile",
+                "username"
+            ],
+            "properties": {
+                "engine": {
+                    "description": "Engine selects one of the server's configured scan engines, such as\ninspec or cinc-auditor, instead of the default one.",
+                    "type": "string"
+                },
+                "hostname": {
+                    "type": "string"
+                },
+                "private_key": {
+                    "type": "string"
+                },
+                "profile": {
+                    "type": "string"
+                },
+                "ref": {
+                    "description": "Ref pins the profile's git repository to a branch, tag or commit SHA.",
+                    "type": "string"
+                },
+                "timeout_seconds": {
+                    "description": "TimeoutSeconds overrides the server's default scan timeout.",
+                    "type": "integer"
+                },
+                "username": {
+                    "type": "string"
+                }
+            }
+        },
+        "api.reviewProfileRequest": {
+            "type": "object",
+            "required": [
+                "reviewer",
+                "status"
+            ],
+            "properties": {
+                "notes": {
+                    "type": "string"
+                },
+                "ref": {
+                    "description": "Ref is the branch, tag or commit SHA of the profile's git repository\nthat was reviewed. It defaults to the latest commit seen by the\ncatalog sync, or to the default branch if there is none.",
+                    "type": "string"
+                },
+                "reviewer": {
+                    "type": "string"
+                },
+                "status": {
+                    "type": "string"
+                }
+            }
+        },
+        "models.Control": {
+            "type": "object",
+            "properties": {
+                "commit_sha": {
+                    "description": "CommitSHA is the commit of the profile the control was read from.",
+                    "type": "string"
+                },
+                "desc": {
+                    "type": "string"
+                },
+                "file": {
+                    "description": "File is the path of the file declaring the control, relative to the\nprofile's root.",
+                    "type": "string"
+                },
+                "id": {
+                    "type": "string"
+                },
+                "impact": {
+                    "description": "Impact defaults to 0.5, as in InSpec, for controls that don't set it.",
+                    "type": "number"
+                },
+                "profile_id": {
+                    "type": "integer"
+                },
+                "profile_name": {
+                    "type": "string"
+                },
+                "refs": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/models.ControlRef"
+                    }
+                },
+                "tags": {
+                    "type": "object",
+                    "additionalProperties": true
+                },
+                "title": {
+                    "type": "string"
+                }
+            }
+        },
+        "models.ControlChange": {
+            "type": "object",
+            "properties": {
+                "after": {
+                    "type": "string"
+                },
+                "before": {
+                    "type": "string"
+                },
+                "id": {
+                    "type": "string"
+                },
+                "impact": {
+                    "type": "number"
+                },
+                "profile": {
+                    "type": "string"
+                },
+                "title": {
+                    "type": "string"
+                }
+            }
+        },
+        "models.ControlRef": {
+            "type": "object",
+            "properties": {
+                "ref": {
+                    "type": "string"
+                },
+                "url": {
+                    "type": "string"
+                }
+            }
+        },
+        "models.ControlResult": {
+            "type": "object",
+            "properties": {
+                "desc": {
+                    "type": "string"
+                },
+                "id": {
+                    "type": "string"
+                },
+                "impact": {
+                    "type": "number"
+                },
+                "results": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/models.TestResult"
+                    }
+                },
+                "status": {
+                    "type": "string"
+                },
+                "tags": {
+                    "type": "object",
+                    "additionalProperties": true
+                },
+                "title": {
+                    "type": "string"
+                }
+            }
+        },
+        "models.Job": {
+            "type": "object",
+            "properties": {
+                "commit_sha": {
+                    "type": "string"
+                },
+                "created_at": {
+                    "type": "string"
+                },
+                "engine": {
+                    "description": "Engine is the scan engine (e.g. inspec or cinc-auditor) running the\njob and EngineVersion the version it reported.",
+                    "type": "string"
+                },
+                "engine_version": {
+                    "type": "string"
+                },
+                "error": {
+                    "type": "string"
+                },
+                "exit_code": {
+                    "type": "integer"
+                },
+                "finished_at": {
+                    "type": "string"
+                },
+                "hostname": {
+                    "type": "string"
+                },
+                "id": {
+                    "type": "integer"
+                },
+                "output": {
+                    "type": "string"
+                },
+                "profile": {
+                    "type": "string"
+                },
+                "profile_version": {
+                    "type": "string"
+                },
+                "queue_position": {
+                    "description": "QueuePosition is the 1-based position of a queued job in the\nexecution queue. It is not persisted.",
+                    "type": "integer"
+                },
+                "ref": {
+                    "description": "Ref is the branch, tag or commit of the profile that was requested.\nCommitSHA is the commit it resolved to and ProfileVersion the version\ndeclared in the profile's inspec.yml at that commit.",
+                    "type": "string"
+                },
+                "result": {
+                    "$ref": "#/definitions/models.Run"
+                },
+                "started_at": {
+                    "type": "string"
+                },
+                "status": {
+                    "type": "string"
+                },
+                "timeout_seconds": {
+                    "description": "TimeoutSeconds is how long the scan may run once started.",
+                    "type": "integer"
+                },
+                "username": {
+                    "type": "string"
+                }
+            }
+        },
+        "models.Platform": {
+            "type": "object",
+            "properties": {
+                "name": {
+                    "type": "string"
+                },
+                "release": {
+                    "type": "string"
+                },
+                "target_id": {
+                    "type": "string"
+                }
+            }
+        },
+        "models.Profile": {
+            "type": "object",
+            "properties": {
+                "canonical_id": {
+                    "description": "CanonicalID is the ID of the profile that this fork or duplicate is\ngrouped under, and Duplicates counts the profiles grouped under this\none.",
+                    "type": "integer"
+                },
+                "commit_sha": {
+                    "description": "CommitSHA is the latest commit of the profile's repository seen by\nthe catalog sync. Executions use the cached checkout of this commit.",
+                    "type": "string"
+                },
+                "description": {
+                    "type": "string"
+                },
+                "duplicates": {
+                    "type": "integer"
+                },
+                "fingerprint": {
+                    "description": "Fingerprint is the SHA-256 hash of the profile's inspec.yml and\ncontrol IDs, computed when its controls are indexed. Profiles with\nthe same fingerprint are duplicates of each other.",
+                    "type": "string"
+                },
+                "fork": {
+                    "description": "Fork is set for profiles whose repository is a fork, and ParentURL\nis the URL of the repository it was forked from, if known.",
+                    "type": "boolean"
+                },
+                "id": {
+                    "type": "integer"
+                },
+                "last_updated": {
+                    "type": "string"
+                },
+                "metadata": {
+                    "description": "Metadata is parsed from the profile's inspec.yml during sync.",
+                    "allOf": [
+                        {
+                            "$ref": "#/definitions/models.ProfileMetadata"
+                        }
+                    ]
+                },
+                "name": {
+                    "type": "string"
+                },
+                "owner": {
+                    "description": "Owner is the user, organization or group owning the profile's\nrepository, as named by its source.",
+                    "type": "string"
+                },
+                "parent_url": {
+                    "type": "string"
+                },
+                "repo_id": {
+                    "description": "RepoID is the ID of the profile's repository on its GitHub host,\nwhich stays the same when the repository is renamed or transferred.",
+                    "type": "integer"
+                },
+                "review_notes": {
+                    "type": "string"
+                },
+                "review_status": {
+                    "description": "ReviewStatus is pending, approved or blocked. Only approved profiles\nmay be executed if the server requires it. Reviewer, ReviewNotes,\nReviewedSHA and ReviewedAt describe the latest review; executions of\nan approved profile run the reviewed commit.",
+                    "type": "string"
+                },
+                "reviewed_at": {
+                    "type": "string"
+                },
+                "reviewed_sha": {
+                    "type": "string"
+                },
+                "reviewer": {
+                    "type": "string"
+                },
+                "source": {
+                    "description": "Source is the name of the catalog source the profile was found on.",
+                    "type": "string"
+                },
+                "stars": {
+                    "type": "integer"
+                },
+                "status": {
+                    "description": "Status is active, archived or removed. StatusReason explains why a\nprofile was removed.",
+                    "type": "string"
+                },
+                "status_reason": {
+                    "type": "string"
+                },
+                "url": {
+                    "type": "string"
+                }
+            }
+        },
+        "models.ProfileDependency": {
+            "type": "object",
+            "properties": {
+                "branch": {
+                    "type": "string"
+                },
+                "commit": {
+                    "type": "string"
+                },
+                "compliance": {
+                    "type": "string"
+                },
+                "git": {
+                    "type": "string"
+                },
+                "name": {
+                    "type": "string"
+                },
+                "path": {
+                    "type": "string"
+                },
+                "supermarket": {
+                    "type": "string"
+                },
+                "tag": {
+                    "type": "string"
+                },
+                "url": {
+                    "type": "string"
+                },
+                "version": {
+                    "type": "string"
+                }
+            }
+        },
+        "models.ProfileInput": {
+            "type": "object",
+            "properties": {
+                "description": {
+                    "type": "string"
+                },
+                "name": {
+                    "type": "string"
+                },
+                "required": {
+                    "type": "boolean"
+                },
+                "sensitive": {
+                    "type": "boolean"
+                },
+                "type": {
+                    "type": "string"
+                },
+                "value": {}
+            }
+        },
+        "models.ProfileMetadata": {
+            "type": "object",
+            "properties": {
+                "copyright": {
+                    "type": "string"
+                },
+                "dependencies": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/models.ProfileDependency"
+                    }
+                },
+                "inputs": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/models.ProfileInput"
+                    }
+                },
+                "inspec_version": {
+                    "type": "string"
+                },
+                "license": {
+                    "type": "string"
+                },
+                "maintainer": {
+                    "type": "string"
+                },
+                "name": {
+                    "type": "string"
+                },
+                "summary": {
+                    "type": "string"
+                },
+                "supports": {
+                    "type": "array",
+                    "items": {
+                        "type": "object",
+                        "additionalProperties": {
+                            "type": "string"
+                        }
+                    }
+                },
+                "title": {
+                    "type": "string"
+                },
+                "version": {
+                    "type": "string"
+                }
+            }
+        },
+        "models.ProfileResult": {
+            "type": "object",
+            "properties": {
+                "controls": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/models.ControlResult"
+                    }
+                },
+                "name": {
+                    "type": "string"
+                },
+                "sha256": {
+                    "type": "string"
+                },
+                "status": {
+                    "type": "string"
+                },
+                "status_message": {
+                    "type": "string"
+                },
+                "title": {
+                    "type": "string"
+                },
+                "version": {
+                    "type": "string"
+                }
+            }
+        },
+        "models.ProfileReview": {
+            "type": "object",
+            "properties": {
+                "commit_sha": {
+                    "description": "CommitSHA is the commit of the profile's repository that was\nreviewed, if the profile comes from a git source.",
+                    "type": "string"
+                },
+                "created_at": {
+                    "type": "string"
+                },
+                "id": {
+                    "type": "integer"
+                },
+                "notes": {
+                    "type": "string"
+                },
+                "profile_id": {
+                    "type": "integer"
+                },
+                "reviewer": {
+                    "type": "string"
+                },
+                "status": {
+                    "type": "string"
+                }
+            }
+        },
+        "models.Run": {
+            "type": "object",
+            "properties": {
+                "commit_sha": {
+                    "description": "CommitSHA and ProfileVersion record the exact revision of the profile\nthat was executed.",
+                    "type": "string"
+                },
+                "created_at": {
+                    "type": "string"
+                },
+                "engine": {
+                    "description": "Engine and EngineVersion identify the scan engine that produced the\nrun; Version below is the version written into the report itself.",
+                    "type": "string"
+                },
+                "engine_version": {
+                    "type": "string"
+                },
+                "hostname": {
+                    "type": "string"
+                },
+                "id": {
+                    "type": "integer"
+                },
+                "job_id": {
+                    "type": "integer"
+                },
+                "platform": {
+                    "$ref": "#/definitions/models.Platform"
+                },
+                "profile": {
+                    "type": "string"
+                },
+                "profile_version": {
+                    "type": "string"
+                },
+                "profiles": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/models.ProfileResult"
+                    }
+                },
+                "statistics": {
+                    "$ref": "#/definitions/models.Statistics"
+                },
+                "summary": {
+                    "$ref": "#/definitions/models.RunSummary"
+                },
+                "version": {
+                    "type": "string"
+                }
+            }
+        },
+        "models.RunDiff": {
+            "type": "object",
+            "properties": {
+                "added": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/models.ControlChange"
+                    }
+                },
+                "from": {
+                    "type": "integer"
+                },
+                "newly_failing": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/models.ControlChange"
+                    }
+                },
+                "newly_passing": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/models.ControlChange"
+                    }
+                },
+                "newly_skipped": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/models.ControlChange"
+                    }
+                },
+                "removed": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/models.ControlChange"
+                    }
+                },
+                "to": {
+                    "type": "integer"
+                },
+                "unchanged": {
+                    "type": "integer"
+                }
+            }
+        },
+        "models.RunSummary": {
+            "type": "object",
+            "properties": {
+                "failed": {
+                    "type": "integer"
+                },
+                "passed": {
+                    "type": "integer"
+                },
+                "skipped": {
+                    "type": "integer"
+                },
+                "total": {
+                    "type": "integer"
+                }
+            }
+        },
+        "models.Statistics": {
+            "type": "object",
+            "properties": {
+                "duration": {
+                    "type": "number"
+                }
+            }
+        },
+        "models.SyncRun": {
+            "type": "object",
+            "properties": {
+                "added": {
+                    "description": "Added, Updated and Removed count the changes to the catalog.",
+                    "type": "integer"
+                },
+                "discovered": {
+                    "type": "integer"
+                },
+                "duplicates": {
+                    "type": "integer"
+                },
+                "error": {
+                    "type": "string"
+                },
+                "excluded": {
+                    "type": "integer"
+                },
+                "finished_at": {
+                    "type": "string"
+                },
+                "id": {
+                    "type": "integer"
+                },
+                "pages": {
+                    "description": "Pages, Repositories and Duplicates count the search result pages\nfetched, the distinct repositories on them and the repositories seen\ntwice. Excluded counts the repositories skipped by an exclude pattern\nand Discovered the repositories that are profiles.",
+                    "type": "integer"
+                },
+                "removed": {
+                    "type": "integer"
+                },
+                "repositories": {
+                    "type": "integer"
+                },
+                "sources": {
+                    "description": "Sources names the catalog sources synced; all sources are synced if\nit is empty.",
+                    "type": "array",
+                    "items": {
+                        "type": "string"
+                    }
+                },
+                "started_at": {
+                    "type": "string"
+                },
+                "status": {
+                    "type": "string"
+                },
+                "trigger": {
+                    "type": "string"
+                },
+                "updated": {
+                    "type": "integer"
+                }
+            }
+        },
+        "models.TestResult": {
+            "type": "object",
+            "properties": {
+                "code_desc": {
+                    "type": "string"
+                },
+                "message": {
+                    "type": "string"
+                },
+                "run_time": {
+                    "type": "number"
+                },
+                "skip_message": {
+                    "type": "string"
+                },
+                "start_time": {
+                    "type": "string"
+                },
+                "status": {
+                    "type": "string"
+                }
+            }
+        },
+        "scheduler.Stats": {
+            "type": "object",
+            "properties": {
+                "max_concurrent": {
+                    "type": "integer"
+                },
+                "queued": {
+                    "type": "integer"
+                },
+                "running": {
+                    "type": "integer"
+                }
+            }
+        }
+    },
+    "securityDefinitions": {
+        "AdminToken": {
+            "description": "Admin API token set by ADMIN_TOKEN, as \"Bearer \u003ctoken\u003e\".",
+            "type": "apiKey",
+            "name": "Authorization",
+            "in": "header"
         }
     }
 }`
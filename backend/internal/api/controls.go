@@ -0,0 +1,118 @@
+package api
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"strconv"
+
+	"github.com/ahasunos/caas/backend/internal/db"
+	"github.com/gin-gonic/gin"
+)
+
+// defaultControlsLimit and maxControlsLimit bound the number of controls
+// listed at once.
+const (
+	defaultControlsLimit = 100
+	maxControlsLimit     = 1000
+)
+
+// listControlsHandler godoc
+// @Summary Search controls
+// @Description Searches the controls of all catalog profiles. Controls are indexed from each profile's latest commit during catalog sync. Search results are ordered by relevance, other listings by impact, highest first.
+// @Tags controls
+// @Produce json
+// @Param q query string false "Full-text search over control ID, title and description"
+// @Param tag query string false "Tag name, or name:value to match a tag's value"
+// @Param impact_min query number false "Minimum impact, between 0.0 and 1.0"
+// @Param limit query int false "Maximum number of controls to return (default 100, max 1000)"
+// @Success 200 {array} models.Control
+// @Failure 400 {object} map[string]interface{} "Invalid query parameter"
+// @Failure 500 {object} map[string]interface{} "Failed to fetch controls"
+// @Router /controls [get]
+func listControlsHandler(c *gin.Context) {
+	filter, ok := controlFilter(c)
+	if !ok {
+		return
+	}
+	respondWithControls(c, filter)
+}
+
+// listProfileControlsHandler godoc
+// @Summary List profile controls
+// @Description Returns the indexed controls of a catalog profile. Accepts the same filters as /controls.
+// @Tags controls
+// @Produce json
+// @Param id path int true "Profile ID"
+// @Param q query string false "Full-text search over control ID, title and description"
+// @Param tag query string false "Tag name, or name:value to match a tag's value"
+// @Param impact_min query number false "Minimum impact, between 0.0 and 1.0"
+// @Param limit query int false "Maximum number of controls to return (default 100, max 1000)"
+// @Success 200 {array} models.Control
+// @Failure 400 {object} map[string]interface{} "Invalid profile ID or query parameter"
+// @Failure 404 {object} map[string]interface{} "Profile not found"
+// @Failure 500 {object} map[string]interface{} "Failed to fetch controls"
+// @Router /profiles/{id}/controls [get]
+func listProfileControlsHandler(c *gin.Context) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid profile ID"})
+		return
+	}
+	if _, err := db.GetProfile(id); errors.Is(err, sql.ErrNoRows) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Profile not found"})
+		return
+	} else if err != nil {
+		log.Printf("Error fetching profile %d: %v", id, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch profile"})
+		return
+	}
+
+	filter, ok := controlFilter(c)
+	if !ok {
+		return
+	}
+	filter.ProfileID = id
+	respondWithControls(c, filter)
+}
+
+// controlFilter builds a control filter from the query parameters. It
+// responds with an error and returns false if a parameter is invalid.
+func controlFilter(c *gin.Context) (db.ControlFilter, bool) {
+	filter := db.ControlFilter{
+		Query: c.Query("q"),
+		Tag:   c.Query("tag"),
+		Limit: defaultControlsLimit,
+	}
+
+	if impact := c.Query("impact_min"); impact != "" {
+		f, err := strconv.ParseFloat(impact, 64)
+		if err != nil || f < 0 || f > 1 {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "impact_min must be between 0.0 and 1.0"})
+			return filter, false
+		}
+		filter.ImpactMin = &f
+	}
+	if limit := c.Query("limit"); limit != "" {
+		n, err := strconv.Atoi(limit)
+		if err != nil || n < 1 || n > maxControlsLimit {
+			c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("limit must be between 1 and %d", maxControlsLimit)})
+			return filter, false
+		}
+		filter.Limit = n
+	}
+	return filter, true
+}
+
+func respondWithControls(c *gin.Context, filter db.ControlFilter) {
+	controls, err := db.ListControls(filter)
+	if err != nil {
+		log.Println("Error listing controls:", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Could not fetch controls from database."})
+		return
+	}
+
+	c.JSON(http.StatusOK, controls)
+}
@@ -1,16 +1,19 @@
 package api
 
 import (
+	"database/sql"
 	"encoding/base64"
+	"errors"
 	"fmt"
 	"log"
 	"net/http"
-	"os"
-	"os/exec"
+	"strconv"
+	"strings"
 	"time"
 
 	"github.com/ahasunos/caas/backend/internal/db"
 	"github.com/ahasunos/caas/backend/internal/github"
+	"github.com/ahasunos/caas/backend/internal/models"
 	"github.com/gin-gonic/gin"
 )
 
@@ -27,75 +30,199 @@ func welcomeHandler(c *gin.Context) {
 	})
 }
 
+// defaultProfilesLimit and maxProfilesLimit bound the number of profiles
+// listed at once.
+const (
+	defaultProfilesLimit = 100
+	maxProfilesLimit     = 500
+)
+
 // fetchProfilesHandler handles the HTTP request to fetch profiles.
-// It first attempts to retrieve profiles from the database. If no profiles
-// are found, it updates the profiles from GitHub and retries fetching them
-// from the database. It returns the profiles in JSON format or an error
-// message if any operation fails.
+// It returns a page of the profiles in the database matching the query
+// parameters. If the catalog is empty, it starts a catalog sync in the
+// background and returns its ID in the X-Sync-ID header. The total number
+// of matching profiles is returned in the X-Total-Count header and the next
+// page is linked from the Link header.
 //
 // @Summary Fetch profiles
-// @Description Fetch profiles from the database or update from GitHub if not found
+// @Description Searches, filters and pages through the profile catalog. Forks and duplicates grouped under a canonical profile are left out unless collapse is false; the canonical profile counts them in duplicates. If the catalog is empty, a catalog sync is started in the background and its ID returned in the X-Sync-ID header. The total number of matching profiles is returned in the X-Total-Count header and the URL of the next page in the Link header (rel="next").
 // @Tags profiles
 // @Produce json
+// @Param q query string false "Full-text search over profile name and description"
+// @Param min_stars query int false "Minimum number of GitHub stars"
+// @Param platform query string false "Supported platform, platform family or OS family from inspec.yml (e.g. linux, ubuntu, windows)"
+// @Param owner query string false "User or organization owning the repository"
+// @Param source query string false "Name of the catalog source profiles were found on"
+// @Param status query string false "Comma-separated profile statuses to include: active, archived or removed (default all)"
+// @Param review_status query string false "Comma-separated review statuses to include: pending, approved or blocked (default all)"
+// @Param collapse query bool false "Leave out forks and duplicates of other profiles (default true)"
+// @Param sort query string false "Sort key: stars (default), name, updated or relevance (requires q)"
+// @Param order query string false "Sort order: asc or desc (default depends on the sort key)"
+// @Param limit query int false "Maximum number of profiles to return (default 100, max 500)"
+// @Param cursor query string false "Cursor of the next page, taken from the Link header"
 // @Success 200 {array} models.Profile
+// @Header 200 {integer} X-Total-Count "Number of profiles matching the filters"
+// @Header 200 {string} Link "URL of the next page"
+// @Header 200 {integer} X-Sync-ID "ID of the catalog sync started because the catalog is empty"
+// @Failure 400 {object} map[string]interface{} "Invalid query parameter"
 // @Failure 500 {object} map[string]interface{}
 // @Router /fetch-profiles [get]
 func fetchProfilesHandler(c *gin.Context) {
-	profiles, err := db.GetProfilesFromDatabase()
+	filter, ok := profileFilter(c)
+	if !ok {
+		return
+	}
+
+	page, err := db.ListProfiles(filter)
+	if errors.Is(err, db.ErrInvalidCursor) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid cursor"})
+		return
+	}
 	if err != nil {
+		log.Println("Error listing profiles:", err)
 		c.JSON(http.StatusInternalServerError, gin.H{
 			"error": "Could not fetch profiles from database.",
 		})
 		return
 	}
 
-	if len(profiles) == 0 {
-		// No profiles found, fetch from GitHub
-		if err := db.UpdateProfilesFromGitHub(); err != nil {
-			c.JSON(http.StatusInternalServerError, gin.H{
-				"error": "Failed to update profiles from GitHub.",
-			})
+	if page.Total == 0 && len(c.Request.URL.Query()) == 0 {
+		// No profiles found, sync the catalog in the background
+		run, _ := startSync(c, models.SyncTriggerManual)
+		if run == nil {
 			return
 		}
+		if run.ID != 0 {
+			c.Header("X-Sync-ID", strconv.Itoa(run.ID))
+		}
+	}
+
+	c.Header("X-Total-Count", strconv.Itoa(page.Total))
+	if page.NextCursor != "" {
+		next := *c.Request.URL
+		query := next.Query()
+		query.Set("cursor", page.NextCursor)
+		next.RawQuery = query.Encode()
+		c.Header("Link", fmt.Sprintf("<%s>; rel=\"next\"", next.RequestURI()))
+	}
+	c.JSON(http.StatusOK, page.Profiles)
+}
 
-		profiles, _ = db.GetProfilesFromDatabase() // Retry after updating
+// profileFilter builds a profile filter from the query parameters. It
+// responds with an error and returns false if a parameter is invalid.
+func profileFilter(c *gin.Context) (db.ProfileFilter, bool) {
+	filter := db.ProfileFilter{
+		Query:    c.Query("q"),
+		Platform: c.Query("platform"),
+		Owner:    c.Query("owner"),
+		Source:   c.Query("source"),
+		Collapse: true,
+		Sort:     c.Query("sort"),
+		Cursor:   c.Query("cursor"),
+		Limit:    defaultProfilesLimit,
 	}
 
-	c.JSON(http.StatusOK, profiles)
+	if minStars := c.Query("min_stars"); minStars != "" {
+		n, err := strconv.Atoi(minStars)
+		if err != nil || n < 0 {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "min_stars must be a non-negative integer"})
+			return filter, false
+		}
+		filter.MinStars = n
+	}
+	if status := c.Query("review_status"); status != "" {
+		for _, st := range strings.Split(status, ",") {
+			switch st {
+			case models.ReviewPending, models.ReviewApproved, models.ReviewBlocked:
+				filter.ReviewStatuses = append(filter.ReviewStatuses, st)
+			default:
+				c.JSON(http.StatusBadRequest, gin.H{"error": "review_status must be a comma-separated list of pending, approved and blocked"})
+				return filter, false
+			}
+		}
+	}
+	if collapse := c.Query("collapse"); collapse != "" {
+		b, err := strconv.ParseBool(collapse)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "collapse must be true or false"})
+			return filter, false
+		}
+		filter.Collapse = b
+	}
+	if status := c.Query("status"); status != "" {
+		for _, st := range strings.Split(status, ",") {
+			switch st {
+			case models.ProfileActive, models.ProfileArchived, models.ProfileRemoved:
+				filter.Statuses = append(filter.Statuses, st)
+			default:
+				c.JSON(http.StatusBadRequest, gin.H{"error": "status must be a comma-separated list of active, archived and removed"})
+				return filter, false
+			}
+		}
+	}
+	if filter.Sort != "" && (!db.IsProfileSort(filter.Sort) || filter.Sort == "relevance" && filter.Query == "") {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "sort must be one of stars, name, updated or relevance (with q)"})
+		return filter, false
+	}
+	switch c.Query("order") {
+	case "":
+	case "asc":
+		ascending := true
+		filter.Ascending = &ascending
+	case "desc":
+		ascending := false
+		filter.Ascending = &ascending
+	default:
+		c.JSON(http.StatusBadRequest, gin.H{"error": "order must be asc or desc"})
+		return filter, false
+	}
+	if limit := c.Query("limit"); limit != "" {
+		n, err := strconv.Atoi(limit)
+		if err != nil || n < 1 || n > maxProfilesLimit {
+			c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("limit must be between 1 and %d", maxProfilesLimit)})
+			return filter, false
+		}
+		filter.Limit = n
+	}
+	return filter, true
 }
 
 // updateProfilesHandler handles the HTTP request to update profiles.
-// It responds with a JSON message indicating that the profile update is in progress.
-// The actual profile update process is initiated by calling db.UpdateProfilesFromGitHub().
-// If an error occurs during the update, it is logged.
+// It starts a catalog sync in the background, like POST /sync, and responds
+// with the ID of the sync, or of the sync that is already running.
 //
 // @Summary Update profiles
-// @Description Initiates the process of updating profiles from GitHub and responds with a status message.
+// @Description Starts updating the profiles from the configured sources in the background and responds with the ID and status URL of the sync. Deprecated in favour of POST /sync.
 // @Tags profiles
 // @Produce json
-// @Success 200 {object} any
-// @Failure 500 {object} any
-// @Router /update-profiles [post]
+// @Success 202 {object} map[string]interface{} "Sync started, with sync_id and status_url"
+// @Success 200 {object} map[string]interface{} "A sync is already running, with its sync_id and status_url"
+// @Failure 500 {object} map[string]interface{} "Failed to start sync"
+// @Router /update-profiles [get]
 func updateProfilesHandler(c *gin.Context) {
-	c.JSON(http.StatusOK, gin.H{
-		"message": "Profile update in progress, please check back later.",
-	})
+	run, started := startSync(c, models.SyncTriggerManual)
+	if run == nil {
+		return
+	}
 
-	// Fetch and update profiles from GitHub
-	if err := db.UpdateProfilesFromGitHub(); err != nil {
-		log.Println("Error updating profiles:", err)
+	status := http.StatusAccepted
+	message := "Profile update in progress, please check back later."
+	if !started {
+		status = http.StatusOK
+		message = "A profile update is already in progress, please check back later."
 	}
+	c.JSON(status, syncRef(run, gin.H{"message": message}))
 }
 
 // addProfileHandler handles the addition of a new InSpec profile from a GitHub repository URL.
 // @Summary Add a new InSpec profile
-// @Description Adds a new InSpec profile by fetching details from a provided GitHub repository URL.
+// @Description Adds a new InSpec profile by fetching details from a provided GitHub repository URL. The repository must be on one of the configured GitHub or GitHub Enterprise Server hosts.
 // @Tags profiles
 // @Accept json
 // @Produce json
 // @Param url body string true "GitHub repository URL"
 // @Success 200 {object} map[string]interface{} "Profile added successfully"
-// @Failure 400 {object} map[string]interface{} "Invalid request payload or missing inspec.yml"
+// @Failure 400 {object} map[string]interface{} "Invalid request payload, unknown GitHub host or missing inspec.yml"
 // @Failure 500 {object} map[string]interface{} "Failed to fetch profile details from GitHub or insert into the database"
 // @Router /add-profile [post]
 func addProfileHandler(c *gin.Context) {
@@ -111,56 +238,155 @@ func addProfileHandler(c *gin.Context) {
 		return
 	}
 
-	// Check if the repository at the URL contains an inspec.yml file
-	if github.HasInSpecYML(request.URL) {
-		// Fetch details of the repository
-		profile, err := github.FetchProfileDetailsFromGitHub(request.URL)
-		if err != nil {
-			c.JSON(http.StatusInternalServerError, gin.H{
-				"error": "Failed to fetch profile details from GitHub.",
-			})
-			return
-		}
-
-		// Insert the profile into the database
-		if err := db.InsertProfileIntoDatabase(profile); err != nil {
-			c.JSON(http.StatusInternalServerError, gin.H{
-				"error": "Failed to insert profile into the database.",
-			})
-			return
-		}
-
-		// Return success message
-		c.JSON(http.StatusOK, gin.H{
-			"message": "Profile added successfully.",
-			"profile": profile,
+	gh, ok := profileCatalog.GitHub(request.URL)
+	if !ok {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": "The provided URL is not a repository on a configured GitHub host.",
 		})
-	} else {
+		return
+	}
+
+	// Check if the repository at the URL contains an inspec.yml file
+	meta, err := gh.FetchMetadata(c.Request.Context(), request.URL)
+	if errors.Is(err, github.ErrNoInSpecYML) {
 		c.JSON(http.StatusBadRequest, gin.H{
 			"error": "The provided repository is not a valid InSpec profile (missing inspec.yml).",
 		})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error": "Failed to fetch inspec.yml from GitHub.",
+		})
+		return
+	}
+
+	// Fetch details of the repository
+	profile, err := gh.FetchProfileDetailsFromGitHub(c.Request.Context(), request.URL)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error": "Failed to fetch profile details from GitHub.",
+		})
+		return
+	}
+	profile.Metadata = meta
+	profile.Source = gh.Name()
+
+	// Insert the profile into the database
+	if err := db.InsertProfileIntoDatabase(profile); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error": "Failed to insert profile into the database.",
+		})
+		return
+	}
+
+	// Return success message
+	c.JSON(http.StatusOK, gin.H{
+		"message": "Profile added successfully.",
+		"profile": profile,
+	})
+}
+
+// getProfileHandler godoc
+// @Summary Get profile
+// @Description Returns a catalog profile with the metadata parsed from its inspec.yml: title, version, maintainer, license, summary, supported platforms, required InSpec version, inputs and dependencies.
+// @Tags profiles
+// @Produce json
+// @Param id path int true "Profile ID"
+// @Success 200 {object} models.Profile
+// @Failure 400 {object} map[string]interface{} "Invalid profile ID"
+// @Failure 404 {object} map[string]interface{} "Profile not found"
+// @Failure 500 {object} map[string]interface{} "Failed to fetch profile"
+// @Router /profiles/{id} [get]
+func getProfileHandler(c *gin.Context) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid profile ID"})
+		return
+	}
+
+	profile, err := db.GetProfile(id)
+	if errors.Is(err, sql.ErrNoRows) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Profile not found"})
+		return
+	}
+	if err != nil {
+		log.Printf("Error fetching profile %d: %v", id, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch profile"})
+		return
 	}
+
+	c.JSON(http.StatusOK, profile)
 }
 
-// @Summary Execute InSpec profile
-// @Description Executes an InSpec profile on a remote host using SSH authentication.
+// listProfileDuplicatesHandler godoc
+// @Summary List profile duplicates
+// @Description Returns the forks and duplicates grouped under a catalog profile, most stars first. Forks are grouped with the profile of the repository they were forked from, and profiles with the same fingerprint (the hash of their inspec.yml and control IDs) with each other.
 // @Tags profiles
+// @Produce json
+// @Param id path int true "Profile ID"
+// @Success 200 {array} models.Profile
+// @Failure 400 {object} map[string]interface{} "Invalid profile ID"
+// @Failure 404 {object} map[string]interface{} "Profile not found"
+// @Failure 500 {object} map[string]interface{} "Failed to fetch profiles"
+// @Router /profiles/{id}/duplicates [get]
+func listProfileDuplicatesHandler(c *gin.Context) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid profile ID"})
+		return
+	}
+	if _, err := db.GetProfile(id); errors.Is(err, sql.ErrNoRows) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Profile not found"})
+		return
+	} else if err != nil {
+		log.Printf("Error fetching profile %d: %v", id, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch profile"})
+		return
+	}
+
+	page, err := db.ListProfiles(db.ProfileFilter{CanonicalID: id})
+	if err != nil {
+		log.Println("Error listing duplicate profiles:", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Could not fetch profiles from database."})
+		return
+	}
+	c.JSON(http.StatusOK, page.Profiles)
+}
+
+// executeProfileRequest is the payload accepted by executeProfileHandler.
+type executeProfileRequest struct {
+	Hostname   string `json:"hostname" binding:"required"`
+	Username   string `json:"username" binding:"required"`
+	Profile    string `json:"profile" binding:"required"`
+	PrivateKey string `json:"private_key" binding:"required"`
+	// TimeoutSeconds overrides the server's default scan timeout.
+	TimeoutSeconds int `json:"timeout_seconds"`
+	// Engine selects one of the server's configured scan engines, such as
+	// inspec or cinc-auditor, instead of the default one.
+	Engine string `json:"engine"`
+	// Ref pins the profile's git repository to a branch, tag or commit SHA.
+	Ref string `json:"ref"`
+}
+
+// executeProfileHandler queues an InSpec profile execution and returns the
+// ID of the job that tracks it. The scan itself runs in the background once
+// the scheduler has a free slot and no other scan targets the same host; its
+// progress and results are available from getJobHandler.
+//
+// @Summary Execute InSpec profile
+// @Description Queues an InSpec profile execution on a remote host using SSH authentication and returns the job ID. Unless the server allows any profile (REQUIRE_APPROVED_PROFILES=false), the profile must be an approved catalog profile of a git source, and it runs at its reviewed commit. The scan is stopped and recorded as timed out if it runs longer than `timeout_seconds` (or the server default). Set `ref` to a branch, tag or commit SHA to run the profile's git repository at that revision; the resolved commit and the profile's inspec.yml version are recorded on the job and its run.
+// @Tags jobs
 // @Accept json
 // @Produce json
-// @Param request body any{Hostname string `json:"hostname"`; Username string `json:"username"`; Profile string `json:"profile"`; PrivateKey string `json:"private_key"`} true "Execution request"
-// @Success 200 {object} map[string]interface{} "Execution results"
+// @Param request body executeProfileRequest true "Execution request"
+// @Success 202 {object} map[string]interface{} "Job queued"
 // @Failure 400 {object} map[string]interface{} "Invalid request"
-// @Failure 500 {object} map[string]interface{} "Execution failed"
+// @Failure 403 {object} map[string]interface{} "Profile not approved"
+// @Failure 500 {object} map[string]interface{} "Failed to queue execution"
 // @Router /execute-profile [post]
 func executeProfileHandler(c *gin.Context) {
-	// Parse request body
-	var req struct {
-		Hostname   string `json:"hostname"`
-		Username   string `json:"username"`
-		Profile    string `json:"profile"`
-		PrivateKey string `json:"private_key"`
-	}
-
+	var req executeProfileRequest
 	if err := c.ShouldBindJSON(&req); err != nil {
 		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request"})
 		return
@@ -169,34 +395,83 @@ func executeProfileHandler(c *gin.Context) {
 	// Decode the Base64 encoded private key
 	decodedKey, err := base64.StdEncoding.DecodeString(req.PrivateKey)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to decode private key"})
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Failed to decode private key"})
 		return
 	}
 
-	// Save the decoded private key to a temporary file
-	privateKeyPath := "/tmp/temp-key.pem"
-	if err := os.WriteFile(privateKeyPath, decodedKey, 0600); err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to save private key"})
-		return
+	timeout := settings.ScanTimeout
+	if req.TimeoutSeconds != 0 {
+		// Check the bounds before converting, which could overflow
+		if req.TimeoutSeconds < 0 || float64(req.TimeoutSeconds) > settings.MaxScanTimeout.Seconds() {
+			c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("timeout_seconds must be between 1 and %d", int(settings.MaxScanTimeout.Seconds()))})
+			return
+		}
+		timeout = time.Duration(req.TimeoutSeconds) * time.Second
 	}
-	defer os.Remove(privateKeyPath) // Cleanup after execution
 
-	log.Printf("Executing InSpec profile %s on %s as %s", req.Profile, req.Hostname, req.Username)
-	log.Printf("Private key saved to %s", privateKeyPath)
+	engine := settings.DefaultEngine
+	if req.Engine != "" {
+		if _, ok := settings.Engines[req.Engine]; !ok {
+			c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("Unknown engine %q", req.Engine)})
+			return
+		}
+		engine = req.Engine
+	}
 
-	start := time.Now()
-	// Construct InSpec command
-	cmd := exec.Command("inspec", "exec", req.Profile, "-t", fmt.Sprintf("ssh://%s@%s", req.Username, req.Hostname), "-i", privateKeyPath, "--chef-license", "accept", "--chef-license-key", "free-833b40cf-336a-42ee-b71d-f14a078107b9-5090")
+	// Only reviewed code may run with the requester's credentials
+	var approved models.Profile
+	if settings.RequireApprovedProfiles {
+		approved, err = approvedProfile(req.Profile)
+		if errors.Is(err, errNotApproved) {
+			c.JSON(http.StatusForbidden, gin.H{"error": err.Error()})
+			return
+		}
+		if err != nil {
+			log.Println("Error checking profile approval:", err)
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to queue execution"})
+			return
+		}
+	}
 
-	// Execute command and capture output
-	output, err := cmd.CombinedOutput()
-	log.Printf("InSpec command executed in %s", time.Since(start))
+	job := models.Job{
+		Hostname:       req.Hostname,
+		Username:       req.Username,
+		Profile:        req.Profile,
+		Ref:            req.Ref,
+		Engine:         engine,
+		TimeoutSeconds: int(timeout.Seconds()),
+		Instance:       settings.InstanceID,
+	}
 
-	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Execution failed", "details": string(output)})
+	// Resolve the ref now, so the job runs exactly what was current when it
+	// was requested even if the branch moves while it is queued
+	if req.Ref != "" {
+		if job.CommitSHA, err = profileCatalog.ResolveRef(c.Request.Context(), req.Profile, req.Ref); err != nil {
+			log.Println("Error resolving profile ref:", err)
+			c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("Could not resolve ref %q of profile %s", req.Ref, req.Profile)})
+			return
+		}
+	}
+	if settings.RequireApprovedProfiles {
+		if req.Ref == "" {
+			job.CommitSHA = approved.ReviewedSHA
+		}
+		if err := checkReviewedCommit(approved, job.CommitSHA); err != nil {
+			c.JSON(http.StatusForbidden, gin.H{"error": err.Error()})
+			return
+		}
+	}
+	if err := db.CreateJob(&job); err != nil {
+		log.Println("Error creating job:", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to queue execution"})
 		return
 	}
 
-	// Return execution results
-	c.JSON(http.StatusOK, gin.H{"output": string(output)})
+	submitJob(job, decodedKey)
+
+	c.JSON(http.StatusAccepted, gin.H{
+		"job_id":     job.ID,
+		"status":     job.Status,
+		"status_url": fmt.Sprintf("/jobs/%d", job.ID),
+	})
 }
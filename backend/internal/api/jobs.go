@@ -0,0 +1,282 @@
+package api
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/ahasunos/caas/backend/internal/db"
+	"github.com/ahasunos/caas/backend/internal/executor"
+	"github.com/ahasunos/caas/backend/internal/models"
+	"github.com/ahasunos/caas/backend/internal/scheduler"
+	"github.com/ahasunos/caas/backend/internal/stream"
+	"github.com/gin-gonic/gin"
+)
+
+// getJobHandler godoc
+// @Summary Get job status
+// @Description Returns the status, timestamps, exit code, raw output and structured results of a profile execution job. Queued jobs also report their position in the queue.
+// @Tags jobs
+// @Produce json
+// @Param id path int true "Job ID"
+// @Success 200 {object} models.Job
+// @Failure 400 {object} map[string]interface{} "Invalid job ID"
+// @Failure 404 {object} map[string]interface{} "Job not found"
+// @Failure 500 {object} map[string]interface{} "Failed to fetch job"
+// @Router /jobs/{id} [get]
+func getJobHandler(c *gin.Context) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid job ID"})
+		return
+	}
+
+	job, err := db.GetJob(id)
+	if errors.Is(err, sql.ErrNoRows) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Job not found"})
+		return
+	}
+	if err != nil {
+		log.Printf("Error fetching job %d: %v", id, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch job"})
+		return
+	}
+
+	if job.Status == models.JobQueued {
+		job.QueuePosition = scans.Position(job.ID)
+	}
+
+	c.JSON(http.StatusOK, job)
+}
+
+// getQueueHandler godoc
+// @Summary Get execution queue status
+// @Description Returns the number of queued and running executions and the concurrency limit.
+// @Tags jobs
+// @Produce json
+// @Success 200 {object} scheduler.Stats
+// @Router /jobs/queue [get]
+func getQueueHandler(c *gin.Context) {
+	c.JSON(http.StatusOK, scans.Stats())
+}
+
+// errJobCancelled is the cancellation cause of jobs cancelled through the API.
+var errJobCancelled = errors.New("cancelled by request")
+
+// outputs holds the live output of queued, running and recently finished jobs.
+var outputs = stream.NewRegistry(5 * time.Minute)
+
+// inflightJob is a job that is queued or running.
+type inflightJob struct {
+	cancel context.CancelCauseFunc
+	// privateKey is the caller's decoded SSH key, cleared once the job is
+	// released.
+	privateKey []byte
+}
+
+// inflight holds the jobs that are queued or running.
+var inflight = struct {
+	sync.Mutex
+	jobs map[int]inflightJob
+}{jobs: make(map[int]inflightJob)}
+
+// submitJob registers a queued job for cancellation and hands it to the
+// scheduler.
+func submitJob(job models.Job, privateKey []byte) {
+	ctx, cancel := context.WithCancelCause(context.Background())
+	outputs.Open(job.ID)
+
+	inflight.Lock()
+	inflight.jobs[job.ID] = inflightJob{cancel: cancel, privateKey: privateKey}
+	inflight.Unlock()
+
+	scans.Submit(scheduler.Task{
+		ID:     job.ID,
+		Target: job.Hostname,
+		Run:    func() { runJob(ctx, job, privateKey) },
+	})
+}
+
+// releaseJob forgets a job that has finished or was cancelled before it
+// ran, and clears its private key from memory.
+func releaseJob(id int) {
+	inflight.Lock()
+	defer inflight.Unlock()
+
+	if job, ok := inflight.jobs[id]; ok {
+		job.cancel(nil)
+		clear(job.privateKey)
+		delete(inflight.jobs, id)
+	}
+}
+
+// cancelJobHandler godoc
+// @Summary Cancel job
+// @Description Cancels a queued or running profile execution. A queued job is cancelled immediately; a running scan has its InSpec process group terminated and is recorded as cancelled once it has stopped.
+// @Tags jobs
+// @Produce json
+// @Param id path int true "Job ID"
+// @Success 202 {object} map[string]interface{} "Cancellation requested"
+// @Failure 400 {object} map[string]interface{} "Invalid job ID"
+// @Failure 404 {object} map[string]interface{} "Job not found"
+// @Failure 409 {object} map[string]interface{} "Job already finished"
+// @Failure 500 {object} map[string]interface{} "Failed to cancel job"
+// @Router /jobs/{id} [delete]
+func cancelJobHandler(c *gin.Context) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid job ID"})
+		return
+	}
+
+	job, err := db.GetJob(id)
+	if errors.Is(err, sql.ErrNoRows) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Job not found"})
+		return
+	}
+	if err != nil {
+		log.Printf("Error fetching job %d: %v", id, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to cancel job"})
+		return
+	}
+
+	inflight.Lock()
+	running, ok := inflight.jobs[id]
+	inflight.Unlock()
+	if job.Finished() || !ok {
+		c.JSON(http.StatusConflict, gin.H{"error": "Job already finished", "status": job.Status})
+		return
+	}
+
+	running.cancel(errJobCancelled)
+
+	// A job still waiting in the queue will never run, so finish it here
+	if scans.Remove(id) {
+		releaseJob(id)
+		defer outputs.Close(id)
+		job.Status = models.JobCancelled
+		job.Error = errJobCancelled.Error()
+		if err := db.FinishJob(job); err != nil {
+			log.Println("Error updating job:", err)
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to cancel job"})
+			return
+		}
+		c.JSON(http.StatusAccepted, gin.H{"job_id": id, "status": models.JobCancelled})
+		return
+	}
+
+	c.JSON(http.StatusAccepted, gin.H{"job_id": id, "status": "cancelling"})
+}
+
+// runJob executes the InSpec profile described by job and records the
+// outcome. The scan is stopped when ctx is cancelled or when the job's
+// timeout expires. It is meant to be run in its own goroutine.
+func runJob(ctx context.Context, job models.Job, privateKey []byte) {
+	// Followers of the output are told the job is over once it is recorded
+	defer outputs.Close(job.ID)
+	// Don't keep the caller's key in memory any longer than the run
+	defer clear(privateKey)
+	defer releaseJob(job.ID)
+
+	defer func() {
+		// A panicking run must not take the server down; its workspace has
+		// already been removed by the executor's deferred cleanup.
+		if r := recover(); r != nil {
+			log.Printf("Job %d panicked: %v", job.ID, r)
+			job.Status = models.JobFailed
+			job.Error = fmt.Sprintf("internal error: %v", r)
+			if err := db.FinishJob(job); err != nil {
+				log.Println("Error updating job:", err)
+			}
+		}
+	}()
+
+	// The job may have been cancelled just as it was dispatched
+	if ctx.Err() != nil {
+		job.Status = models.JobCancelled
+		job.Error = context.Cause(ctx).Error()
+		if err := db.FinishJob(job); err != nil {
+			log.Println("Error updating job:", err)
+		}
+		return
+	}
+
+	if err := db.MarkJobRunning(job.ID); err != nil {
+		log.Println("Error updating job:", err)
+	}
+
+	timeout := time.Duration(job.TimeoutSeconds) * time.Second
+	runCtx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	var live io.Writer
+	if l := outputs.Get(job.ID); l != nil {
+		live = l
+	}
+
+	// Pinned and catalog profiles run from the local cache instead of being
+	// downloaded by InSpec
+	var res executor.Result
+	checkout, err := checkoutProfile(runCtx, job)
+	if err == nil {
+		defer checkout.Release()
+		job.CommitSHA = checkout.CommitSHA
+		job.ProfileVersion = checkout.Version
+		res, err = inspec.Execute(runCtx, executor.Request{
+			JobID:      job.ID,
+			Hostname:   job.Hostname,
+			Username:   job.Username,
+			Profile:    checkout.Path,
+			Engine:     job.Engine,
+			PrivateKey: privateKey,
+		}, live)
+	} else {
+		err = fmt.Errorf("failed to check out profile: %v", err)
+	}
+
+	job.Status = models.JobSucceeded
+	job.ExitCode = res.ExitCode
+	job.Output = res.Output
+	job.EngineVersion = res.EngineVersion
+	job.Result = res.Report
+	if job.ProfileVersion == "" && job.Result != nil && len(job.Result.Profiles) > 0 {
+		// InSpec fetched the profile itself; the report lists it first
+		job.ProfileVersion = job.Result.Profiles[0].Version
+	}
+	switch {
+	case ctx.Err() != nil:
+		job.Status = models.JobCancelled
+		job.Error = context.Cause(ctx).Error()
+	case errors.Is(runCtx.Err(), context.DeadlineExceeded):
+		job.Status = models.JobTimedOut
+		job.Error = fmt.Sprintf("execution timed out after %s", timeout)
+	case err != nil:
+		job.Status = models.JobFailed
+		job.Error = err.Error()
+	}
+
+	// Keep the results of completed scans in the scan history
+	if job.Status == models.JobSucceeded && job.Result != nil {
+		job.Result.JobID = job.ID
+		job.Result.Hostname = job.Hostname
+		job.Result.Profile = job.Profile
+		job.Result.CommitSHA = job.CommitSHA
+		job.Result.ProfileVersion = job.ProfileVersion
+		job.Result.Engine = job.Engine
+		job.Result.EngineVersion = job.EngineVersion
+		if err := db.SaveRun(job.Result); err != nil {
+			log.Println("Error saving run:", err)
+		}
+	}
+
+	if err := db.FinishJob(job); err != nil {
+		log.Println("Error updating job:", err)
+	}
+}
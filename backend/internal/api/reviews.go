@@ -0,0 +1,229 @@
+package api
+
+import (
+	"context"
+	"crypto/subtle"
+	"database/sql"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/ahasunos/caas/backend/internal/catalog"
+	"github.com/ahasunos/caas/backend/internal/db"
+	"github.com/ahasunos/caas/backend/internal/models"
+	"github.com/gin-gonic/gin"
+)
+
+// errNotApproved is returned for profiles that may not be executed because
+// they aren't approved catalog profiles.
+var errNotApproved = errors.New("profile is not approved")
+
+// requireAdmin rejects requests that don't carry the admin token as a
+// bearer token. The admin API is disabled if no token is configured.
+func requireAdmin(c *gin.Context) {
+	if settings.AdminToken == "" {
+		c.AbortWithStatusJSON(http.StatusForbidden, gin.H{"error": "The admin API is disabled, set ADMIN_TOKEN to enable it"})
+		return
+	}
+	token, ok := strings.CutPrefix(c.GetHeader("Authorization"), "Bearer ")
+	if !ok || subtle.ConstantTimeCompare([]byte(token), []byte(settings.AdminToken)) != 1 {
+		c.Header("WWW-Authenticate", "Bearer")
+		c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": "Invalid admin token"})
+		return
+	}
+	c.Next()
+}
+
+// reviewProfileRequest is the payload accepted by reviewProfileHandler.
+type reviewProfileRequest struct {
+	Status   string `json:"status" binding:"required"`
+	Reviewer string `json:"reviewer" binding:"required"`
+	Notes    string `json:"notes"`
+	// Ref is the branch, tag or commit SHA of the profile's git repository
+	// that was reviewed. It defaults to the latest commit seen by the
+	// catalog sync, or to the default branch if there is none.
+	Ref string `json:"ref"`
+}
+
+// reviewProfileHandler godoc
+// @Summary Review a profile
+// @Description Sets the review status of a catalog profile to pending, approved or blocked, and records the review in its history. Reviews of profiles from git sources apply to a commit, `ref` or else the latest commit seen by the catalog sync, resolved from the default branch when approving a profile the sync hasn't checked yet; executions of an approved profile run that commit.
+// @Tags admin
+// @Accept json
+// @Produce json
+// @Security AdminToken
+// @Param id path int true "Profile ID"
+// @Param request body reviewProfileRequest true "Review"
+// @Success 200 {object} models.Profile
+// @Failure 400 {object} map[string]interface{} "Invalid request"
+// @Failure 401 {object} map[string]interface{} "Invalid admin token"
+// @Failure 403 {object} map[string]interface{} "Admin API disabled"
+// @Failure 404 {object} map[string]interface{} "Profile not found"
+// @Failure 500 {object} map[string]interface{} "Failed to review profile"
+// @Router /admin/profiles/{id}/review [put]
+func reviewProfileHandler(c *gin.Context) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid profile ID"})
+		return
+	}
+	var req reviewProfileRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request"})
+		return
+	}
+	switch req.Status {
+	case models.ReviewPending, models.ReviewApproved, models.ReviewBlocked:
+	default:
+		c.JSON(http.StatusBadRequest, gin.H{"error": "status must be pending, approved or blocked"})
+		return
+	}
+
+	profile, err := db.GetProfile(id)
+	if errors.Is(err, sql.ErrNoRows) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Profile not found"})
+		return
+	}
+	if err != nil {
+		log.Printf("Error fetching profile %d: %v", id, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch profile"})
+		return
+	}
+
+	review := models.ProfileReview{
+		ProfileID: id,
+		Status:    req.Status,
+		Reviewer:  req.Reviewer,
+		Notes:     req.Notes,
+		CommitSHA: profile.CommitSHA,
+	}
+	if req.Ref != "" {
+		if review.CommitSHA, err = profileCatalog.ResolveRef(c.Request.Context(), profile.URL, req.Ref); err != nil {
+			log.Println("Error resolving profile ref:", err)
+			c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("Could not resolve ref %q of profile %s", req.Ref, profile.URL)})
+			return
+		}
+	}
+	// An approval must pin the commit that executions run, so approve the
+	// default branch of git profiles whose latest commit wasn't seen yet
+	if review.Status == models.ReviewApproved && review.CommitSHA == "" && gitProfile(profile) {
+		if review.CommitSHA, err = profileCatalog.ResolveRef(c.Request.Context(), profile.URL, ""); err != nil {
+			log.Println("Error resolving profile commit:", err)
+			c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("Could not resolve the latest commit of profile %s, give the reviewed ref", profile.URL)})
+			return
+		}
+	}
+	if err := db.ReviewProfile(&review); err != nil {
+		log.Println("Error reviewing profile:", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to review profile"})
+		return
+	}
+	log.Printf("Profile %d (%s) %s by %s", id, profile.URL, review.Status, review.Reviewer)
+
+	if profile, err = db.GetProfile(id); err != nil {
+		log.Printf("Error fetching profile %d: %v", id, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch profile"})
+		return
+	}
+	c.JSON(http.StatusOK, profile)
+}
+
+// listProfileReviewsHandler godoc
+// @Summary List profile reviews
+// @Description Returns the review history of a catalog profile, latest first.
+// @Tags admin
+// @Produce json
+// @Security AdminToken
+// @Param id path int true "Profile ID"
+// @Success 200 {array} models.ProfileReview
+// @Failure 400 {object} map[string]interface{} "Invalid profile ID"
+// @Failure 401 {object} map[string]interface{} "Invalid admin token"
+// @Failure 403 {object} map[string]interface{} "Admin API disabled"
+// @Failure 404 {object} map[string]interface{} "Profile not found"
+// @Failure 500 {object} map[string]interface{} "Failed to fetch reviews"
+// @Router /admin/profiles/{id}/reviews [get]
+func listProfileReviewsHandler(c *gin.Context) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid profile ID"})
+		return
+	}
+	if _, err := db.GetProfile(id); errors.Is(err, sql.ErrNoRows) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Profile not found"})
+		return
+	} else if err != nil {
+		log.Printf("Error fetching profile %d: %v", id, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch profile"})
+		return
+	}
+
+	reviews, err := db.ListProfileReviews(id)
+	if err != nil {
+		log.Println("Error listing profile reviews:", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch reviews"})
+		return
+	}
+	c.JSON(http.StatusOK, reviews)
+}
+
+// approvedProfile returns the catalog profile at profileURL if it is
+// approved. The error wraps errNotApproved if the URL is not in the catalog
+// or its profile is pending or blocked.
+func approvedProfile(profileURL string) (models.Profile, error) {
+	profile, err := db.GetProfileByURL(profileURL)
+	if errors.Is(err, sql.ErrNoRows) {
+		return profile, fmt.Errorf("%w: %s is not in the catalog", errNotApproved, profileURL)
+	}
+	if err != nil {
+		return profile, err
+	}
+	if profile.ReviewStatus != models.ReviewApproved {
+		return profile, fmt.Errorf("%w: %s is %s", errNotApproved, profileURL, profile.ReviewStatus)
+	}
+	return profile, nil
+}
+
+// checkReviewedCommit returns an error wrapping errNotApproved if a job of
+// an approved profile would run another commit than the reviewed one. Git
+// profiles approved without a commit must be reviewed again, and profiles
+// of other sources, such as Supermarket or local profiles, are refused as
+// they can't be pinned to the reviewed version.
+func checkReviewedCommit(profile models.Profile, sha string) error {
+	if !gitProfile(profile) {
+		return fmt.Errorf("%w: %s isn't a git profile and can't be pinned to a reviewed version", errNotApproved, profile.URL)
+	}
+	if profile.ReviewedSHA == "" {
+		return fmt.Errorf("%w: %s was approved without a reviewed commit", errNotApproved, profile.URL)
+	}
+	if sha != profile.ReviewedSHA {
+		return fmt.Errorf("%w: commit %s of %s is not the reviewed commit %s", errNotApproved, sha, profile.URL, profile.ReviewedSHA)
+	}
+	return nil
+}
+
+// gitProfile reports whether a catalog profile is a git repository, which
+// is run at a commit. Like catalog checkouts, profiles of sources that are
+// no longer configured are taken for git repositories.
+func gitProfile(profile models.Profile) bool {
+	src, ok := profileCatalog.Source(profile.Source)
+	return !ok || src.Git()
+}
+
+// checkoutProfile checks out the profile of a job. If approved profiles are
+// required, the profile is checked once more, as it may have been blocked
+// or reviewed at another commit while the job was queued.
+func checkoutProfile(ctx context.Context, job models.Job) (catalog.Checkout, error) {
+	if settings.RequireApprovedProfiles {
+		profile, err := approvedProfile(job.Profile)
+		if err == nil {
+			err = checkReviewedCommit(profile, job.CommitSHA)
+		}
+		if err != nil {
+			return catalog.Checkout{}, err
+		}
+	}
+	return profileCatalog.Checkout(ctx, job.Profile, job.CommitSHA)
+}
@@ -0,0 +1,45 @@
+package api
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/ahasunos/caas/backend/internal/catalog"
+	"github.com/ahasunos/caas/backend/internal/models"
+	"github.com/ahasunos/caas/backend/internal/sources"
+)
+
+func TestCheckReviewedCommit(t *testing.T) {
+	old := profileCatalog
+	t.Cleanup(func() { profileCatalog = old })
+	profileCatalog = catalog.NewSyncer(nil, []sources.ProfileSource{
+		sources.NewGitLab(sources.GitLabOptions{Name: "gitlab", URL: "https://gitlab.example.com"}),
+		sources.NewSupermarket("supermarket", "https://supermarket.chef.io"),
+	})
+
+	const sha = "0123456789abcdef0123456789abcdef01234567"
+	tests := []struct {
+		name    string
+		profile models.Profile
+		sha     string
+		wantErr bool
+	}{
+		{name: "reviewed commit", profile: models.Profile{Source: "gitlab", ReviewedSHA: sha}, sha: sha},
+		{name: "other commit", profile: models.Profile{Source: "gitlab", ReviewedSHA: sha}, sha: "fedcba9876543210fedcba9876543210fedcba98", wantErr: true},
+		{name: "git profile approved without a commit", profile: models.Profile{Source: "gitlab"}, wantErr: true},
+		{name: "profile of a source no longer configured", profile: models.Profile{Source: "github.com"}, wantErr: true},
+		{name: "Supermarket profile", profile: models.Profile{Source: "supermarket"}, wantErr: true},
+		{name: "Supermarket profile with a commit", profile: models.Profile{Source: "supermarket", ReviewedSHA: sha}, sha: sha, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := checkReviewedCommit(tt.profile, tt.sha)
+			if tt.wantErr && !errors.Is(err, errNotApproved) {
+				t.Errorf("checkReviewedCommit error = %v, want errNotApproved", err)
+			}
+			if !tt.wantErr && err != nil {
+				t.Errorf("checkReviewedCommit error = %v, want none", err)
+			}
+		})
+	}
+}
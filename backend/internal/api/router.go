@@ -1,18 +1,70 @@
 package api
 
 import (
+	"github.com/ahasunos/caas/backend/internal/catalog"
+	"github.com/ahasunos/caas/backend/internal/config"
+	"github.com/ahasunos/caas/backend/internal/executor"
+	"github.com/ahasunos/caas/backend/internal/scheduler"
 	"github.com/gin-gonic/gin"
 )
 
-func SetupRouter() *gin.Engine {
+// Services bundles the components the handlers depend on.
+type Services struct {
+	// Scheduler queues the executions started through the API.
+	Scheduler *scheduler.Scheduler
+	// Executor runs the scheduled executions; tests can replace it with an
+	// executor.Fake.
+	Executor executor.Executor
+	// Catalog syncs the profile catalog and checks out catalog profiles.
+	Catalog *catalog.Syncer
+}
+
+var (
+	// settings holds the service configuration used by the handlers.
+	settings config.Config
+	// scans schedules the InSpec executions started through the API.
+	scans *scheduler.Scheduler
+	// inspec runs the scheduled executions.
+	inspec executor.Executor
+	// profileCatalog syncs the catalog and provides local checkouts of its
+	// profiles.
+	profileCatalog *catalog.Syncer
+)
+
+// SetupRouter registers the API routes, wiring the handlers to svc.
+func SetupRouter(cfg config.Config, svc Services) *gin.Engine {
+	settings = cfg
+	scans = svc.Scheduler
+	inspec = svc.Executor
+	profileCatalog = svc.Catalog
+
 	r := gin.Default()
 
 	// Define routes
 	r.GET("/", welcomeHandler)
 	r.GET("/fetch-profiles", fetchProfilesHandler)
 	r.GET("/update-profiles", updateProfilesHandler)
+	r.POST("/sync", startSyncHandler)
+	r.GET("/sync", listSyncsHandler)
+	r.GET("/sync/:id", getSyncHandler)
 	r.POST("/add-profile", addProfileHandler)
+	r.GET("/profiles/:id", getProfileHandler)
+	r.GET("/profiles/:id/controls", listProfileControlsHandler)
+	r.GET("/profiles/:id/duplicates", listProfileDuplicatesHandler)
+	r.GET("/controls", listControlsHandler)
 	r.POST("/execute-profile", executeProfileHandler)
+	r.GET("/jobs/queue", getQueueHandler)
+	r.GET("/jobs/:id", getJobHandler)
+	r.DELETE("/jobs/:id", cancelJobHandler)
+	r.GET("/jobs/:id/stream", streamJobHandler)
+	r.GET("/runs", listRunsHandler)
+	r.GET("/runs/:id", getRunHandler)
+	r.GET("/runs/:id/diff/:other", diffRunsHandler)
+	r.GET("/hosts/:hostname/latest", getLatestHostRunHandler)
+
+	admin := r.Group("/admin", requireAdmin)
+	admin.PUT("/profiles/:id/review", reviewProfileHandler)
+	admin.GET("/profiles/:id/reviews", listProfileReviewsHandler)
 
 	return r
 }
@@ -0,0 +1,207 @@
+package api
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/ahasunos/caas/backend/internal/db"
+	"github.com/ahasunos/caas/backend/internal/models"
+	"github.com/ahasunos/caas/backend/internal/report"
+	"github.com/gin-gonic/gin"
+)
+
+// defaultRunsLimit and maxRunsLimit bound the number of runs listed at once.
+const (
+	defaultRunsLimit = 50
+	maxRunsLimit     = 500
+)
+
+// listRunsHandler godoc
+// @Summary List scan runs
+// @Description Returns stored scan runs, newest first, optionally filtered by host, profile and time range. Dates may be given as RFC 3339 timestamps or as YYYY-MM-DD; a date-only `until` includes the whole day.
+// @Tags runs
+// @Produce json
+// @Param host query string false "Hostname the run targeted"
+// @Param profile query string false "Profile that was executed"
+// @Param since query string false "Only runs created at or after this time (RFC 3339, or a date in UTC)"
+// @Param until query string false "Only runs created at or before this time (RFC 3339, or a date in UTC)"
+// @Param limit query int false "Maximum number of runs to return (default 50, max 500)"
+// @Success 200 {array} models.Run
+// @Failure 400 {object} map[string]interface{} "Invalid query parameter"
+// @Failure 500 {object} map[string]interface{} "Failed to fetch runs"
+// @Router /runs [get]
+func listRunsHandler(c *gin.Context) {
+	filter := db.RunFilter{
+		Hostname: c.Query("host"),
+		Profile:  c.Query("profile"),
+		Limit:    defaultRunsLimit,
+	}
+
+	var err error
+	if filter.Since, err = parseTimeParam(c.Query("since"), false); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid since parameter"})
+		return
+	}
+	if filter.Until, err = parseTimeParam(c.Query("until"), true); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid until parameter"})
+		return
+	}
+	if limit := c.Query("limit"); limit != "" {
+		n, err := strconv.Atoi(limit)
+		if err != nil || n < 1 || n > maxRunsLimit {
+			c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("limit must be between 1 and %d", maxRunsLimit)})
+			return
+		}
+		filter.Limit = n
+	}
+
+	runs, err := db.ListRuns(filter)
+	if err != nil {
+		log.Println("Error listing runs:", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Could not fetch runs from database."})
+		return
+	}
+
+	c.JSON(http.StatusOK, runs)
+}
+
+// getRunHandler godoc
+// @Summary Get scan run
+// @Description Returns a stored scan run with all of its profiles and control results.
+// @Tags runs
+// @Produce json
+// @Param id path int true "Run ID"
+// @Success 200 {object} models.Run
+// @Failure 400 {object} map[string]interface{} "Invalid run ID"
+// @Failure 404 {object} map[string]interface{} "Run not found"
+// @Failure 500 {object} map[string]interface{} "Failed to fetch run"
+// @Router /runs/{id} [get]
+func getRunHandler(c *gin.Context) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid run ID"})
+		return
+	}
+
+	respondWithRun(c, id)
+}
+
+// diffRunsHandler godoc
+// @Summary Diff two scan runs
+// @Description Compares the control results of two stored runs and reports the controls that started failing, started passing, became skipped, were added or were removed when going from the first run to the second.
+// @Tags runs
+// @Produce json
+// @Param id path int true "Baseline run ID"
+// @Param other path int true "Run ID to compare against the baseline"
+// @Success 200 {object} models.RunDiff
+// @Failure 400 {object} map[string]interface{} "Invalid run ID"
+// @Failure 404 {object} map[string]interface{} "Run not found"
+// @Failure 500 {object} map[string]interface{} "Failed to fetch run"
+// @Router /runs/{id}/diff/{other} [get]
+func diffRunsHandler(c *gin.Context) {
+	fromID, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid run ID"})
+		return
+	}
+	toID, err := strconv.Atoi(c.Param("other"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid run ID"})
+		return
+	}
+
+	var runs [2]models.Run
+	for i, id := range []int{fromID, toID} {
+		runs[i], err = db.GetRun(id)
+		if errors.Is(err, sql.ErrNoRows) {
+			c.JSON(http.StatusNotFound, gin.H{"error": fmt.Sprintf("Run %d not found", id)})
+			return
+		}
+		if err != nil {
+			log.Printf("Error fetching run %d: %v", id, err)
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch run"})
+			return
+		}
+	}
+
+	c.JSON(http.StatusOK, report.Diff(runs[0], runs[1]))
+}
+
+// getLatestHostRunHandler godoc
+// @Summary Get latest run for a host
+// @Description Returns the most recent scan run against a host, with its control results. Use `at` to see the host's compliance state as of a given time.
+// @Tags runs
+// @Produce json
+// @Param hostname path string true "Hostname"
+// @Param profile query string false "Only consider runs of this profile"
+// @Param at query string false "Latest run created at or before this time (default now)"
+// @Success 200 {object} models.Run
+// @Failure 400 {object} map[string]interface{} "Invalid query parameter"
+// @Failure 404 {object} map[string]interface{} "No run found for host"
+// @Failure 500 {object} map[string]interface{} "Failed to fetch run"
+// @Router /hosts/{hostname}/latest [get]
+func getLatestHostRunHandler(c *gin.Context) {
+	at, err := parseTimeParam(c.Query("at"), true)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid at parameter"})
+		return
+	}
+	if at.IsZero() {
+		at = time.Now().UTC()
+	}
+
+	id, err := db.GetLatestRunID(c.Param("hostname"), c.Query("profile"), at)
+	if errors.Is(err, sql.ErrNoRows) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "No run found for host"})
+		return
+	}
+	if err != nil {
+		log.Println("Error fetching latest run:", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch run"})
+		return
+	}
+
+	respondWithRun(c, id)
+}
+
+// respondWithRun writes the run with the given ID, or the matching error.
+func respondWithRun(c *gin.Context, id int) {
+	run, err := db.GetRun(id)
+	if errors.Is(err, sql.ErrNoRows) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Run not found"})
+		return
+	}
+	if err != nil {
+		log.Printf("Error fetching run %d: %v", id, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch run"})
+		return
+	}
+
+	c.JSON(http.StatusOK, run)
+}
+
+// parseTimeParam parses an RFC 3339 timestamp or a YYYY-MM-DD date, which
+// is taken to be in UTC, and returns it in UTC like the creation times of
+// runs are stored. An empty value yields the zero time. With endOfDay set, a
+// bare date refers to the last instant of that day instead of its start.
+func parseTimeParam(value string, endOfDay bool) (time.Time, error) {
+	if value == "" {
+		return time.Time{}, nil
+	}
+	if t, err := time.Parse(time.RFC3339, value); err == nil {
+		return t.UTC(), nil
+	}
+	t, err := time.Parse(time.DateOnly, value)
+	if err != nil {
+		return time.Time{}, err
+	}
+	if endOfDay {
+		t = t.Add(24*time.Hour - time.Nanosecond)
+	}
+	return t, nil
+}
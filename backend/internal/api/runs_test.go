@@ -0,0 +1,50 @@
+package api
+
+import (
+	"database/sql/driver"
+	"net/http"
+	"regexp"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/ahasunos/caas/backend/internal/executor"
+)
+
+// utcTime matches a time argument equal to the time and given in UTC, as
+// the creation times of runs are stored without a time zone.
+type utcTime time.Time
+
+func (t utcTime) Match(v driver.Value) bool {
+	got, ok := v.(time.Time)
+	return ok && got.Location() == time.UTC && got.Equal(time.Time(t))
+}
+
+func TestListRunsConvertsOffsetsToUTC(t *testing.T) {
+	r, mock := setupAPI(t, executor.NewFake(), 1)
+
+	since := time.Date(2025, 3, 1, 10, 0, 0, 0, time.UTC)
+	until := time.Date(2025, 3, 2, 0, 0, 0, 0, time.UTC).Add(-time.Nanosecond)
+	mock.ExpectQuery(regexp.QuoteMeta("FROM runs WHERE created_at >= $1 AND created_at <= $2")).
+		WithArgs(utcTime(since), utcTime(until), defaultRunsLimit).
+		WillReturnRows(sqlmock.NewRows([]string{"id"}))
+
+	w := request(r, http.MethodGet, "/runs?since=2025-03-01T12:00:00%2B02:00&until=2025-03-01", nil)
+	if w.Code != http.StatusOK {
+		t.Errorf("GET /runs = %d %s, want 200", w.Code, w.Body)
+	}
+}
+
+func TestLatestHostRunConvertsOffsetsToUTC(t *testing.T) {
+	r, mock := setupAPI(t, executor.NewFake(), 1)
+
+	at := time.Date(2025, 3, 1, 17, 30, 0, 0, time.UTC)
+	mock.ExpectQuery(regexp.QuoteMeta("SELECT id FROM runs WHERE hostname = $1")).
+		WithArgs("web-1", "", utcTime(at)).
+		WillReturnRows(sqlmock.NewRows([]string{"id"}))
+
+	w := request(r, http.MethodGet, "/hosts/web-1/latest?at=2025-03-01T12:30:00-05:00", nil)
+	if w.Code != http.StatusNotFound {
+		t.Errorf("GET /hosts/web-1/latest = %d %s, want 404", w.Code, w.Body)
+	}
+}
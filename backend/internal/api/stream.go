@@ -0,0 +1,163 @@
+package api
+
+import (
+	"database/sql"
+	"errors"
+	"io"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/ahasunos/caas/backend/internal/db"
+	"github.com/ahasunos/caas/backend/internal/models"
+	"github.com/gin-contrib/sse"
+	"github.com/gin-gonic/gin"
+)
+
+// keepAliveInterval is how often an idle stream sends a comment so that
+// proxies don't close the connection.
+const keepAliveInterval = 15 * time.Second
+
+// jobPollInterval is how often a stream of a job without a live log checks
+// whether the job has finished.
+var jobPollInterval = 2 * time.Second
+
+// streamJobHandler godoc
+// @Summary Stream job output
+// @Description Streams the output of a profile execution as Server-Sent Events. Each `line` event carries one line of InSpec output and uses the line number as its event ID, so clients reconnecting with `Last-Event-ID` (or `?from=`) resume where they left off. Once the job has finished, a `control` event is sent for every control of the report, followed by a `done` event with the final job. The output of jobs running on another instance is sent once they have finished.
+// @Tags jobs
+// @Produce text/event-stream
+// @Param id path int true "Job ID"
+// @Param from query int false "Number of lines already received"
+// @Success 200 {string} string "Event stream"
+// @Failure 400 {object} map[string]interface{} "Invalid job ID"
+// @Failure 404 {object} map[string]interface{} "Job not found"
+// @Failure 500 {object} map[string]interface{} "Failed to fetch job"
+// @Router /jobs/{id}/stream [get]
+func streamJobHandler(c *gin.Context) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid job ID"})
+		return
+	}
+
+	from := 0
+	if lastID := c.GetHeader("Last-Event-ID"); lastID != "" {
+		from, err = strconv.Atoi(lastID)
+	} else if q := c.Query("from"); q != "" {
+		from, err = strconv.Atoi(q)
+	}
+	if err != nil || from < 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid resume position"})
+		return
+	}
+
+	// Look up the live log before the job, so that a job finishing in
+	// between is still read back completely from the database
+	live := outputs.Get(id)
+	job, err := db.GetJob(id)
+	if errors.Is(err, sql.ErrNoRows) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Job not found"})
+		return
+	}
+	if err != nil {
+		log.Printf("Error fetching job %d: %v", id, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch job"})
+		return
+	}
+
+	c.Header("Cache-Control", "no-cache")
+	c.Header("X-Accel-Buffering", "no")
+	c.Render(http.StatusOK, sse.Event{Event: "status", Data: gin.H{"job_id": job.ID, "status": job.Status}})
+	c.Writer.Flush()
+
+	keepAlive := time.NewTicker(keepAliveInterval)
+	defer keepAlive.Stop()
+
+	// Without a live log, the job runs on another instance or its log isn't
+	// open yet, so wait for either the log or the end of the job
+	if live == nil && !job.Finished() {
+		poll := time.NewTicker(jobPollInterval)
+		defer poll.Stop()
+
+		for live == nil && !job.Finished() {
+			select {
+			case <-poll.C:
+			case <-keepAlive.C:
+				io.WriteString(c.Writer, ": keep-alive\n\n")
+				c.Writer.Flush()
+				continue
+			case <-c.Request.Context().Done():
+				return
+			}
+			live = outputs.Get(id)
+			if job, err = db.GetJob(id); err != nil {
+				log.Printf("Error fetching job %d: %v", id, err)
+				return
+			}
+		}
+	}
+
+	if live != nil {
+		for {
+			lines, closed, changed := live.Since(from)
+			for _, line := range lines {
+				from++
+				c.Render(-1, sse.Event{Id: strconv.Itoa(from), Event: "line", Data: line})
+			}
+			c.Writer.Flush()
+			if closed {
+				break
+			}
+
+			select {
+			case <-changed:
+			case <-keepAlive.C:
+				io.WriteString(c.Writer, ": keep-alive\n\n")
+				c.Writer.Flush()
+			case <-c.Request.Context().Done():
+				return
+			}
+		}
+
+		// The job is recorded before its log is closed
+		if job, err = db.GetJob(id); err != nil {
+			log.Printf("Error fetching job %d: %v", id, err)
+			return
+		}
+	} else {
+		// The live log has expired, or the job ran on another instance;
+		// replay the stored output
+		lines := outputLines(job.Output)
+		for _, line := range lines[min(from, len(lines)):] {
+			from++
+			c.Render(-1, sse.Event{Id: strconv.Itoa(from), Event: "line", Data: line})
+		}
+	}
+
+	if job.Result != nil {
+		for _, p := range job.Result.Profiles {
+			for _, control := range p.Controls {
+				c.Render(-1, sse.Event{Event: "control", Data: controlEvent{Profile: p.Name, ControlResult: control}})
+			}
+		}
+	}
+	c.Render(-1, sse.Event{Event: "done", Data: job})
+	c.Writer.Flush()
+}
+
+// controlEvent is the payload of a `control` stream event.
+type controlEvent struct {
+	Profile string `json:"profile"`
+	models.ControlResult
+}
+
+// outputLines splits stored job output the same way the live log does.
+func outputLines(output string) []string {
+	if output == "" {
+		return nil
+	}
+	return strings.Split(strings.TrimSuffix(output, "\n"), "\n")
+}
@@ -0,0 +1,79 @@
+package api
+
+import (
+	"net/http"
+	"regexp"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/ahasunos/caas/backend/internal/executor"
+	"github.com/ahasunos/caas/backend/internal/models"
+	"github.com/ahasunos/caas/backend/internal/stream"
+)
+
+// useFastPolling makes streams poll for jobs without a live log right away,
+// with no logs left over from other tests.
+func useFastPolling(t *testing.T) {
+	oldInterval, oldOutputs := jobPollInterval, outputs
+	jobPollInterval, outputs = time.Millisecond, stream.NewRegistry(time.Minute)
+	t.Cleanup(func() { jobPollInterval, outputs = oldInterval, oldOutputs })
+}
+
+func TestStreamJobRunningElsewhere(t *testing.T) {
+	r, mock := setupAPI(t, executor.NewFake(), 1)
+	useFastPolling(t)
+
+	// The job runs on another instance, so there is no live log to follow
+	expectGetJob(mock, 30, models.JobRunning)
+	expectGetJob(mock, 30, models.JobRunning)
+	mock.ExpectQuery(regexp.QuoteMeta("FROM jobs WHERE id = $1")).
+		WithArgs(30).
+		WillReturnRows(sqlmock.NewRows(regexp.MustCompile(`,\s*`).Split(jobColumnsForTest, -1)).
+			AddRow(30, models.JobSucceeded, "web-1", "root", testProfile, "", "", "", "inspec", "", 60, 0, "first\nsecond\n", "", nil,
+				time.Now(), time.Now(), time.Now()))
+
+	w := request(r, http.MethodGet, "/jobs/30/stream?from=1", nil)
+	body := w.Body.String()
+	if w.Code != http.StatusOK {
+		t.Fatalf("GET /jobs/30/stream = %d %s, want 200", w.Code, body)
+	}
+	if strings.Contains(body, "data:first") || !strings.Contains(body, "id:2\nevent:line\ndata:second") {
+		t.Errorf("stream = %q, want the stored output after the first line", body)
+	}
+	if _, done, _ := strings.Cut(body, "event:done"); !strings.Contains(done, `"status":"succeeded"`) {
+		t.Errorf("done event = %q, want the finished job", done)
+	}
+}
+
+func TestStreamJobWaitsForItsLog(t *testing.T) {
+	r, mock := setupAPI(t, executor.NewFake(), 1)
+	useFastPolling(t)
+
+	// The job was created, but its log is only opened while the job is
+	// being looked up
+	mock.ExpectQuery(regexp.QuoteMeta("FROM jobs WHERE id = $1")).
+		WithArgs(31).
+		WillDelayFor(100 * time.Millisecond).
+		WillReturnRows(sqlmock.NewRows(regexp.MustCompile(`,\s*`).Split(jobColumnsForTest, -1)).
+			AddRow(31, models.JobQueued, "web-1", "root", testProfile, "", "", "", "inspec", "", 60, nil, "", "", nil, time.Now(), nil, nil))
+	expectGetJob(mock, 31, models.JobRunning)
+	expectGetJob(mock, 31, models.JobSucceeded)
+
+	go func() {
+		time.Sleep(10 * time.Millisecond)
+		l := outputs.Open(31)
+		l.Write([]byte("live\n"))
+		outputs.Close(31)
+	}()
+
+	w := request(r, http.MethodGet, "/jobs/31/stream", nil)
+	body := w.Body.String()
+	if !strings.Contains(body, "id:1\nevent:line\ndata:live") {
+		t.Errorf("stream = %q, want the live output", body)
+	}
+	if _, done, _ := strings.Cut(body, "event:done"); !strings.Contains(done, `"status":"succeeded"`) {
+		t.Errorf("done event = %q, want the finished job", done)
+	}
+}
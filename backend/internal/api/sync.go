@@ -0,0 +1,147 @@
+package api
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"strconv"
+
+	"github.com/ahasunos/caas/backend/internal/catalog"
+	"github.com/ahasunos/caas/backend/internal/db"
+	"github.com/ahasunos/caas/backend/internal/models"
+	"github.com/gin-gonic/gin"
+)
+
+// defaultSyncRunsLimit and maxSyncRunsLimit bound the number of sync runs
+// listed at once.
+const (
+	defaultSyncRunsLimit = 20
+	maxSyncRunsLimit     = 100
+)
+
+// startSyncHandler godoc
+// @Summary Start a catalog sync
+// @Description Starts syncing the profile catalog from the configured sources in the background. Only one sync runs at a time; if one is already running, status 409 is returned with its ID, which a sync just started by another instance may not have yet. Poll the status URL for progress.
+// @Tags sync
+// @Produce json
+// @Param source query []string false "Names of the sources to sync (default all)" collectionFormat(multi)
+// @Success 202 {object} map[string]interface{} "Sync started, with sync_id, status and status_url"
+// @Failure 400 {object} map[string]interface{} "Unknown source"
+// @Failure 409 {object} map[string]interface{} "A sync is already running, with its sync_id and status_url if it has been recorded"
+// @Failure 500 {object} map[string]interface{} "Failed to start sync"
+// @Router /sync [post]
+func startSyncHandler(c *gin.Context) {
+	run, started := startSync(c, models.SyncTriggerManual, c.QueryArray("source")...)
+	if run == nil {
+		return
+	}
+	if !started {
+		c.JSON(http.StatusConflict, syncRef(run, gin.H{"error": "A catalog sync is already running"}))
+		return
+	}
+	c.JSON(http.StatusAccepted, gin.H{
+		"sync_id":    run.ID,
+		"status":     run.Status,
+		"status_url": syncStatusURL(run.ID),
+	})
+}
+
+// startSync starts a catalog sync of the named sources, or of all sources,
+// and returns its run and whether it was started by this call rather than
+// already running. It responds with an error and returns nil if the sync
+// could not be started.
+func startSync(c *gin.Context, trigger string, sources ...string) (*models.SyncRun, bool) {
+	run, err := profileCatalog.Start(trigger, sources...)
+	if errors.Is(err, catalog.ErrSyncRunning) {
+		return &run, false
+	}
+	if errors.Is(err, catalog.ErrUnknownSource) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return nil, false
+	}
+	if err != nil {
+		log.Println("Error starting catalog sync:", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to start catalog sync"})
+		return nil, false
+	}
+	return &run, true
+}
+
+func syncStatusURL(id int) string {
+	return fmt.Sprintf("/sync/%d", id)
+}
+
+// syncRef adds the ID and status URL of run to a response, unless run is a
+// sync running on another instance that hasn't been recorded yet.
+func syncRef(run *models.SyncRun, h gin.H) gin.H {
+	if run.ID != 0 {
+		h["sync_id"] = run.ID
+		h["status_url"] = syncStatusURL(run.ID)
+	}
+	return h
+}
+
+// getSyncHandler godoc
+// @Summary Get catalog sync status
+// @Description Returns the status, timestamps, counts and error of a catalog sync.
+// @Tags sync
+// @Produce json
+// @Param id path int true "Sync ID"
+// @Success 200 {object} models.SyncRun
+// @Failure 400 {object} map[string]interface{} "Invalid sync ID"
+// @Failure 404 {object} map[string]interface{} "Sync not found"
+// @Failure 500 {object} map[string]interface{} "Failed to fetch sync"
+// @Router /sync/{id} [get]
+func getSyncHandler(c *gin.Context) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid sync ID"})
+		return
+	}
+
+	run, err := db.GetSyncRun(id)
+	if errors.Is(err, sql.ErrNoRows) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Sync not found"})
+		return
+	}
+	if err != nil {
+		log.Printf("Error fetching sync run %d: %v", id, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch sync"})
+		return
+	}
+
+	c.JSON(http.StatusOK, run)
+}
+
+// listSyncsHandler godoc
+// @Summary List catalog syncs
+// @Description Returns the most recent catalog syncs, newest first.
+// @Tags sync
+// @Produce json
+// @Param limit query int false "Maximum number of syncs to return (default 20, max 100)"
+// @Success 200 {array} models.SyncRun
+// @Failure 400 {object} map[string]interface{} "Invalid limit"
+// @Failure 500 {object} map[string]interface{} "Failed to fetch syncs"
+// @Router /sync [get]
+func listSyncsHandler(c *gin.Context) {
+	limit := defaultSyncRunsLimit
+	if s := c.Query("limit"); s != "" {
+		n, err := strconv.Atoi(s)
+		if err != nil || n < 1 || n > maxSyncRunsLimit {
+			c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("limit must be between 1 and %d", maxSyncRunsLimit)})
+			return
+		}
+		limit = n
+	}
+
+	runs, err := db.ListSyncRuns(limit)
+	if err != nil {
+		log.Println("Error listing sync runs:", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch syncs"})
+		return
+	}
+
+	c.JSON(http.StatusOK, runs)
+}
@@ -0,0 +1,58 @@
+package api
+
+import (
+	"database/sql"
+	"encoding/json"
+	"net/http"
+	"regexp"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/ahasunos/caas/backend/internal/executor"
+	"github.com/ahasunos/caas/backend/internal/models"
+)
+
+// expectSyncLockHeld expects the sync lock to be held by another instance.
+func expectSyncLockHeld(mock sqlmock.Sqlmock) {
+	mock.ExpectQuery(regexp.QuoteMeta("SELECT pg_try_advisory_lock($1)")).
+		WillReturnRows(sqlmock.NewRows([]string{"pg_try_advisory_lock"}).AddRow(false))
+}
+
+func TestStartSyncRunning(t *testing.T) {
+	r, mock := setupAPI(t, executor.NewFake(), 1)
+
+	expectSyncLockHeld(mock)
+	mock.ExpectQuery("FROM sync_runs WHERE status = ").
+		WithArgs(models.SyncRunning).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "status", "trigger", "sources", "pages", "repositories", "duplicates",
+			"excluded", "discovered", "added", "updated", "removed", "error", "started_at", "finished_at"}).
+			AddRow(3, models.SyncRunning, models.SyncTriggerManual, "{}", 0, 0, 0, 0, 0, 0, 0, 0, "", time.Now(), nil))
+
+	w := request(r, http.MethodPost, "/sync", nil)
+	var body map[string]any
+	json.Unmarshal(w.Body.Bytes(), &body)
+	if w.Code != http.StatusConflict || body["sync_id"] != 3.0 || body["status_url"] != "/sync/3" {
+		t.Errorf("POST /sync = %d %s, want 409 with sync 3", w.Code, w.Body)
+	}
+}
+
+func TestStartSyncRunningNotRecordedYet(t *testing.T) {
+	r, mock := setupAPI(t, executor.NewFake(), 1)
+
+	expectSyncLockHeld(mock)
+	// The instance holding the lock never records its run in time
+	for range 5 {
+		mock.ExpectQuery("FROM sync_runs WHERE status = ").WillReturnError(sql.ErrNoRows)
+	}
+
+	w := request(r, http.MethodPost, "/sync", nil)
+	var body map[string]any
+	json.Unmarshal(w.Body.Bytes(), &body)
+	if w.Code != http.StatusConflict {
+		t.Errorf("POST /sync = %d %s, want 409", w.Code, w.Body)
+	}
+	if _, ok := body["sync_id"]; ok {
+		t.Errorf("POST /sync = %s, want no sync ID", w.Body)
+	}
+}
@@ -0,0 +1,75 @@
+package catalog
+
+import (
+	"context"
+	"errors"
+	"regexp"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/ahasunos/caas/backend/internal/db"
+	"github.com/ahasunos/caas/backend/internal/models"
+	"github.com/ahasunos/caas/backend/internal/sources"
+)
+
+// errStop ends a discovery of resumableSource.
+var errStop = errors.New("stop")
+
+// resumableSource is a source whose discovery records the position it
+// started at, then stops.
+type resumableSource struct {
+	sources.ProfileSource
+	key   string
+	start int
+}
+
+func (s *resumableSource) Name() string          { return "github.com" }
+func (s *resumableSource) CheckpointKey() string { return s.key }
+
+func (s *resumableSource) Discover(ctx context.Context, start int, fn func(next int, profiles []models.Profile) error) (sources.Stats, error) {
+	s.start = start
+	return sources.Stats{}, errStop
+}
+
+func TestSyncSourceResumesMatchingCheckpoints(t *testing.T) {
+	started := time.Now().Add(-time.Hour)
+	tests := []struct {
+		name      string
+		page      int
+		key       string
+		updated   time.Time
+		wantStart int
+	}{
+		{name: "matching", page: 7, key: "per_page=100", updated: time.Now(), wantStart: 7},
+		{name: "other page size", page: 7, key: "per_page=10", updated: time.Now(), wantStart: 1},
+		{name: "recorded without settings", page: 7, key: "", updated: time.Now(), wantStart: 1},
+		{name: "stale", page: 7, key: "per_page=100", updated: time.Now().Add(-2 * checkpointMaxAge), wantStart: 1},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			conn, mock, err := sqlmock.New()
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer conn.Close()
+			db.Use(conn)
+
+			mock.ExpectQuery(regexp.QuoteMeta("FROM sync_checkpoints WHERE source = $1")).
+				WithArgs("github.com").
+				WillReturnRows(sqlmock.NewRows([]string{"page", "discovery_key", "started_at", "updated_at"}).
+					AddRow(tt.page, tt.key, started, tt.updated))
+
+			src := &resumableSource{key: "per_page=100"}
+			if _, err := NewSyncer(nil, nil).syncSource(src); !errors.Is(err, errStop) {
+				t.Fatalf("syncSource error = %v, want the discovery's error", err)
+			}
+			if src.start != tt.wantStart {
+				t.Errorf("discovery started at %d, want %d", src.start, tt.wantStart)
+			}
+			if err := mock.ExpectationsWereMet(); err != nil {
+				t.Error(err)
+			}
+		})
+	}
+}
@@ -0,0 +1,99 @@
+package catalog
+
+import (
+	"context"
+	"database/sql"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/ahasunos/caas/backend/internal/db"
+	"github.com/ahasunos/caas/backend/internal/models"
+	"github.com/ahasunos/caas/backend/internal/sources"
+)
+
+// profileRow returns a catalog profile row of the given source.
+func profileRow(url, source string) *sqlmock.Rows {
+	return sqlmock.NewRows([]string{"id", "name", "url", "description", "stars", "commit_sha", "metadata", "repo_id", "source",
+		"owner", "fork", "parent_url", "fingerprint", "canonical_id", "duplicates", "status", "status_reason",
+		"review_status", "reviewer", "review_notes", "reviewed_sha", "reviewed_at", "last_updated"}).
+		AddRow(1, "baseline", url, "", 0, "", nil, 0, source,
+			"acme", false, "", "", 0, 0, models.ProfileActive, "",
+			"", "", "", "", nil, time.Now())
+}
+
+func TestCredentials(t *testing.T) {
+	const profileURL = "https://gitlab.example.com/acme/baseline"
+	gitlab := sources.NewGitLab(sources.GitLabOptions{Name: "gitlab", URL: "https://gitlab.example.com", Token: "secret"})
+	local := sources.NewGit(sources.GitOptions{Name: "local", Repositories: []string{"https://git.example.com/baseline.git"}, Token: "listed"}, nil)
+
+	tests := []struct {
+		name      string
+		url       string
+		rows      *sqlmock.Rows
+		wantToken string
+	}{
+		{name: "catalog profile of the source", url: profileURL, rows: profileRow(profileURL, "gitlab"), wantToken: "secret"},
+		{name: "catalog profile of another source", url: profileURL, rows: profileRow(profileURL, "supermarket")},
+		{name: "not in the catalog", url: profileURL},
+		{name: "listed repository", url: "https://git.example.com/baseline.git", wantToken: "listed"},
+		{name: "other host", url: "https://github.com/acme/baseline"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			conn, mock, err := sqlmock.New()
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer conn.Close()
+			db.Use(conn)
+
+			if tt.url == profileURL {
+				query := mock.ExpectQuery("FROM inspec_profiles WHERE url = ").WithArgs(profileURL, models.ProfileRemoved)
+				if tt.rows != nil {
+					query.WillReturnRows(tt.rows)
+				} else {
+					query.WillReturnError(sql.ErrNoRows)
+				}
+			}
+
+			s := NewSyncer(nil, []sources.ProfileSource{gitlab, local})
+			_, token, err := s.Credentials(context.Background(), tt.url)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if token != tt.wantToken {
+				t.Errorf("token = %q, want %q", token, tt.wantToken)
+			}
+			if err := mock.ExpectationsWereMet(); err != nil {
+				t.Error(err)
+			}
+		})
+	}
+}
+
+func TestCheckoutLeavesUnlistedRepositoriesToInSpec(t *testing.T) {
+	conn, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	db.Use(conn)
+
+	const profileURL = "https://gitlab.example.com/someone/private"
+	mock.ExpectQuery("FROM inspec_profiles WHERE url = ").
+		WithArgs(profileURL, models.ProfileRemoved).
+		WillReturnError(sql.ErrNoRows)
+
+	gitlab := sources.NewGitLab(sources.GitLabOptions{Name: "gitlab", URL: "https://gitlab.example.com", Token: "secret"})
+	co, err := NewSyncer(nil, []sources.ProfileSource{gitlab}).Checkout(context.Background(), profileURL, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if co.Path != profileURL || co.CommitSHA != "" {
+		t.Errorf("Checkout = %+v, want the URL handed to InSpec", co)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Error(err)
+	}
+}
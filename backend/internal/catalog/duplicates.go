@@ -0,0 +1,156 @@
+package catalog
+
+import (
+	"cmp"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+
+	"github.com/ahasunos/caas/backend/internal/db"
+	"github.com/ahasunos/caas/backend/internal/metadata"
+	"github.com/ahasunos/caas/backend/internal/models"
+)
+
+// fingerprint returns the SHA-256 hash of the inspec.yml of the profile in
+// dir followed by the sorted IDs of its controls. Forks and copies of a
+// profile that haven't changed it share its fingerprint.
+func fingerprint(dir string, list []models.Control) (string, error) {
+	data, err := os.ReadFile(filepath.Join(dir, metadata.FileName))
+	if err != nil {
+		return "", fmt.Errorf("failed to read %s: %v", metadata.FileName, err)
+	}
+
+	ids := make([]string, len(list))
+	for i, control := range list {
+		ids[i] = control.ID
+	}
+	slices.Sort(ids)
+
+	h := sha256.New()
+	h.Write(data)
+	for _, id := range ids {
+		h.Write([]byte("\x00" + id))
+	}
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
+
+// groupDuplicates groups every fork and duplicate in the catalog under a
+// canonical profile. Forks are grouped with the profile of the repository
+// they were forked from, if it is in the catalog, and profiles with the
+// same fingerprint with each other. The canonical profile of a group is the
+// one most likely to be the original: active before archived or removed,
+// not a fork, most stars and oldest.
+func (s *Syncer) groupDuplicates() error {
+	profiles, err := db.ListAllProfiles()
+	if err != nil {
+		return err
+	}
+
+	canonical := canonicalProfiles(profiles)
+	changed := 0
+	for _, profile := range profiles {
+		if canonical[profile.ID] == profile.CanonicalID {
+			continue
+		}
+		if err := db.SetProfileCanonical(profile.ID, canonical[profile.ID]); err != nil {
+			return err
+		}
+		changed++
+	}
+	if changed > 0 {
+		log.Printf("Regrouped %d profiles as forks or duplicates", changed)
+	}
+	return nil
+}
+
+// canonicalProfiles returns the ID of the canonical profile of each profile
+// that is grouped under another one.
+func canonicalProfiles(profiles []models.Profile) map[int]int {
+	// Union-find over the indexes of profiles
+	parent := make([]int, len(profiles))
+	for i := range parent {
+		parent[i] = i
+	}
+	var find func(i int) int
+	find = func(i int) int {
+		if parent[i] != i {
+			parent[i] = find(parent[i])
+		}
+		return parent[i]
+	}
+	union := func(i, j int) {
+		parent[find(i)] = find(j)
+	}
+
+	byURL := make(map[string]int)
+	byFingerprint := make(map[string]int)
+	for i, profile := range profiles {
+		byURL[normalizeURL(profile.URL)] = i
+		if profile.Fingerprint == "" {
+			continue
+		}
+		if j, ok := byFingerprint[profile.Fingerprint]; ok {
+			union(i, j)
+		} else {
+			byFingerprint[profile.Fingerprint] = i
+		}
+	}
+	for i, profile := range profiles {
+		if profile.ParentURL == "" {
+			continue
+		}
+		if j, ok := byURL[normalizeURL(profile.ParentURL)]; ok {
+			union(i, j)
+		}
+	}
+
+	groups := make(map[int][]models.Profile)
+	for i, profile := range profiles {
+		root := find(i)
+		groups[root] = append(groups[root], profile)
+	}
+	canonical := make(map[int]int)
+	for _, group := range groups {
+		if len(group) < 2 {
+			continue
+		}
+		best := slices.MinFunc(group, compareCanonical)
+		for _, profile := range group {
+			if profile.ID != best.ID {
+				canonical[profile.ID] = best.ID
+			}
+		}
+	}
+	return canonical
+}
+
+// compareCanonical orders profiles by how likely they are the original of
+// a group of duplicates.
+func compareCanonical(a, b models.Profile) int {
+	rank := func(status string) int {
+		return slices.Index([]string{models.ProfileActive, models.ProfileArchived, models.ProfileRemoved}, status)
+	}
+	fork := func(p models.Profile) int {
+		if p.Fork {
+			return 1
+		}
+		return 0
+	}
+	return cmp.Or(
+		cmp.Compare(rank(a.Status), rank(b.Status)),
+		cmp.Compare(fork(a), fork(b)),
+		cmp.Compare(b.Stars, a.Stars),
+		cmp.Compare(a.ID, b.ID),
+	)
+}
+
+// normalizeURL returns a repository URL in a form that matches the other
+// URLs of the same repository.
+func normalizeURL(url string) string {
+	return strings.TrimSuffix(strings.TrimSuffix(strings.ToLower(url), "/"), ".git")
+}
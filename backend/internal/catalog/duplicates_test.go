@@ -0,0 +1,101 @@
+package catalog
+
+import (
+	"maps"
+	"testing"
+
+	"github.com/ahasunos/caas/backend/internal/models"
+)
+
+func TestCanonicalProfiles(t *testing.T) {
+	active := models.ProfileActive
+	tests := []struct {
+		name     string
+		profiles []models.Profile
+		want     map[int]int
+	}{
+		{
+			name: "unrelated",
+			profiles: []models.Profile{
+				{ID: 1, URL: "https://github.com/a/baseline", Fingerprint: "x", Status: active},
+				{ID: 2, URL: "https://github.com/b/baseline", Fingerprint: "y", Status: active},
+				{ID: 3, URL: "https://github.com/c/baseline", Status: active},
+				{ID: 4, URL: "https://github.com/d/baseline", Status: active},
+			},
+			want: map[int]int{},
+		},
+		{
+			name: "same fingerprint, most stars",
+			profiles: []models.Profile{
+				{ID: 1, URL: "https://github.com/a/baseline", Fingerprint: "x", Stars: 3, Status: active},
+				{ID: 2, URL: "https://github.com/b/baseline", Fingerprint: "x", Stars: 10, Status: active},
+				{ID: 3, URL: "https://github.com/c/baseline", Fingerprint: "x", Stars: 1, Status: active},
+			},
+			want: map[int]int{1: 2, 3: 2},
+		},
+		{
+			name: "fork of a differently written URL",
+			profiles: []models.Profile{
+				{ID: 1, URL: "https://github.com/dev-sec/linux-baseline", Stars: 5, Status: active},
+				{ID: 2, URL: "https://github.com/me/linux-baseline", Fork: true, ParentURL: "https://GitHub.com/dev-sec/linux-baseline.git/", Stars: 50, Status: active},
+			},
+			want: map[int]int{2: 1},
+		},
+		{
+			name: "fork with a parent outside the catalog",
+			profiles: []models.Profile{
+				{ID: 1, URL: "https://github.com/me/linux-baseline", Fork: true, ParentURL: "https://github.com/dev-sec/linux-baseline", Status: active},
+			},
+			want: map[int]int{},
+		},
+		{
+			name: "fingerprint and fork chained",
+			profiles: []models.Profile{
+				{ID: 1, URL: "https://github.com/a/baseline", Fingerprint: "x", Status: active},
+				{ID: 2, URL: "https://github.com/b/baseline", Fingerprint: "x", Status: active},
+				{ID: 3, URL: "https://github.com/c/baseline", Fork: true, ParentURL: "https://github.com/b/baseline", Fingerprint: "y", Status: active},
+			},
+			want: map[int]int{2: 1, 3: 1},
+		},
+		{
+			name: "active before archived before removed",
+			profiles: []models.Profile{
+				{ID: 1, URL: "https://github.com/a/baseline", Fingerprint: "x", Stars: 100, Status: models.ProfileRemoved},
+				{ID: 2, URL: "https://github.com/b/baseline", Fingerprint: "x", Stars: 50, Status: models.ProfileArchived},
+				{ID: 3, URL: "https://github.com/c/baseline", Fingerprint: "x", Fork: true, Status: active},
+			},
+			want: map[int]int{1: 3, 2: 3},
+		},
+		{
+			name: "archived before removed",
+			profiles: []models.Profile{
+				{ID: 1, URL: "https://github.com/a/baseline", Fingerprint: "x", Stars: 100, Status: models.ProfileRemoved},
+				{ID: 2, URL: "https://github.com/b/baseline", Fingerprint: "x", Status: models.ProfileArchived},
+			},
+			want: map[int]int{1: 2},
+		},
+		{
+			name: "not a fork before more stars",
+			profiles: []models.Profile{
+				{ID: 1, URL: "https://github.com/a/baseline", Fingerprint: "x", Fork: true, Stars: 100, Status: active},
+				{ID: 2, URL: "https://github.com/b/baseline", Fingerprint: "x", Stars: 1, Status: active},
+			},
+			want: map[int]int{1: 2},
+		},
+		{
+			name: "oldest on a tie",
+			profiles: []models.Profile{
+				{ID: 9, URL: "https://github.com/a/baseline", Fingerprint: "x", Stars: 4, Status: active},
+				{ID: 5, URL: "https://github.com/b/baseline", Fingerprint: "x", Stars: 4, Status: active},
+			},
+			want: map[int]int{9: 5},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := canonicalProfiles(tt.profiles); !maps.Equal(got, tt.want) {
+				t.Errorf("canonicalProfiles = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
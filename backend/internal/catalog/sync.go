@@ -0,0 +1,675 @@
+// Package catalog keeps the profile catalog in the database in step with the
+// profile repositories it lists.
+package catalog
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"log"
+	"math/rand/v2"
+	"net/url"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/ahasunos/caas/backend/internal/controls"
+	"github.com/ahasunos/caas/backend/internal/cron"
+	"github.com/ahasunos/caas/backend/internal/db"
+	"github.com/ahasunos/caas/backend/internal/github"
+	"github.com/ahasunos/caas/backend/internal/metadata"
+	"github.com/ahasunos/caas/backend/internal/models"
+	"github.com/ahasunos/caas/backend/internal/profilecache"
+	"github.com/ahasunos/caas/backend/internal/sources"
+)
+
+// resolveTimeout bounds how long looking up the latest commit of one
+// repository may take.
+const resolveTimeout = time.Minute
+
+// indexTimeout bounds how long fetching a profile to index its controls may
+// take.
+const indexTimeout = 5 * time.Minute
+
+// checkpointMaxAge is how long an interrupted sync may be resumed. Older
+// checkpoints are ignored, as the search results will have moved on.
+const checkpointMaxAge = 24 * time.Hour
+
+// runningLookups is how often Start looks for the run of a sync another
+// instance holds the lock of, which it records just after taking the lock,
+// waiting runningLookupDelay between lookups.
+const (
+	runningLookups     = 5
+	runningLookupDelay = 100 * time.Millisecond
+)
+
+// Syncer updates the catalog from the profile sources it is configured with.
+type Syncer struct {
+	cache   *profilecache.Cache
+	sources []sources.ProfileSource
+}
+
+// NewSyncer returns a Syncer that discovers profiles on srcs and refreshes
+// checkouts held by cache when a profile's repository moves to a new
+// commit.
+func NewSyncer(cache *profilecache.Cache, srcs []sources.ProfileSource) *Syncer {
+	return &Syncer{cache: cache, sources: srcs}
+}
+
+// Source returns the source with the given name.
+func (s *Syncer) Source(name string) (sources.ProfileSource, bool) {
+	for _, src := range s.sources {
+		if src.Name() == name {
+			return src, true
+		}
+	}
+	return nil, false
+}
+
+// GitHub returns the client of the GitHub host repoURL is on.
+func (s *Syncer) GitHub(repoURL string) (*github.Client, bool) {
+	for _, src := range s.sources {
+		if gh, ok := src.(*sources.GitHub); ok && gh.Owns(repoURL) {
+			return gh.Client(), true
+		}
+	}
+	return nil, false
+}
+
+// gitSource returns the git source repoURL is on.
+func (s *Syncer) gitSource(repoURL string) (sources.ProfileSource, bool) {
+	for _, src := range s.sources {
+		if src.Git() && src.Owns(repoURL) {
+			return src, true
+		}
+	}
+	return nil, false
+}
+
+// listed reports whether src names its repositories one by one, rather
+// than owning every repository on a host.
+func listed(src sources.ProfileSource) bool {
+	_, ok := src.(*sources.Git)
+	return ok
+}
+
+// Credentials returns the credentials of the source repoURL belongs to, so
+// that private repositories can be checked out. It implements
+// profilecache.Credentials. Sources owning a whole host only lend their
+// credentials to the catalog profiles they discovered; any other repository
+// on the host, which callers can ask to run, is fetched without them.
+func (s *Syncer) Credentials(ctx context.Context, repoURL string) (string, string, error) {
+	src, ok := s.gitSource(repoURL)
+	if !ok {
+		return "", "", nil
+	}
+	if !listed(src) {
+		profile, err := db.GetProfileByURL(repoURL)
+		if errors.Is(err, sql.ErrNoRows) || err == nil && profile.Source != src.Name() {
+			return "", "", nil
+		} else if err != nil {
+			return "", "", err
+		}
+	}
+	return src.Credentials(ctx, repoURL)
+}
+
+// Stats summarizes a catalog sync.
+type Stats struct {
+	sources.Stats
+	// Added, Updated and Removed count the profiles inserted into, updated
+	// in and removed from the catalog.
+	Added   int
+	Updated int
+	Removed int
+	// Duration is how long the sync took.
+	Duration time.Duration
+}
+
+func (s *Stats) add(other Stats) {
+	s.Pages += other.Pages
+	s.Repositories += other.Repositories
+	s.Duplicates += other.Duplicates
+	s.Excluded += other.Excluded
+	s.Profiles += other.Profiles
+	s.Added += other.Added
+	s.Updated += other.Updated
+	s.Removed += other.Removed
+}
+
+func (s Stats) String() string {
+	return fmt.Sprintf("%d pages, %d repositories (%d duplicates, %d excluded skipped), %d profiles found, %d added, %d updated, %d removed in %s",
+		s.Pages, s.Repositories, s.Duplicates, s.Excluded, s.Profiles, s.Added, s.Updated, s.Removed, s.Duration.Round(time.Millisecond))
+}
+
+// ErrSyncRunning is returned by Start when a sync is already running.
+var ErrSyncRunning = errors.New("a catalog sync is already running")
+
+// ErrUnknownSource is returned by Start for a source that isn't configured.
+var ErrUnknownSource = errors.New("unknown catalog source")
+
+// errSyncDone is returned by start when the scheduled sync already ran.
+var errSyncDone = errors.New("scheduled catalog sync already ran")
+
+// Start starts a sync of the named sources, or of all sources if none are
+// named, in the background and returns its run, which is recorded in the
+// database and updated once the sync finishes. Only one sync runs at a
+// time, across all instances sharing the database: if one is already
+// running, Start returns it along with ErrSyncRunning. The returned run has
+// no ID if the running sync hasn't been recorded yet.
+func (s *Syncer) Start(trigger string, names ...string) (models.SyncRun, error) {
+	return s.start(trigger, names, time.Time{})
+}
+
+// start starts a sync like Start. If slot is set, the sync is skipped with
+// errSyncDone if a sync of the same sources with the same trigger started
+// at or after slot, so that a schedule firing on several instances syncs
+// only once.
+func (s *Syncer) start(trigger string, names []string, slot time.Time) (models.SyncRun, error) {
+	for _, name := range names {
+		if _, ok := s.Source(name); !ok {
+			return models.SyncRun{}, fmt.Errorf("%w: %s", ErrUnknownSource, name)
+		}
+	}
+
+	release, ok, err := db.TryLockSync()
+	if err != nil {
+		return models.SyncRun{}, err
+	}
+	if !ok {
+		for attempt := 1; ; attempt++ {
+			run, err := db.GetRunningSyncRun()
+			if errors.Is(err, sql.ErrNoRows) && attempt < runningLookups {
+				time.Sleep(runningLookupDelay)
+				continue
+			}
+			if err != nil && !errors.Is(err, sql.ErrNoRows) {
+				return models.SyncRun{}, err
+			}
+			return run, ErrSyncRunning
+		}
+	}
+
+	if !slot.IsZero() {
+		done, err := db.HasSyncRunSince(trigger, names, slot)
+		if err != nil || done {
+			release()
+			if err == nil {
+				err = errSyncDone
+			}
+			return models.SyncRun{}, err
+		}
+	}
+
+	run := models.SyncRun{Trigger: trigger, Sources: names}
+	if err := db.CreateSyncRun(&run); err != nil {
+		release()
+		return models.SyncRun{}, err
+	}
+
+	go func() {
+		defer release()
+		s.run(run)
+	}()
+	return run, nil
+}
+
+// scheduleRetry is how long a scheduled sync that found another sync
+// running waits before trying again.
+const scheduleRetry = time.Minute
+
+// Schedule starts a sync of the named sources, or of all sources if none are
+// named, whenever schedule fires, delayed by a random duration of up to
+// jitter, until ctx is cancelled. The jitter spreads the load of instances
+// sharing a schedule; only the first of them to start syncs, the others
+// find the sync done and skip it. A sync that finds another one running is
+// retried until the schedule fires again. Scheduled syncs are recorded like
+// any other, with the trigger "scheduled".
+func (s *Syncer) Schedule(ctx context.Context, schedule *cron.Schedule, jitter time.Duration, names ...string) {
+	desc := "catalog sync"
+	if len(names) > 0 {
+		desc = fmt.Sprintf("catalog sync of %s", strings.Join(names, ", "))
+	}
+
+	slot := schedule.Next(time.Now())
+	for ; ; slot = nextSlot(schedule, slot, time.Now()) {
+		if slot.IsZero() {
+			log.Printf("Schedule %q of %s never fires, scheduled syncs are disabled", schedule, desc)
+			return
+		}
+		delay := time.Until(slot)
+		if jitter > 0 {
+			delay += rand.N(jitter)
+		}
+		log.Printf("Next scheduled %s at %s", desc, time.Now().Add(delay).Format(time.RFC3339))
+
+		for {
+			if sleep(ctx, delay) != nil {
+				return
+			}
+
+			run, err := s.start(models.SyncTriggerScheduled, names, slot)
+			if errors.Is(err, ErrSyncRunning) && time.Now().Add(scheduleRetry).Before(schedule.Next(slot)) {
+				log.Printf("Another sync is running, retrying scheduled %s in %s", desc, scheduleRetry)
+				delay = scheduleRetry
+				continue
+			}
+
+			switch {
+			case errors.Is(err, errSyncDone), errors.Is(err, ErrSyncRunning):
+				log.Printf("Skipping scheduled %s of %s, another instance ran it or a sync is still running", desc, slot.Format(time.RFC3339))
+			case err != nil:
+				log.Printf("Error starting scheduled %s: %v", desc, err)
+			default:
+				log.Printf("Started scheduled %s %d", desc, run.ID)
+			}
+			break
+		}
+	}
+}
+
+// nextSlot returns the slot of schedule following prev. Computing it from
+// the slot that ran rather than from now keeps a jitter delay or a retry that
+// ran past the next slot from skipping it. Of slots that have all passed by
+// now only the latest is returned, so a long outage doesn't replay each one.
+func nextSlot(schedule *cron.Schedule, prev, now time.Time) time.Time {
+	slot := schedule.Next(prev)
+	for !slot.IsZero() && slot.Before(now) {
+		next := schedule.Next(slot)
+		if next.IsZero() || next.After(now) {
+			break
+		}
+		slot = next
+	}
+	return slot
+}
+
+func sleep(ctx context.Context, d time.Duration) error {
+	t := time.NewTimer(d)
+	defer t.Stop()
+	select {
+	case <-t.C:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// run performs a sync and records its outcome in run.
+func (s *Syncer) run(run models.SyncRun) {
+	log.Printf("Catalog sync %d started (%s)", run.ID, run.Trigger)
+	var stats Stats
+	err := func() (err error) {
+		// A panic must not leave the run marked as running
+		defer func() {
+			if r := recover(); r != nil {
+				err = fmt.Errorf("sync panicked: %v", r)
+			}
+		}()
+		stats, err = s.syncAll(run.Sources)
+		return err
+	}()
+
+	run.Status = models.SyncSucceeded
+	if err != nil {
+		run.Status = models.SyncFailed
+		run.Error = err.Error()
+	}
+	run.Pages = stats.Pages
+	run.Repositories = stats.Repositories
+	run.Duplicates = stats.Duplicates
+	run.Excluded = stats.Excluded
+	run.Discovered = stats.Profiles
+	run.Added = stats.Added
+	run.Updated = stats.Updated
+	run.Removed = stats.Removed
+	if err := db.FinishSyncRun(run); err != nil {
+		log.Println("Error recording sync run:", err)
+	}
+}
+
+// syncAll fetches profiles from the named sources, or from every source if
+// none are named, updates or inserts them in the database, records the
+// latest commit of each and indexes their controls, and then groups forks
+// and duplicates. A source that fails doesn't stop the others from being
+// synced. The returned stats include the work done before any failure.
+func (s *Syncer) syncAll(names []string) (Stats, error) {
+	start := time.Now()
+	var total Stats
+	var errs []error
+	for _, src := range s.sources {
+		if len(names) > 0 && !slices.Contains(names, src.Name()) {
+			continue
+		}
+		stats, err := s.syncSource(src)
+		total.add(stats)
+		if err != nil {
+			log.Printf("Error syncing profiles from %s after %s: %v", src.Name(), stats, err)
+			errs = append(errs, fmt.Errorf("%s: %w", src.Name(), err))
+			continue
+		}
+		log.Printf("Synced profiles from %s: %s", src.Name(), stats)
+	}
+	if err := s.groupDuplicates(); err != nil {
+		log.Println("Error grouping duplicate profiles:", err)
+		errs = append(errs, err)
+	}
+	total.Duration = time.Since(start)
+	log.Printf("Catalog sync finished: %s", total)
+	return total, errors.Join(errs...)
+}
+
+// syncSource syncs the profiles of one source. Progress of sources that can
+// resume discovery is checkpointed after every batch, so a sync that
+// fails, for example on GitHub's rate limit, resumes where it stopped. Once
+// discovery completes, the catalog profiles of the source that it didn't
+// return are pruned.
+func (s *Syncer) syncSource(src sources.ProfileSource) (Stats, error) {
+	ctx := context.Background()
+	began := time.Now()
+	var stats Stats
+
+	// since is when the pass over the source started, which is earlier
+	// than began if an interrupted pass is resumed
+	start, since := 1, began
+	var key string
+	if r, ok := src.(sources.Resumable); ok {
+		key = r.CheckpointKey()
+	}
+	if cp, err := db.GetSyncCheckpoint(src.Name()); err != nil {
+		log.Println("Error getting sync checkpoint:", err)
+	} else if cp.Page > 1 && cp.Key != key {
+		// The page size or listings changed, so the page points elsewhere
+		log.Printf("Discarding sync checkpoint of %s, which was taken with other discovery settings", src.Name())
+	} else if cp.Page > 1 && time.Since(cp.UpdatedAt) < checkpointMaxAge {
+		log.Printf("Resuming sync of %s at page %d", src.Name(), cp.Page)
+		start, since = cp.Page, cp.StartedAt
+	}
+
+	discovery, err := src.Discover(ctx, start, func(next int, profiles []models.Profile) error {
+		// Update or insert profiles in database
+		for _, profile := range profiles {
+			if err := s.store(src, profile, &stats); err != nil {
+				return err
+			}
+		}
+		if next == 0 {
+			return nil
+		}
+		return db.SaveSyncCheckpoint(src.Name(), next, key, since)
+	})
+	stats.Stats = discovery
+	if err != nil {
+		stats.Duration = time.Since(began)
+		return stats, err
+	}
+	if err := db.ClearSyncCheckpoint(src.Name()); err != nil {
+		stats.Duration = time.Since(began)
+		return stats, err
+	}
+
+	err = s.prune(ctx, src, since, &stats)
+	stats.Duration = time.Since(began)
+	return stats, err
+}
+
+// store updates or inserts a profile of src in the catalog and updates the
+// commit and controls of git profiles.
+func (s *Syncer) store(src sources.ProfileSource, profile models.Profile, stats *Stats) error {
+	profile.Source = src.Name()
+	added, err := db.UpsertProfile(&profile)
+	if err != nil {
+		return err
+	}
+	if added {
+		stats.Added++
+	} else {
+		stats.Updated++
+	}
+	if src.Git() {
+		if sha := s.updateCommit(profile); sha != "" {
+			s.indexControls(profile, sha)
+		}
+	}
+	return nil
+}
+
+// prune reconciles the profiles of src that discovery didn't return since
+// the pass over the source started. Discovery may not cover every profile
+// of a source, for example search results are capped, so each profile is
+// checked on its own: renamed and transferred repositories are followed,
+// archived ones are marked as such, and profiles that are gone from the
+// source are removed.
+func (s *Syncer) prune(ctx context.Context, src sources.ProfileSource, since time.Time, stats *Stats) error {
+	if err := s.claim(src); err != nil {
+		return err
+	}
+	profiles, err := db.ListUnseenProfiles(src.Name(), since)
+	if err != nil {
+		return err
+	}
+
+	for _, old := range profiles {
+		var goneErr *sources.GoneError
+		profile, err := src.Check(ctx, old)
+		if errors.As(err, &goneErr) {
+			err = s.remove(old, goneErr.Reason, stats)
+		} else if err == nil {
+			err = s.update(src, old, profile, stats)
+		}
+
+		if errors.Is(err, github.ErrRateLimited) {
+			return err
+		}
+		if err != nil {
+			log.Printf("Could not check profile %s: %v", old.URL, err)
+		}
+	}
+	return nil
+}
+
+// claim tags the profiles of src that were added before sources were
+// recorded with its name.
+func (s *Syncer) claim(src sources.ProfileSource) error {
+	profiles, err := db.ListProfilesWithoutSource()
+	if err != nil {
+		return err
+	}
+	for _, profile := range profiles {
+		if src.Owns(profile.URL) {
+			if err := db.SetProfileSource(profile.ID, src.Name()); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+func (s *Syncer) remove(profile models.Profile, reason string, stats *Stats) error {
+	if err := db.RemoveProfile(profile.ID, reason); err != nil {
+		return err
+	}
+	log.Printf("Removed profile %s: %s", profile.URL, reason)
+	stats.Removed++
+	return nil
+}
+
+// update stores the current state of a profile looked up on its own.
+func (s *Syncer) update(src sources.ProfileSource, old, profile models.Profile, stats *Stats) error {
+	// Profiles stored before repository IDs were recorded can only be
+	// matched to their renamed repository by URL
+	if old.RepoID == 0 && profile.RepoID != 0 {
+		if err := db.SetProfileRepoID(old.ID, profile.RepoID); err != nil {
+			return err
+		}
+	}
+	return s.store(src, profile, stats)
+}
+
+// updateCommit records the latest commit of a profile's repository and
+// returns it, or an empty string if it could not be determined. If the
+// profile moved to a new commit and an older checkout is cached, the new
+// commit is fetched right away.
+func (s *Syncer) updateCommit(profile models.Profile) string {
+	ctx, cancel := context.WithTimeout(context.Background(), resolveTimeout)
+	defer cancel()
+
+	sha, err := s.cache.ResolveRef(ctx, profile.URL, "")
+	if err != nil {
+		log.Printf("Could not resolve latest commit of %s: %v", profile.URL, err)
+		return ""
+	}
+	if sha == profile.CommitSHA {
+		return sha
+	}
+
+	if err := db.SetProfileCommit(profile.ID, sha); err != nil {
+		log.Println("Error updating profile commit:", err)
+		return ""
+	}
+	if err := s.cache.Refresh(ctx, profile.URL, sha); err != nil {
+		log.Printf("Could not refresh cached checkout of %s: %v", profile.URL, err)
+	}
+	return sha
+}
+
+// indexControls reads the controls of a profile at commit sha from its
+// cached checkout and stores them along with the profile's fingerprint,
+// unless they were already indexed at that commit.
+func (s *Syncer) indexControls(profile models.Profile, sha string) {
+	indexed, err := db.GetControlsCommit(profile.ID)
+	if err != nil {
+		log.Println("Error getting indexed controls commit:", err)
+		return
+	}
+	// Profiles indexed before fingerprints were recorded are indexed again
+	if indexed == sha && profile.Fingerprint != "" {
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), indexTimeout)
+	defer cancel()
+
+	path, release, err := s.cache.Get(ctx, profile.URL, sha)
+	if err != nil {
+		log.Printf("Could not check out %s to index its controls: %v", profile.URL, err)
+		return
+	}
+	defer release()
+
+	list, err := controls.ReadDir(path)
+	if err != nil {
+		log.Printf("Could not read controls of %s: %v", profile.URL, err)
+		return
+	}
+	sum, err := fingerprint(path, list)
+	if err != nil {
+		log.Printf("Could not fingerprint %s: %v", profile.URL, err)
+	}
+	if err := db.ReplaceProfileControls(profile.ID, sha, sum, list); err != nil {
+		log.Println("Error storing profile controls:", err)
+		return
+	}
+	log.Printf("Indexed %d controls of %s at %s", len(list), profile.URL, sha)
+}
+
+// Checkout is a profile ready to be handed to an executor.
+type Checkout struct {
+	// Path is a local directory, or the original profile location for
+	// profiles that are left to InSpec to fetch.
+	Path string
+	// CommitSHA is the commit that was checked out, if known.
+	CommitSHA string
+	// Version is the version declared in the profile's inspec.yml, if known.
+	Version string
+
+	release func()
+}
+
+// Release allows the checkout to be evicted from the cache again.
+func (c Checkout) Release() {
+	if c.release != nil {
+		c.release()
+	}
+}
+
+// ResolveRef returns the commit SHA a branch, tag or commit SHA of the git
+// repository at repoURL refers to.
+func (s *Syncer) ResolveRef(ctx context.Context, repoURL, ref string) (string, error) {
+	if err := s.checkRepoURL(repoURL); err != nil {
+		return "", err
+	}
+	return s.cache.ResolveRef(ctx, repoURL, ref)
+}
+
+// remoteSchemes are the schemes of the repository URLs callers may have
+// the cache fetch from. Others, such as file and ext, would let them read
+// files of or run commands on the server.
+var remoteSchemes = []string{"https", "ssh", "git"}
+
+// checkRepoURL returns an error if the repository at repoURL, given by a
+// caller, may not be fetched. Repositories of the configured sources can
+// always be fetched, even from local disk.
+func (s *Syncer) checkRepoURL(repoURL string) error {
+	if _, ok := s.gitSource(repoURL); ok {
+		return nil
+	}
+	u, err := url.Parse(repoURL)
+	if err != nil || !slices.Contains(remoteSchemes, u.Scheme) || u.Host == "" || strings.HasPrefix(u.Host, "-") {
+		return fmt.Errorf("%s is not an https, ssh or git repository URL", repoURL)
+	}
+	return nil
+}
+
+// Checkout returns a local checkout of profileURL at commit sha. Without a
+// commit, catalog profiles from git sources are checked out at their latest
+// known commit, repositories listed by git sources at their default branch,
+// and any other profile is returned unchanged, leaving it to InSpec to
+// fetch it.
+// The caller must release the checkout once it is no longer needed.
+func (s *Syncer) Checkout(ctx context.Context, profileURL, sha string) (Checkout, error) {
+	if sha == "" {
+		profile, err := db.GetProfileByURL(profileURL)
+		if errors.Is(err, sql.ErrNoRows) {
+			if src, ok := s.gitSource(profileURL); !ok || !listed(src) {
+				return Checkout{Path: profileURL}, nil
+			}
+			// InSpec has no credentials for the listed repositories, so
+			// fetch them through the cache
+			profile = models.Profile{URL: profileURL}
+		} else if err != nil {
+			return Checkout{}, err
+		} else if src, ok := s.Source(profile.Source); ok && !src.Git() {
+			return Checkout{Path: profileURL}, nil
+		}
+
+		sha = profile.CommitSHA
+		if sha == "" {
+			if sha, err = s.cache.ResolveRef(ctx, profile.URL, ""); err != nil {
+				return Checkout{}, err
+			}
+			if profile.ID != 0 {
+				if err := db.SetProfileCommit(profile.ID, sha); err != nil {
+					log.Println("Error updating profile commit:", err)
+				}
+			}
+		}
+	}
+
+	if err := s.checkRepoURL(profileURL); err != nil {
+		return Checkout{}, err
+	}
+	path, release, err := s.cache.Get(ctx, profileURL, sha)
+	if err != nil {
+		return Checkout{}, err
+	}
+
+	co := Checkout{Path: path, CommitSHA: sha, release: release}
+	if meta, err := metadata.ReadDir(path); err == nil {
+		co.Version = meta.Version
+	} else {
+		log.Printf("Could not read metadata of %s at %s: %v", profileURL, sha, err)
+	}
+	return co, nil
+}
@@ -0,0 +1,86 @@
+package catalog
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/ahasunos/caas/backend/internal/cron"
+	"github.com/ahasunos/caas/backend/internal/profilecache"
+	"github.com/ahasunos/caas/backend/internal/sources"
+)
+
+func TestResolveRefRejectsRepositoryURLs(t *testing.T) {
+	cache, err := profilecache.New(t.TempDir(), 1<<20)
+	if err != nil {
+		t.Fatal(err)
+	}
+	s := NewSyncer(cache, nil)
+
+	for _, repoURL := range []string{
+		"--upload-pack=touch /tmp/pwned",
+		"-c",
+		"file:///etc",
+		"/srv/git/profile.git",
+		"ext::sh -c touch% /tmp/pwned",
+		"git@github.com:dev-sec/linux-baseline.git",
+		"http://github.com/dev-sec/linux-baseline",
+		"https:///dev-sec/linux-baseline",
+		"ssh://-oProxyCommand=touch /tmp/pwned/repo",
+	} {
+		_, err := s.ResolveRef(context.Background(), repoURL, "main")
+		if err == nil || !strings.Contains(err.Error(), "is not an https, ssh or git repository URL") {
+			t.Errorf("ResolveRef(%q) error = %v, want the URL rejected", repoURL, err)
+		}
+	}
+}
+
+func TestCheckRepoURL(t *testing.T) {
+	local := sources.NewGit(sources.GitOptions{Name: "local", Repositories: []string{"file:///srv/git/baseline.git"}}, nil)
+	s := NewSyncer(nil, []sources.ProfileSource{local})
+	for _, repoURL := range []string{
+		// Configured by the operator rather than a caller
+		"file:///srv/git/baseline.git",
+		"https://github.com/dev-sec/linux-baseline",
+		"https://gitlab.example.com/compliance/baseline.git",
+		"ssh://git@github.com/dev-sec/linux-baseline.git",
+		"git://git.example.com/baseline.git",
+	} {
+		if err := s.checkRepoURL(repoURL); err != nil {
+			t.Errorf("checkRepoURL(%q): %v", repoURL, err)
+		}
+	}
+}
+
+func TestNextSlot(t *testing.T) {
+	schedule, err := cron.Parse("0 * * * *")
+	if err != nil {
+		t.Fatal(err)
+	}
+	prev := time.Date(2025, 6, 1, 10, 0, 0, 0, time.UTC)
+	tests := []struct {
+		name string
+		now  time.Time
+		want time.Time
+	}{
+		{name: "before the next slot", now: prev.Add(5 * time.Minute), want: prev.Add(time.Hour)},
+		{name: "jitter ran past the next slot", now: prev.Add(time.Hour + time.Minute), want: prev.Add(time.Hour)},
+		{name: "several slots missed", now: prev.Add(3*time.Hour + time.Minute), want: prev.Add(3 * time.Hour)},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := nextSlot(schedule, prev, tt.now); !got.Equal(tt.want) {
+				t.Errorf("nextSlot = %s, want %s", got, tt.want)
+			}
+		})
+	}
+
+	never, err := cron.Parse("0 0 30 2 *")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := nextSlot(never, prev, prev); !got.IsZero() {
+		t.Errorf("nextSlot of a schedule that never fires = %s, want zero", got)
+	}
+}
@@ -0,0 +1,225 @@
+// Package config loads service settings from environment variables.
+package config
+
+import (
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Config holds the settings the service reads at startup.
+type Config struct {
+	// MaxConcurrentScans caps how many InSpec executions run at the same time.
+	MaxConcurrentScans int
+	// ScanTimeout is how long a scan may run when the request sets no timeout.
+	ScanTimeout time.Duration
+	// MaxScanTimeout is the longest timeout a request may ask for.
+	MaxScanTimeout time.Duration
+	// Engines maps the names of the scan engines requests may choose from
+	// to the binary that runs them.
+	Engines map[string]string
+	// DefaultEngine is the engine used when a request doesn't name one.
+	DefaultEngine string
+	// ChefLicenseKey is passed to Chef InSpec engines. It is read from
+	// CHEF_LICENSE_KEY or from the file named by CHEF_LICENSE_KEY_FILE.
+	ChefLicenseKey string
+	// ProfileCacheDir is where checkouts of catalog profiles are kept.
+	ProfileCacheDir string
+	// ProfileCacheMaxBytes bounds the size of the profile cache.
+	ProfileCacheMaxBytes int64
+	// Sources are the GitHub and GitHub Enterprise Server hosts, GitLab
+	// instances, git repositories, Supermarkets and local directories the
+	// catalog is populated from. They are read from the file named by
+	// CATALOG_SOURCES_FILE; without it, a single GitHub host is configured
+	// by the GITHUB_* variables.
+	Sources Sources
+	// GitHubPageSize is the number of search results requested per page
+	// during discovery, at most 100.
+	GitHubPageSize int
+	// GitHubWorkers bounds how many repositories discovery checks for an
+	// inspec.yml file at the same time.
+	GitHubWorkers int
+	// GitHubMaxRetries bounds how often a failing GitHub request is retried.
+	GitHubMaxRetries int
+	// GitHubMaxWait is the longest a sync waits for a GitHub rate limit to
+	// reset. A sync that would have to wait longer stops and resumes where
+	// it left off the next time it runs.
+	GitHubMaxWait time.Duration
+	// SyncSchedule is the cron expression on which the catalog is synced,
+	// in the server's time zone. It is empty if scheduled syncs are
+	// disabled.
+	SyncSchedule string
+	// SyncJitter is the longest a scheduled sync is delayed by, at random,
+	// to spread the load of replicas sharing the schedule.
+	SyncJitter time.Duration
+	// AdminToken is the bearer token of the admin API, which reviews
+	// catalog profiles. It is read from ADMIN_TOKEN or from the file named
+	// by ADMIN_TOKEN_FILE; the admin API is disabled without it.
+	AdminToken string
+	// RequireApprovedProfiles refuses to execute profiles that aren't
+	// approved catalog profiles.
+	RequireApprovedProfiles bool
+	// InstanceID identifies the replica that runs a job, so that a replica
+	// restarting only fails the jobs it lost. It must be unique among the
+	// replicas sharing a database and stay the same across restarts; it
+	// defaults to the host name.
+	InstanceID string
+}
+
+// Load reads the configuration from the environment, falling back to
+// defaults for unset or invalid values.
+func Load() Config {
+	cfg := Config{
+		MaxConcurrentScans:   getInt("MAX_CONCURRENT_SCANS", 4),
+		ScanTimeout:          getDuration("SCAN_TIMEOUT", 30*time.Minute),
+		MaxScanTimeout:       getDuration("MAX_SCAN_TIMEOUT", 2*time.Hour),
+		Engines:              getMap("SCAN_ENGINES", "inspec=inspec,cinc-auditor=cinc-auditor"),
+		DefaultEngine:        getString("SCAN_ENGINE", "inspec"),
+		ChefLicenseKey:       getSecret("CHEF_LICENSE_KEY"),
+		ProfileCacheDir:      getString("PROFILE_CACHE_DIR", filepath.Join(os.TempDir(), "caas-profile-cache")),
+		ProfileCacheMaxBytes: int64(getInt("PROFILE_CACHE_MAX_MB", 1024)) << 20,
+
+		GitHubPageSize:   getInt("GITHUB_PAGE_SIZE", 100),
+		GitHubWorkers:    getInt("GITHUB_WORKERS", 8),
+		GitHubMaxRetries: getInt("GITHUB_MAX_RETRIES", 5),
+		GitHubMaxWait:    getDuration("GITHUB_MAX_WAIT", 15*time.Minute),
+
+		SyncSchedule: getString("SYNC_SCHEDULE", "0 3 * * *"),
+		SyncJitter:   getDuration("SYNC_JITTER", 10*time.Minute),
+
+		AdminToken:              getSecret("ADMIN_TOKEN"),
+		RequireApprovedProfiles: getBool("REQUIRE_APPROVED_PROFILES", true),
+		InstanceID:              getString("INSTANCE_ID", hostname()),
+	}
+	if cfg.SyncSchedule == "off" {
+		cfg.SyncSchedule = ""
+	}
+	cfg.Sources = loadSources()
+	if cfg.GitHubPageSize > 100 {
+		log.Printf("GITHUB_PAGE_SIZE=%d exceeds GitHub's limit, using 100", cfg.GitHubPageSize)
+		cfg.GitHubPageSize = 100
+	}
+	if _, ok := cfg.Engines[cfg.DefaultEngine]; !ok {
+		log.Fatalf("SCAN_ENGINE %q is not one of SCAN_ENGINES", cfg.DefaultEngine)
+	}
+	if err := checkTimeouts(cfg.ScanTimeout, cfg.MaxScanTimeout); err != nil {
+		log.Fatal(err)
+	}
+	return cfg
+}
+
+// checkTimeouts returns an error if the scan timeouts can't be recorded
+// with jobs, which hold them in whole seconds, or if the default timeout
+// exceeds the longest one requests may ask for.
+func checkTimeouts(timeout, max time.Duration) error {
+	for key, d := range map[string]time.Duration{"SCAN_TIMEOUT": timeout, "MAX_SCAN_TIMEOUT": max} {
+		if d%time.Second != 0 {
+			return fmt.Errorf("%s=%s is not a whole number of seconds", key, d)
+		}
+	}
+	if timeout > max {
+		return fmt.Errorf("SCAN_TIMEOUT=%s exceeds MAX_SCAN_TIMEOUT=%s", timeout, max)
+	}
+	return nil
+}
+
+// hostname returns the host name, or an empty string if it is unknown.
+func hostname() string {
+	name, err := os.Hostname()
+	if err != nil {
+		log.Printf("Could not get the host name: %v", err)
+	}
+	return name
+}
+
+func getString(key, fallback string) string {
+	if value := os.Getenv(key); value != "" {
+		return value
+	}
+	return fallback
+}
+
+// getList parses a comma-separated list, ignoring empty entries.
+func getList(key string) []string {
+	var list []string
+	for _, item := range strings.Split(os.Getenv(key), ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			list = append(list, item)
+		}
+	}
+	return list
+}
+
+// getMap parses a comma-separated list of name=value pairs.
+func getMap(key, fallback string) map[string]string {
+	value := getString(key, fallback)
+	m := make(map[string]string)
+	for _, pair := range strings.Split(value, ",") {
+		name, v, ok := strings.Cut(strings.TrimSpace(pair), "=")
+		if !ok || name == "" || v == "" {
+			log.Fatalf("Invalid entry %q in %s, expected name=value", pair, key)
+		}
+		m[name] = v
+	}
+	return m
+}
+
+// getSecret reads a secret from the environment variable key, or from the
+// file named by key_FILE (e.g. a Docker or Kubernetes secret mount).
+func getSecret(key string) string {
+	if value := os.Getenv(key); value != "" {
+		return value
+	}
+	path := os.Getenv(key + "_FILE")
+	if path == "" {
+		return ""
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		log.Fatalf("Could not read %s_FILE: %v", key, err)
+	}
+	return strings.TrimSpace(string(data))
+}
+
+func getInt(key string, fallback int) int {
+	value := os.Getenv(key)
+	if value == "" {
+		return fallback
+	}
+	n, err := strconv.Atoi(value)
+	if err != nil || n <= 0 {
+		log.Printf("Ignoring invalid %s=%q, using %d", key, value, fallback)
+		return fallback
+	}
+	return n
+}
+
+func getBool(key string, fallback bool) bool {
+	value := os.Getenv(key)
+	if value == "" {
+		return fallback
+	}
+	b, err := strconv.ParseBool(value)
+	if err != nil {
+		log.Printf("Ignoring invalid %s=%q, using %t", key, value, fallback)
+		return fallback
+	}
+	return b
+}
+
+func getDuration(key string, fallback time.Duration) time.Duration {
+	value := os.Getenv(key)
+	if value == "" {
+		return fallback
+	}
+	d, err := time.ParseDuration(value)
+	if err != nil || d <= 0 {
+		log.Printf("Ignoring invalid %s=%q, using %s", key, value, fallback)
+		return fallback
+	}
+	return d
+}
@@ -0,0 +1,26 @@
+package config
+
+import (
+	"testing"
+	"time"
+)
+
+func TestCheckTimeouts(t *testing.T) {
+	tests := []struct {
+		timeout, max time.Duration
+		wantErr      bool
+	}{
+		{timeout: 30 * time.Minute, max: 2 * time.Hour},
+		{timeout: time.Hour, max: time.Hour},
+		{timeout: 3 * time.Hour, max: 2 * time.Hour, wantErr: true},
+		{timeout: 500 * time.Millisecond, max: time.Hour, wantErr: true},
+		{timeout: 1500 * time.Millisecond, max: time.Hour, wantErr: true},
+		{timeout: time.Second, max: 90500 * time.Millisecond, wantErr: true},
+	}
+	for _, tt := range tests {
+		err := checkTimeouts(tt.timeout, tt.max)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("checkTimeouts(%s, %s) error = %v, want error %v", tt.timeout, tt.max, err, tt.wantErr)
+		}
+	}
+}
@@ -0,0 +1,258 @@
+package config
+
+import (
+	"log"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// GitHubSource is a GitHub or GitHub Enterprise Server host the catalog
+// discovers profiles on.
+type GitHubSource struct {
+	// Name identifies the source; it defaults to the host of WebURL.
+	Name string `yaml:"name"`
+	// APIURL is the base URL of the REST API, e.g. https://api.github.com
+	// or https://github.example.com/api/v3.
+	APIURL string `yaml:"api_url"`
+	// WebURL is the base URL of repositories, e.g. https://github.com.
+	WebURL string `yaml:"web_url"`
+	// SearchQuery is the repository search that finds profiles. It
+	// defaults to "inspec profile" unless other discovery rules are set.
+	SearchQuery string `yaml:"search_query"`
+	// Orgs and Users are organizations and users all of whose repositories
+	// are checked for profiles, Topics selects the repositories tagged
+	// with any of them and Repos lists repositories as owner/name.
+	Orgs   []string `yaml:"orgs"`
+	Users  []string `yaml:"users"`
+	Topics []string `yaml:"topics"`
+	Repos  []string `yaml:"repos"`
+	// Exclude are glob patterns of the owner/name of repositories that are
+	// never added to the catalog, e.g. "octocat/*".
+	Exclude []string `yaml:"exclude"`
+	// Schedule is the cron expression the source is synced on, if it
+	// differs from SYNC_SCHEDULE.
+	Schedule string `yaml:"schedule"`
+
+	// Token, or AppID, AppInstallationID and AppPrivateKey, authenticate
+	// requests to the host. In the sources file they are given as the name
+	// of an environment variable or a file holding the secret.
+	Token             string `yaml:"-"`
+	TokenEnv          string `yaml:"token_env"`
+	TokenFile         string `yaml:"token_file"`
+	AppID             int    `yaml:"app_id"`
+	AppInstallationID int    `yaml:"app_installation_id"`
+	AppPrivateKey     string `yaml:"-"`
+	AppPrivateKeyFile string `yaml:"app_private_key_file"`
+}
+
+// GitLabSource is a GitLab instance the catalog discovers profiles on.
+// Projects are listed from Groups (including their subgroups), by Topics
+// and by Search; a project is a profile if its default branch has an
+// inspec.yml file at the root.
+type GitLabSource struct {
+	Name string `yaml:"name"`
+	// URL is the base URL of the instance, e.g. https://gitlab.com.
+	URL      string   `yaml:"url"`
+	Groups   []string `yaml:"groups"`
+	Topics   []string `yaml:"topics"`
+	Search   string   `yaml:"search"`
+	Schedule string   `yaml:"schedule"`
+
+	// Token is a personal, group or project access token with the
+	// read_api and read_repository scopes.
+	Token     string `yaml:"-"`
+	TokenEnv  string `yaml:"token_env"`
+	TokenFile string `yaml:"token_file"`
+}
+
+// GitSource is a list of git repositories that are profiles, on any host
+// or on local disk (file:// URLs).
+type GitSource struct {
+	Name         string   `yaml:"name"`
+	Repositories []string `yaml:"repositories"`
+	Schedule     string   `yaml:"schedule"`
+
+	// Username and Token authenticate to HTTPS repositories.
+	Username  string `yaml:"username"`
+	Token     string `yaml:"-"`
+	TokenEnv  string `yaml:"token_env"`
+	TokenFile string `yaml:"token_file"`
+}
+
+// SupermarketSource is a Chef Supermarket whose compliance profiles are
+// added to the catalog.
+type SupermarketSource struct {
+	Name string `yaml:"name"`
+	// URL is the base URL of the Supermarket, e.g. https://supermarket.chef.io.
+	URL      string `yaml:"url"`
+	Schedule string `yaml:"schedule"`
+}
+
+// LocalSource is a directory on the API server holding profiles, one per
+// subdirectory.
+type LocalSource struct {
+	Name     string `yaml:"name"`
+	Path     string `yaml:"path"`
+	Schedule string `yaml:"schedule"`
+}
+
+// Sources are the sources the catalog is populated from.
+type Sources struct {
+	GitHub      []GitHubSource      `yaml:"github"`
+	GitLab      []GitLabSource      `yaml:"gitlab"`
+	Git         []GitSource         `yaml:"git"`
+	Supermarket []SupermarketSource `yaml:"supermarket"`
+	Local       []LocalSource       `yaml:"local"`
+}
+
+// Defaults for GitHub sources.
+const (
+	defaultGitHubAPIURL      = "https://api.github.com"
+	defaultGitHubWebURL      = "https://github.com"
+	defaultGitHubSearchQuery = "inspec profile"
+)
+
+// Defaults for the other sources.
+const (
+	defaultGitLabURL      = "https://gitlab.com"
+	defaultSupermarketURL = "https://supermarket.chef.io"
+)
+
+// loadSources reads the catalog sources from CATALOG_SOURCES_FILE. Without
+// it, a single GitHub source is configured from the GITHUB_* variables.
+func loadSources() Sources {
+	path := os.Getenv("CATALOG_SOURCES_FILE")
+	if path == "" {
+		return Sources{GitHub: []GitHubSource{withDefaults(GitHubSource{
+			Name:              "github",
+			APIURL:            os.Getenv("GITHUB_API_URL"),
+			WebURL:            os.Getenv("GITHUB_WEB_URL"),
+			SearchQuery:       os.Getenv("GITHUB_SEARCH_QUERY"),
+			Orgs:              getList("GITHUB_ORGS"),
+			Users:             getList("GITHUB_USERS"),
+			Topics:            getList("GITHUB_TOPICS"),
+			Repos:             getList("GITHUB_REPOS"),
+			Exclude:           getList("GITHUB_EXCLUDE"),
+			Token:             getSecret("GITHUB_TOKEN"),
+			AppID:             getInt("GITHUB_APP_ID", 0),
+			AppInstallationID: getInt("GITHUB_APP_INSTALLATION_ID", 0),
+			AppPrivateKey:     getSecret("GITHUB_APP_PRIVATE_KEY"),
+		})}}
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		log.Fatalf("Could not read CATALOG_SOURCES_FILE: %v", err)
+	}
+	var file Sources
+	if err := yaml.Unmarshal(data, &file); err != nil {
+		log.Fatalf("Could not parse CATALOG_SOURCES_FILE: %v", err)
+	}
+
+	names := make(map[string]bool)
+	checkName := func(name, kind string) {
+		if name == "" {
+			log.Fatalf("A %s source in CATALOG_SOURCES_FILE has no name", kind)
+		}
+		if names[name] {
+			log.Fatalf("Duplicate source name %q in CATALOG_SOURCES_FILE", name)
+		}
+		names[name] = true
+	}
+
+	for i, src := range file.GitHub {
+		src.Token = readToken(src.Token, src.TokenEnv, src.TokenFile)
+		if src.AppPrivateKeyFile != "" {
+			src.AppPrivateKey = readSecretFile(src.AppPrivateKeyFile)
+		}
+		src = withDefaults(src)
+		checkName(src.Name, "github")
+		file.GitHub[i] = src
+	}
+	for i, src := range file.GitLab {
+		src.Token = readToken(src.Token, src.TokenEnv, src.TokenFile)
+		if src.URL == "" {
+			src.URL = defaultGitLabURL
+		}
+		src.URL = strings.TrimSuffix(src.URL, "/")
+		if len(src.Groups) == 0 && len(src.Topics) == 0 && src.Search == "" {
+			log.Fatalf("GitLab source %q needs groups, topics or a search", src.Name)
+		}
+		checkName(src.Name, "gitlab")
+		file.GitLab[i] = src
+	}
+	for i, src := range file.Git {
+		src.Token = readToken(src.Token, src.TokenEnv, src.TokenFile)
+		checkName(src.Name, "git")
+		file.Git[i] = src
+	}
+	for i, src := range file.Supermarket {
+		if src.URL == "" {
+			src.URL = defaultSupermarketURL
+		}
+		src.URL = strings.TrimSuffix(src.URL, "/")
+		checkName(src.Name, "supermarket")
+		file.Supermarket[i] = src
+	}
+	for i, src := range file.Local {
+		if src.Path == "" {
+			log.Fatalf("Local source %q has no path", src.Name)
+		}
+		abs, err := filepath.Abs(src.Path)
+		if err != nil {
+			log.Fatalf("Invalid path %q of local source %q: %v", src.Path, src.Name, err)
+		}
+		src.Path = abs
+		checkName(src.Name, "local")
+		file.Local[i] = src
+	}
+	return file
+}
+
+// readToken returns the token of a source given directly, as the name of an
+// environment variable or as the path of a file, in increasing precedence.
+func readToken(token, env, file string) string {
+	if env != "" {
+		token = os.Getenv(env)
+	}
+	if file != "" {
+		token = readSecretFile(file)
+	}
+	return token
+}
+
+// withDefaults fills in the unset fields of a GitHub source.
+func withDefaults(src GitHubSource) GitHubSource {
+	if src.APIURL == "" {
+		src.APIURL = defaultGitHubAPIURL
+	}
+	if src.WebURL == "" {
+		src.WebURL = defaultGitHubWebURL
+	}
+	if src.SearchQuery == "" && len(src.Orgs)+len(src.Users)+len(src.Topics)+len(src.Repos) == 0 {
+		src.SearchQuery = defaultGitHubSearchQuery
+	}
+	src.APIURL = strings.TrimSuffix(src.APIURL, "/")
+	src.WebURL = strings.TrimSuffix(src.WebURL, "/")
+
+	u, err := url.Parse(src.WebURL)
+	if err != nil || u.Host == "" {
+		log.Fatalf("Invalid web URL %q of GitHub source", src.WebURL)
+	}
+	if src.Name == "" {
+		src.Name = u.Host
+	}
+	return src
+}
+
+func readSecretFile(path string) string {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		log.Fatalf("Could not read secret file %s: %v", path, err)
+	}
+	return strings.TrimSpace(string(data))
+}
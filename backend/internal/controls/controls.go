@@ -0,0 +1,463 @@
+// Package controls extracts the controls declared by an InSpec profile from
+// the Ruby files in its controls directory, without running InSpec.
+//
+// Only literal values are understood: a title, description, impact, tag or
+// ref computed at runtime (from an input, for example) is left out.
+package controls
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/ahasunos/caas/backend/internal/models"
+)
+
+// Dir is the directory of a profile holding its controls.
+const Dir = "controls"
+
+// DefaultImpact is the impact InSpec gives controls that don't set one.
+const DefaultImpact = 0.5
+
+// impacts maps the impact names InSpec accepts to their values.
+var impacts = map[string]float64{
+	"none":     0.0,
+	"low":      0.1,
+	"medium":   0.4,
+	"high":     0.7,
+	"critical": 0.9,
+}
+
+// ReadDir parses every Ruby file below the controls directory of the
+// profile in dir. A control declared again overrides the fields the later
+// declaration sets, as it does in InSpec.
+func ReadDir(dir string) ([]models.Control, error) {
+	root := filepath.Join(dir, Dir)
+	if _, err := os.Stat(root); errors.Is(err, fs.ErrNotExist) {
+		// Profiles that only wrap their dependencies have no controls
+		return nil, nil
+	}
+
+	var files []string
+	err := filepath.WalkDir(root, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if !d.IsDir() && filepath.Ext(path) == ".rb" {
+			files = append(files, path)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to list controls: %v", err)
+	}
+
+	var controls []models.Control
+	index := make(map[string]int)
+	for _, path := range files {
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read controls: %v", err)
+		}
+		rel, err := filepath.Rel(dir, path)
+		if err != nil {
+			return nil, err
+		}
+
+		for _, c := range parse(data) {
+			c.File = filepath.ToSlash(rel)
+			if i, ok := index[c.ID]; ok {
+				merge(&controls[i], c)
+				continue
+			}
+			index[c.ID] = len(controls)
+			controls = append(controls, c.Control)
+		}
+	}
+	return controls, nil
+}
+
+// Parse returns the controls declared in the Ruby source of a controls file.
+func Parse(src []byte) []models.Control {
+	parsed := parse(src)
+	controls := make([]models.Control, len(parsed))
+	for i, c := range parsed {
+		controls[i] = c.Control
+	}
+	return controls
+}
+
+// control is a parsed control that remembers whether its impact was set,
+// which is needed to merge redeclared controls.
+type control struct {
+	models.Control
+	impactSet bool
+}
+
+func parse(src []byte) []control {
+	p := &parser{tokens: tokenize(src)}
+	return p.parse()
+}
+
+// merge applies a redeclaration of a control to the original declaration.
+func merge(dst *models.Control, src control) {
+	if src.Title != "" {
+		dst.Title = src.Title
+	}
+	if src.Desc != "" {
+		dst.Desc = src.Desc
+	}
+	if src.impactSet {
+		dst.Impact = src.Impact
+	}
+	for k, v := range src.Tags {
+		if dst.Tags == nil {
+			dst.Tags = make(map[string]interface{})
+		}
+		dst.Tags[k] = v
+	}
+	dst.Refs = append(dst.Refs, src.Refs...)
+}
+
+type parser struct {
+	tokens []*token
+	pos    int
+}
+
+func (p *parser) peek() *token {
+	return p.tokens[p.pos]
+}
+
+func (p *parser) advance() *token {
+	tok := p.tokens[p.pos]
+	if tok.kind != tokEOF {
+		p.pos++
+	}
+	return tok
+}
+
+func (p *parser) isPunct(text string) bool {
+	tok := p.peek()
+	return tok.kind == tokPunct && tok.text == text
+}
+
+func (p *parser) parse() []control {
+	var controls []control
+	var current *control
+	lineStart := true
+
+	for p.peek().kind != tokEOF {
+		tok := p.advance()
+		if tok.kind == tokNewline || tok.kind == tokPunct && tok.text == ";" {
+			lineStart = true
+			continue
+		}
+		if !lineStart || tok.kind != tokIdent {
+			lineStart = false
+			continue
+		}
+		lineStart = false
+
+		if tok.text == "control" {
+			if id, ok := p.controlID(); ok {
+				controls = append(controls, control{Control: models.Control{ID: id, Impact: DefaultImpact}})
+				current = &controls[len(controls)-1]
+			}
+			continue
+		}
+		if current == nil {
+			continue
+		}
+
+		switch tok.text {
+		case "title", "desc", "impact", "tag", "ref":
+			args, pairs := p.arguments()
+			apply(current, tok.text, args, pairs)
+		}
+	}
+
+	for i := range controls {
+		controls[i].Title = strings.TrimSpace(controls[i].Title)
+		controls[i].Desc = strings.TrimSpace(controls[i].Desc)
+	}
+	return controls
+}
+
+// controlID reads the ID following the control keyword.
+func (p *parser) controlID() (string, bool) {
+	paren := p.isPunct("(")
+	if paren {
+		p.advance()
+	}
+	tok := p.peek()
+	if tok.kind != tokString && tok.kind != tokSymbol {
+		return "", false
+	}
+	p.advance()
+	return tok.text, true
+}
+
+// pair is a key/value argument. Keys are kept in order so that repeated
+// tags behave as in InSpec.
+type pair struct {
+	key   string
+	value interface{}
+}
+
+// arguments reads the arguments of a method call up to the end of the
+// statement. Values that aren't literals are returned as nil.
+func (p *parser) arguments() ([]interface{}, []pair) {
+	paren := p.isPunct("(")
+	if paren {
+		p.advance()
+	}
+
+	var args []interface{}
+	var pairs []pair
+	for {
+		if paren {
+			p.skipNewlines()
+		}
+		tok := p.peek()
+		if tok.kind == tokEOF || tok.kind == tokNewline || paren && p.isPunct(")") {
+			break
+		}
+
+		if tok.kind == tokLabel {
+			p.advance()
+			pairs = append(pairs, pair{key: tok.text, value: p.value()})
+		} else {
+			v := p.value()
+			if p.isPunct("=>") {
+				p.advance()
+				pairs = append(pairs, pair{key: fmt.Sprint(v), value: p.value()})
+			} else {
+				args = append(args, v)
+			}
+		}
+
+		if !p.isPunct(",") {
+			break
+		}
+		p.advance()
+		p.skipNewlines()
+	}
+
+	if paren && p.isPunct(")") {
+		p.advance()
+	}
+	return args, pairs
+}
+
+func (p *parser) skipNewlines() {
+	for p.peek().kind == tokNewline {
+		p.advance()
+	}
+}
+
+// value reads a single literal value. Anything else is skipped up to the
+// next argument and returned as nil.
+func (p *parser) value() interface{} {
+	tok := p.peek()
+	switch tok.kind {
+	case tokString, tokSymbol:
+		p.advance()
+		return tok.text
+	case tokWords:
+		p.advance()
+		words := make([]interface{}, len(tok.words))
+		for i, w := range tok.words {
+			words[i] = w
+		}
+		return words
+	case tokNumber:
+		p.advance()
+		return number(tok.text)
+	case tokIdent:
+		switch tok.text {
+		case "true":
+			p.advance()
+			return true
+		case "false":
+			p.advance()
+			return false
+		case "nil":
+			p.advance()
+			return nil
+		}
+	case tokPunct:
+		switch tok.text {
+		case "-":
+			if next := p.tokens[p.pos+1]; next.kind == tokNumber {
+				p.pos += 2
+				return number("-" + next.text)
+			}
+		case "[":
+			return p.array()
+		case "{":
+			return p.hash()
+		}
+	}
+	p.skipExpression()
+	return nil
+}
+
+func number(text string) interface{} {
+	if n, err := strconv.Atoi(text); err == nil {
+		return n
+	}
+	f, _ := strconv.ParseFloat(text, 64)
+	return f
+}
+
+func (p *parser) array() interface{} {
+	p.advance()
+	values := []interface{}{}
+	for {
+		p.skipNewlines()
+		if p.isPunct("]") || p.peek().kind == tokEOF {
+			break
+		}
+		values = append(values, p.value())
+		p.skipNewlines()
+		if !p.isPunct(",") {
+			break
+		}
+		p.advance()
+	}
+	if p.isPunct("]") {
+		p.advance()
+	}
+	return values
+}
+
+func (p *parser) hash() interface{} {
+	p.advance()
+	values := map[string]interface{}{}
+	for {
+		p.skipNewlines()
+		if p.isPunct("}") || p.peek().kind == tokEOF {
+			break
+		}
+		var key string
+		if tok := p.peek(); tok.kind == tokLabel {
+			p.advance()
+			key = tok.text
+		} else {
+			key = fmt.Sprint(p.value())
+			if !p.isPunct("=>") {
+				break
+			}
+			p.advance()
+		}
+		values[key] = p.value()
+		p.skipNewlines()
+		if !p.isPunct(",") {
+			break
+		}
+		p.advance()
+	}
+	if p.isPunct("}") {
+		p.advance()
+	}
+	return values
+}
+
+// skipExpression skips tokens up to the next comma or closing bracket at
+// the current nesting level, or the end of the statement.
+func (p *parser) skipExpression() {
+	depth := 0
+	for {
+		tok := p.peek()
+		if tok.kind == tokEOF || tok.kind == tokNewline && depth == 0 {
+			return
+		}
+		if tok.kind == tokPunct {
+			switch tok.text {
+			case "(", "[", "{":
+				depth++
+			case ")", "]", "}":
+				if depth == 0 {
+					return
+				}
+				depth--
+			case ",", ";":
+				if depth == 0 {
+					return
+				}
+			}
+		}
+		p.advance()
+	}
+}
+
+// apply records the arguments of a control's DSL method on the control.
+func apply(c *control, method string, args []interface{}, pairs []pair) {
+	switch method {
+	case "title":
+		if s, ok := first(args).(string); ok {
+			c.Title = s
+		}
+	case "desc":
+		// desc 'text' sets the description; desc 'label', 'text' adds a
+		// labelled description, of which only "default" is kept.
+		switch len(args) {
+		case 1:
+			if s, ok := args[0].(string); ok {
+				c.Desc = s
+			}
+		case 2:
+			label, _ := args[0].(string)
+			if s, ok := args[1].(string); ok && label == "default" {
+				c.Desc = s
+			}
+		}
+	case "impact":
+		switch v := first(args).(type) {
+		case int:
+			c.Impact, c.impactSet = float64(v), true
+		case float64:
+			c.Impact, c.impactSet = v, true
+		case string:
+			if impact, ok := impacts[strings.ToLower(v)]; ok {
+				c.Impact, c.impactSet = impact, true
+			} else if f, err := strconv.ParseFloat(v, 64); err == nil {
+				c.Impact, c.impactSet = f, true
+			}
+		}
+	case "tag":
+		if c.Tags == nil {
+			c.Tags = make(map[string]interface{})
+		}
+		for _, arg := range args {
+			if s, ok := arg.(string); ok {
+				c.Tags[s] = nil
+			}
+		}
+		for _, pr := range pairs {
+			c.Tags[pr.key] = pr.value
+		}
+	case "ref":
+		ref := models.ControlRef{}
+		ref.Ref, _ = first(args).(string)
+		for _, pr := range pairs {
+			if pr.key == "url" || pr.key == "uri" {
+				ref.URL, _ = pr.value.(string)
+			}
+		}
+		if ref.Ref != "" || ref.URL != "" {
+			c.Refs = append(c.Refs, ref)
+		}
+	}
+}
+
+func first(args []interface{}) interface{} {
+	if len(args) == 0 {
+		return nil
+	}
+	return args[0]
+}
@@ -0,0 +1,180 @@
+package controls
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/ahasunos/caas/backend/internal/models"
+)
+
+func TestParse(t *testing.T) {
+	tests := []struct {
+		name string
+		src  string
+		want []models.Control
+	}{
+		{
+			name: "literals",
+			src: `
+control 'os-01' do
+  impact 1.0
+  title 'Trusted hosts login'
+  desc "Hosts.equiv file is a weak implementation of authentication."
+  tag 'level1', nist: ['AC-6', 'AC-17'], cis: '5.2.1', 'severity' => :high, count: -2, enabled: true, other: nil
+  ref 'NSA-RH6-STIG - Section 3.5.2.1', url: 'https://www.nsa.gov/'
+  describe file('/etc/hosts.equiv') do
+    it { should_not exist }
+  end
+end
+`,
+			want: []models.Control{{
+				ID:     "os-01",
+				Title:  "Trusted hosts login",
+				Desc:   "Hosts.equiv file is a weak implementation of authentication.",
+				Impact: 1,
+				Tags: map[string]interface{}{"level1": nil, "nist": []interface{}{"AC-6", "AC-17"}, "cis": "5.2.1",
+					"severity": "high", "count": -2, "enabled": true, "other": nil},
+				Refs: []models.ControlRef{{Ref: "NSA-RH6-STIG - Section 3.5.2.1", URL: "https://www.nsa.gov/"}},
+			}},
+		},
+		{
+			name: "symbol ID, parentheses and default impact",
+			src:  "control(:sshd_01) do\n  title('SSH')\nend\n",
+			want: []models.Control{{ID: "sshd_01", Title: "SSH", Impact: DefaultImpact}},
+		},
+		{
+			name: "heredoc description",
+			src:  "control 'x' do\n  desc <<~DESC\n    Line one\n      indented\n  DESC\n  impact 0.3\nend\n",
+			want: []models.Control{{ID: "x", Desc: "Line one\n  indented", Impact: 0.3}},
+		},
+		{
+			name: "percent literals",
+			src:  "control %q(x) do\n  title %q(It's (really) fine)\n  tag %w(a b)\n  tag cci: %w[CCI-1 CCI-2]\nend\n",
+			want: []models.Control{{ID: "x", Title: "It's (really) fine", Impact: DefaultImpact,
+				Tags: map[string]interface{}{"cci": []interface{}{"CCI-1", "CCI-2"}}}},
+		},
+		{
+			name: "labelled descriptions",
+			src:  "control 'x' do\n  desc 'rationale', 'Why'\n  desc 'default', 'What'\n  desc 'check', 'How'\nend\n",
+			want: []models.Control{{ID: "x", Desc: "What", Impact: DefaultImpact}},
+		},
+		{
+			name: "named impacts",
+			src:  "control 'a' do\n  impact 'critical'\nend\ncontrol 'b' do\n  impact :Low\nend\ncontrol 'c' do\n  impact '0.2'\nend\ncontrol 'd' do\n  impact 'unknown'\nend\n",
+			want: []models.Control{{ID: "a", Impact: 0.9}, {ID: "b", Impact: 0.1}, {ID: "c", Impact: 0.2}, {ID: "d", Impact: DefaultImpact}},
+		},
+		{
+			name: "values that aren't literals",
+			src: `control "x" do
+  title "Port #{input('port')}" + suffix
+  title input('title')
+  impact input('impact', value: 0.7)
+  desc 'default', input('desc')
+  tag nist: input('nist'), cis: '1.1'
+end
+`,
+			want: []models.Control{{ID: "x", Title: "Port #{input('port')}", Impact: DefaultImpact,
+				Tags: map[string]interface{}{"nist": nil, "cis": "1.1"}}},
+		},
+		{
+			name: "nested hash and multi-line arguments",
+			src:  "control 'x' do\n  tag(\n    'mapping' => { 'cis' => ['1.1', 2] },\n    rule: {id: 'r1'}\n  )\nend\n",
+			want: []models.Control{{ID: "x", Impact: DefaultImpact, Tags: map[string]interface{}{
+				"mapping": map[string]interface{}{"cis": []interface{}{"1.1", 2}},
+				"rule":    map[string]interface{}{"id": "r1"},
+			}}},
+		},
+		{
+			name: "methods of other objects are ignored",
+			src:  "title 'Profile'\ncontrol 'x' do\n  only_if { title 'nope' }\n  describe x do its('title') { should eq 'y' } end\nend\n",
+			want: []models.Control{{ID: "x", Impact: DefaultImpact}},
+		},
+		{
+			name: "commented out and in strings",
+			src:  "# control 'a' do\n=begin\ncontrol 'b'\n=end\nx = \"control 'c'\"\ncontrol 'd'; title 'D'\n",
+			want: []models.Control{{ID: "d", Title: "D", Impact: DefaultImpact}},
+		},
+		{
+			name: "computed ID",
+			src:  "control \"sysctl-#{i}\" do\nend\ncontrol name do\nend\n",
+			want: []models.Control{{ID: "sysctl-#{i}", Impact: DefaultImpact}},
+		},
+		{
+			name: "redeclared in the same file",
+			src:  "control 'x' do\n  title 'First'\nend\ncontrol 'x' do\n  title 'Second'\nend\n",
+			want: []models.Control{{ID: "x", Title: "First", Impact: DefaultImpact}, {ID: "x", Title: "Second", Impact: DefaultImpact}},
+		},
+		{
+			name: "whitespace is trimmed",
+			src:  "control 'x' do\n  title \"  padded\\n\"\n  desc %q(\n  text\n)\nend\n",
+			want: []models.Control{{ID: "x", Title: "padded", Desc: "text", Impact: DefaultImpact}},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := Parse([]byte(tt.src)); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Parse =\n%+v\nwant\n%+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestReadDir(t *testing.T) {
+	dir := t.TempDir()
+	write := func(name, src string) {
+		path := filepath.Join(dir, name)
+		if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(src), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	write("controls/a.rb", "control 'x' do\n  title 'Original'\n  desc 'Text'\n  impact 0.7\n  tag a: 1\n  ref 'R1'\nend\ncontrol 'y' do\nend\n")
+	// A redeclaration, as from include_controls, only overrides what it sets
+	write("controls/nested/b.rb", "control 'x' do\n  impact 0.0\n  tag b: 2\n  ref 'R2'\nend\n")
+	write("controls/README.md", "control 'z' do\nend\n")
+	write("libraries/helper.rb", "control 'lib' do\nend\n")
+
+	got, err := ReadDir(dir)
+	if err != nil {
+		t.Fatalf("ReadDir: %v", err)
+	}
+	want := []models.Control{
+		{ID: "x", Title: "Original", Desc: "Text", Impact: 0, Tags: map[string]interface{}{"a": 1, "b": 2},
+			Refs: []models.ControlRef{{Ref: "R1"}, {Ref: "R2"}}, File: "controls/a.rb"},
+		{ID: "y", Impact: DefaultImpact, File: "controls/a.rb"},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("ReadDir =\n%+v\nwant\n%+v", got, want)
+	}
+}
+
+func TestReadDirWithoutControls(t *testing.T) {
+	got, err := ReadDir(t.TempDir())
+	if err != nil || got != nil {
+		t.Errorf("ReadDir = %v, %v; want no controls", got, err)
+	}
+}
+
+func FuzzParse(f *testing.F) {
+	for _, src := range []string{
+		"control 'x' do\n  impact 0.7\n  title 'T'\n  desc <<~D\n    text\n  D\n  tag a: [1, {b: :c}], 'd' => %w(e f)\nend\n",
+		"control %q(x) do; desc 'default', \"#{a('}')}\"; impact 'high'; ref 'r', url: 'u'; end",
+		"=begin\n=end\ncontrol(:y) do tag(\n1 =>\n-2) end",
+		"a <<-A, <<'B'\nA\nB\n%i[",
+		"control 'x' do\n  tag -",
+	} {
+		f.Add([]byte(src))
+	}
+	f.Fuzz(func(t *testing.T, src []byte) {
+		// Every token but the last consumes input, so lexing terminates
+		tokens := tokenize(src)
+		if len(tokens) > len(src)+1 || tokens[len(tokens)-1].kind != tokEOF {
+			t.Fatalf("%d tokens for %d bytes of input", len(tokens), len(src))
+		}
+		Parse(src)
+	})
+}
@@ -0,0 +1,396 @@
+package controls
+
+import (
+	"strings"
+)
+
+// tokenKind is the kind of a token produced by the lexer.
+type tokenKind int
+
+const (
+	tokEOF tokenKind = iota
+	tokNewline
+	tokIdent
+	tokLabel  // `name:` or `'name':` in a hash or keyword argument
+	tokString // any string literal, including heredocs
+	tokWords  // %w() and %i() word arrays
+	tokSymbol
+	tokNumber
+	tokPunct
+)
+
+type token struct {
+	kind  tokenKind
+	text  string
+	words []string
+}
+
+// lexer splits Ruby source into the tokens the control parser needs. It
+// understands Ruby's literal syntax well enough to never mistake the
+// content of a string, heredoc or comment for code, but makes no attempt to
+// tokenize operators beyond single characters and `=>`.
+type lexer struct {
+	src []byte
+	pos int
+	// heredocs waiting for their body, which starts on the next line.
+	heredocs []pendingHeredoc
+}
+
+type pendingHeredoc struct {
+	tok    *token
+	id     string
+	indent bool // <<- and <<~ allow an indented terminator
+	squish bool // <<~ removes the common indentation
+}
+
+func tokenize(src []byte) []*token {
+	l := &lexer{src: src}
+	var tokens []*token
+	for {
+		tok := l.next()
+		tokens = append(tokens, tok)
+		if tok.kind == tokEOF {
+			return tokens
+		}
+	}
+}
+
+func (l *lexer) peekByte(offset int) byte {
+	if l.pos+offset < len(l.src) {
+		return l.src[l.pos+offset]
+	}
+	return 0
+}
+
+func (l *lexer) next() *token {
+	for l.pos < len(l.src) {
+		c := l.src[l.pos]
+		switch {
+		case c == ' ' || c == '\t' || c == '\r':
+			l.pos++
+		case c == '\\' && l.peekByte(1) == '\n':
+			l.pos += 2
+		case c == '#':
+			l.skipLine()
+		case c == '\n':
+			l.pos++
+			l.readHeredocBodies()
+			if l.atLineStart("=begin") {
+				l.skipBlockComment()
+			}
+			return &token{kind: tokNewline}
+		case c == '\'' || c == '"':
+			l.pos++
+			return l.maybeLabel(&token{kind: tokString, text: l.readQuoted(c, c, c == '"')})
+		case c == '%' && l.percentLiteral():
+			return l.readPercent()
+		case c == '<' && l.peekByte(1) == '<' && l.heredocStart():
+			return l.readHeredocStart()
+		case c == ':' && l.peekByte(1) == ':':
+			l.pos += 2
+			return &token{kind: tokPunct, text: "::"}
+		case c == ':' && isIdentStart(l.peekByte(1)):
+			l.pos++
+			return &token{kind: tokSymbol, text: l.readIdent()}
+		case c == ':' && (l.peekByte(1) == '"' || l.peekByte(1) == '\''):
+			q := l.peekByte(1)
+			l.pos += 2
+			return &token{kind: tokSymbol, text: l.readQuoted(q, q, q == '"')}
+		case isDigit(c):
+			return &token{kind: tokNumber, text: l.readNumber()}
+		case isIdentStart(c):
+			ident := l.readIdent()
+			if l.peekByte(0) == ':' && l.peekByte(1) != ':' {
+				l.pos++
+				return &token{kind: tokLabel, text: ident}
+			}
+			return &token{kind: tokIdent, text: ident}
+		case c == '=' && l.peekByte(1) == '>':
+			l.pos += 2
+			return &token{kind: tokPunct, text: "=>"}
+		default:
+			l.pos++
+			return &token{kind: tokPunct, text: string(c)}
+		}
+	}
+	return &token{kind: tokEOF}
+}
+
+func (l *lexer) skipLine() {
+	for l.pos < len(l.src) && l.src[l.pos] != '\n' {
+		l.pos++
+	}
+}
+
+func (l *lexer) atLineStart(prefix string) bool {
+	return strings.HasPrefix(string(l.src[l.pos:min(len(l.src), l.pos+len(prefix))]), prefix)
+}
+
+// skipBlockComment skips an =begin/=end comment, leaving the lexer at the
+// end of the =end line.
+func (l *lexer) skipBlockComment() {
+	for l.pos < len(l.src) {
+		l.skipLine()
+		if l.pos < len(l.src) {
+			l.pos++
+		}
+		if l.atLineStart("=end") {
+			l.skipLine()
+			return
+		}
+	}
+}
+
+// maybeLabel turns a string followed directly by a colon into a label.
+func (l *lexer) maybeLabel(tok *token) *token {
+	if l.peekByte(0) == ':' && l.peekByte(1) != ':' {
+		l.pos++
+		tok.kind = tokLabel
+	}
+	return tok
+}
+
+// readQuoted reads a string up to its closing delimiter, which must not be
+// the delimiter it was opened with for bracketed %-literals. Escapes are
+// interpreted in double-quoted strings; interpolations are kept verbatim.
+func (l *lexer) readQuoted(open, close byte, escapes bool) string {
+	var b strings.Builder
+	depth := 0
+	for l.pos < len(l.src) {
+		c := l.src[l.pos]
+		l.pos++
+		switch {
+		case c == '\\' && l.pos < len(l.src):
+			e := l.src[l.pos]
+			l.pos++
+			if escapes {
+				b.WriteString(unescape(e))
+			} else if e == close || e == open || e == '\\' {
+				b.WriteByte(e)
+			} else {
+				b.WriteByte('\\')
+				b.WriteByte(e)
+			}
+		case escapes && c == '#' && l.peekByte(0) == '{':
+			b.WriteString(l.readInterpolation())
+		case c == open && open != close:
+			depth++
+			b.WriteByte(c)
+		case c == close:
+			if depth == 0 {
+				return b.String()
+			}
+			depth--
+			b.WriteByte(c)
+		default:
+			b.WriteByte(c)
+		}
+	}
+	return b.String()
+}
+
+// readInterpolation returns a #{...} interpolation verbatim. Braces in
+// string literals inside it don't count.
+func (l *lexer) readInterpolation() string {
+	start := l.pos - 1
+	depth := 0
+	for l.pos < len(l.src) {
+		c := l.src[l.pos]
+		l.pos++
+		if c == '\'' || c == '"' {
+			l.readQuoted(c, c, c == '"')
+		} else if c == '{' {
+			depth++
+		} else if c == '}' {
+			depth--
+			if depth == 0 {
+				break
+			}
+		}
+	}
+	return string(l.src[start:l.pos])
+}
+
+func unescape(c byte) string {
+	switch c {
+	case 'n':
+		return "\n"
+	case 't':
+		return "\t"
+	case 's':
+		return " "
+	case '\n':
+		return ""
+	}
+	return string(c)
+}
+
+// percentLiteral reports whether the % at the current position starts a
+// %-literal rather than being the modulo operator.
+func (l *lexer) percentLiteral() bool {
+	c := l.peekByte(1)
+	if strings.IndexByte("qQwWiI", c) >= 0 {
+		return isDelimiter(l.peekByte(2))
+	}
+	return c == '(' || c == '[' || c == '{' || c == '<' || c == '|' || c == '!'
+}
+
+func (l *lexer) readPercent() *token {
+	l.pos++
+	kind := byte('Q')
+	if c := l.src[l.pos]; !isDelimiter(c) {
+		kind = c
+		l.pos++
+	}
+	open := l.src[l.pos]
+	l.pos++
+	close := closingDelimiter(open)
+	text := l.readQuoted(open, close, kind == 'Q' || kind == 'W' || kind == 'I')
+
+	switch kind {
+	case 'w', 'W', 'i', 'I':
+		return &token{kind: tokWords, words: strings.Fields(text)}
+	}
+	return &token{kind: tokString, text: text}
+}
+
+func isDelimiter(c byte) bool {
+	return c != 0 && !isIdentChar(c) && c != ' ' && c != '\t' && c != '\n' && c != '\r'
+}
+
+func closingDelimiter(open byte) byte {
+	switch open {
+	case '(':
+		return ')'
+	case '[':
+		return ']'
+	case '{':
+		return '}'
+	case '<':
+		return '>'
+	}
+	return open
+}
+
+// heredocStart reports whether the << at the current position starts a
+// heredoc rather than being the append operator.
+func (l *lexer) heredocStart() bool {
+	i := 2
+	if c := l.peekByte(i); c == '~' || c == '-' {
+		i++
+	}
+	c := l.peekByte(i)
+	if c == '\'' || c == '"' {
+		return true
+	}
+	return c >= 'A' && c <= 'Z' || c == '_'
+}
+
+func (l *lexer) readHeredocStart() *token {
+	l.pos += 2
+	h := pendingHeredoc{tok: &token{kind: tokString}}
+	if c := l.peekByte(0); c == '~' || c == '-' {
+		h.indent = true
+		h.squish = c == '~'
+		l.pos++
+	}
+	if q := l.peekByte(0); q == '\'' || q == '"' {
+		l.pos++
+		h.id = l.readQuoted(q, q, false)
+	} else {
+		h.id = l.readIdent()
+	}
+	l.heredocs = append(l.heredocs, h)
+	return h.tok
+}
+
+// readHeredocBodies reads the bodies of the heredocs started on the line
+// that just ended.
+func (l *lexer) readHeredocBodies() {
+	for _, h := range l.heredocs {
+		var lines []string
+		for l.pos < len(l.src) {
+			start := l.pos
+			l.skipLine()
+			line := string(l.src[start:l.pos])
+			if l.pos < len(l.src) {
+				l.pos++
+			}
+			terminator := line
+			if h.indent {
+				terminator = strings.TrimLeft(line, " \t")
+			}
+			if strings.TrimRight(terminator, "\r") == h.id {
+				break
+			}
+			lines = append(lines, line)
+		}
+		if h.squish {
+			lines = dedent(lines)
+		}
+		h.tok.text = strings.Join(lines, "\n")
+	}
+	l.heredocs = nil
+}
+
+// dedent removes the indentation common to all non-blank lines.
+func dedent(lines []string) []string {
+	indent := -1
+	for _, line := range lines {
+		if strings.TrimSpace(line) == "" {
+			continue
+		}
+		n := len(line) - len(strings.TrimLeft(line, " \t"))
+		if indent < 0 || n < indent {
+			indent = n
+		}
+	}
+	if indent <= 0 {
+		return lines
+	}
+	out := make([]string, len(lines))
+	for i, line := range lines {
+		if len(line) >= indent {
+			out[i] = line[indent:]
+		} else {
+			out[i] = strings.TrimLeft(line, " \t")
+		}
+	}
+	return out
+}
+
+func (l *lexer) readNumber() string {
+	start := l.pos
+	for l.pos < len(l.src) {
+		c := l.src[l.pos]
+		if isDigit(c) || c == '_' || c == '.' && isDigit(l.peekByte(1)) {
+			l.pos++
+			continue
+		}
+		break
+	}
+	return strings.ReplaceAll(string(l.src[start:l.pos]), "_", "")
+}
+
+func (l *lexer) readIdent() string {
+	start := l.pos
+	for l.pos < len(l.src) && isIdentChar(l.src[l.pos]) {
+		l.pos++
+	}
+	if c := l.peekByte(0); (c == '?' || c == '!') && l.peekByte(1) != '=' {
+		l.pos++
+	}
+	return string(l.src[start:l.pos])
+}
+
+func isDigit(c byte) bool {
+	return c >= '0' && c <= '9'
+}
+
+func isIdentStart(c byte) bool {
+	return c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || c == '_' || c >= 0x80
+}
+
+func isIdentChar(c byte) bool {
+	return isIdentStart(c) || isDigit(c)
+}
@@ -0,0 +1,136 @@
+package controls
+
+import (
+	"reflect"
+	"testing"
+)
+
+// lexed is a token in a form that is easy to compare.
+type lexed struct {
+	kind tokenKind
+	text string
+}
+
+func lex(src string) []lexed {
+	var out []lexed
+	for _, tok := range tokenize([]byte(src)) {
+		text := tok.text
+		if tok.kind == tokWords {
+			text = ""
+			for i, w := range tok.words {
+				if i > 0 {
+					text += "|"
+				}
+				text += w
+			}
+		}
+		out = append(out, lexed{tok.kind, text})
+	}
+	return out
+}
+
+func TestTokenize(t *testing.T) {
+	tests := []struct {
+		name string
+		src  string
+		want []lexed
+	}{
+		{
+			name: "single quoted",
+			src:  `'it''s \'quoted\' \n'`,
+			want: []lexed{{tokString, "it"}, {tokString, `s 'quoted' \n`}, {tokEOF, ""}},
+		},
+		{
+			name: "double quoted with escapes and interpolation",
+			src:  `"a\tb #{input('x', value: "}")} \"c\""`,
+			want: []lexed{{tokString, "a\tb #{input('x', value: \"}\")} \"c\""}, {tokEOF, ""}},
+		},
+		{
+			name: "symbols",
+			src:  `:name :"quoted name" :'single' a::B`,
+			want: []lexed{{tokSymbol, "name"}, {tokSymbol, "quoted name"}, {tokSymbol, "single"},
+				{tokIdent, "a"}, {tokPunct, "::"}, {tokIdent, "B"}, {tokEOF, ""}},
+		},
+		{
+			name: "labels",
+			src:  `tag nist: 'x', 'cis-level': 1, "a" => b?`,
+			want: []lexed{{tokIdent, "tag"}, {tokLabel, "nist"}, {tokString, "x"}, {tokPunct, ","},
+				{tokLabel, "cis-level"}, {tokNumber, "1"}, {tokPunct, ","}, {tokString, "a"}, {tokPunct, "=>"},
+				{tokIdent, "b?"}, {tokEOF, ""}},
+		},
+		{
+			name: "numbers",
+			src:  `1_000 0.75 1.to_s`,
+			want: []lexed{{tokNumber, "1000"}, {tokNumber, "0.75"}, {tokNumber, "1"}, {tokPunct, "."},
+				{tokIdent, "to_s"}, {tokEOF, ""}},
+		},
+		{
+			name: "percent literals",
+			src:  `%q(a (nested) b) %Q[x\ty] %(plain) %w(one two  three) %i[a b] %{braces} %|pipes|`,
+			want: []lexed{{tokString, "a (nested) b"}, {tokString, "x\ty"}, {tokString, "plain"},
+				{tokWords, "one|two|three"}, {tokWords, "a|b"}, {tokString, "braces"}, {tokString, "pipes"}, {tokEOF, ""}},
+		},
+		{
+			name: "modulo is not a percent literal",
+			src:  `a % b %w`,
+			want: []lexed{{tokIdent, "a"}, {tokPunct, "%"}, {tokIdent, "b"}, {tokPunct, "%"}, {tokIdent, "w"}, {tokEOF, ""}},
+		},
+		{
+			name: "comments",
+			src:  "a # 'not a string\n=begin\ncontrol 'x'\n=end\nb",
+			want: []lexed{{tokIdent, "a"}, {tokNewline, ""}, {tokNewline, ""}, {tokIdent, "b"}, {tokEOF, ""}},
+		},
+		{
+			name: "line continuation",
+			src:  "a \\\n b",
+			want: []lexed{{tokIdent, "a"}, {tokIdent, "b"}, {tokEOF, ""}},
+		},
+		{
+			name: "heredoc",
+			src:  "desc <<EOS, 'x'\n  first\n  second\nEOS\nb",
+			want: []lexed{{tokIdent, "desc"}, {tokString, "  first\n  second"}, {tokPunct, ","}, {tokString, "x"},
+				{tokNewline, ""}, {tokIdent, "b"}, {tokEOF, ""}},
+		},
+		{
+			name: "indented heredoc terminator",
+			src:  "a <<-EOS\n  text\n  EOS\n",
+			want: []lexed{{tokIdent, "a"}, {tokString, "  text"}, {tokNewline, ""}, {tokEOF, ""}},
+		},
+		{
+			name: "squiggly heredoc",
+			src:  "a <<~'EOS'\n    one\n\n      two\n  EOS\n",
+			want: []lexed{{tokIdent, "a"}, {tokString, "one\n\n  two"}, {tokNewline, ""}, {tokEOF, ""}},
+		},
+		{
+			name: "two heredocs on one line",
+			src:  "a(<<~A, <<~B)\n  one\nA\n  two\nB\nc",
+			want: []lexed{{tokIdent, "a"}, {tokPunct, "("}, {tokString, "one"}, {tokPunct, ","}, {tokString, "two"},
+				{tokPunct, ")"}, {tokNewline, ""}, {tokIdent, "c"}, {tokEOF, ""}},
+		},
+		{
+			name: "append is not a heredoc",
+			src:  "list << item",
+			want: []lexed{{tokIdent, "list"}, {tokPunct, "<"}, {tokPunct, "<"}, {tokIdent, "item"}, {tokEOF, ""}},
+		},
+		{
+			name: "unterminated string",
+			src:  `'open`,
+			want: []lexed{{tokString, "open"}, {tokEOF, ""}},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := lex(tt.src); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("tokenize(%q) =\n%v\nwant\n%v", tt.src, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestDedent(t *testing.T) {
+	got := dedent([]string{"    a", "", "  b", "\t"})
+	want := []string{"  a", "", "b", ""}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("dedent = %q, want %q", got, want)
+	}
+}
@@ -0,0 +1,216 @@
+// Package cron parses standard five-field cron expressions and computes
+// when they next fire.
+package cron
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Schedule is a parsed cron expression.
+type Schedule struct {
+	minute, hour, dom, month, dow uint64
+	// domStar and dowStar record whether the day fields were unrestricted.
+	// As in Vixie cron, a day matches either day field if both are
+	// restricted.
+	domStar, dowStar bool
+	expr             string
+}
+
+// maxLookahead bounds the search for the next activation, so that
+// expressions that never fire, such as "0 0 30 2 *", don't loop forever.
+const maxLookahead = 5 * 366 * 24 * time.Hour
+
+// descriptors are the predefined schedules accepted in place of the five
+// fields.
+var descriptors = map[string]string{
+	"@yearly":   "0 0 1 1 *",
+	"@annually": "0 0 1 1 *",
+	"@monthly":  "0 0 1 * *",
+	"@weekly":   "0 0 * * 0",
+	"@daily":    "0 0 * * *",
+	"@midnight": "0 0 * * *",
+	"@hourly":   "0 * * * *",
+}
+
+var monthNames = map[string]int{
+	"jan": 1, "feb": 2, "mar": 3, "apr": 4, "may": 5, "jun": 6,
+	"jul": 7, "aug": 8, "sep": 9, "oct": 10, "nov": 11, "dec": 12,
+}
+
+var dayNames = map[string]int{
+	"sun": 0, "mon": 1, "tue": 2, "wed": 3, "thu": 4, "fri": 5, "sat": 6,
+}
+
+// field describes the values a cron field accepts.
+type field struct {
+	name     string
+	min, max int
+	names    map[string]int
+}
+
+var fields = [5]field{
+	{"minute", 0, 59, nil},
+	{"hour", 0, 23, nil},
+	{"day of month", 1, 31, nil},
+	{"month", 1, 12, monthNames},
+	// 7 is accepted for Sunday and folded onto 0
+	{"day of week", 0, 7, dayNames},
+}
+
+// Parse parses a cron expression of the form "minute hour day-of-month month
+// day-of-week". Each field is *, a value, a range a-b, or a list of these
+// separated by commas, optionally with a step (*/15, 1-30/5). Months and
+// days of the week may be given by their three-letter English names. The
+// descriptors @yearly, @monthly, @weekly, @daily and @hourly are accepted as
+// well.
+func Parse(expr string) (*Schedule, error) {
+	spec := strings.TrimSpace(expr)
+	if d, ok := descriptors[strings.ToLower(spec)]; ok {
+		spec = d
+	}
+	parts := strings.Fields(spec)
+	if len(parts) != len(fields) {
+		return nil, fmt.Errorf("cron expression %q must have 5 fields, found %d", expr, len(parts))
+	}
+
+	var bits [5]uint64
+	for i, part := range parts {
+		b, err := parseField(part, fields[i])
+		if err != nil {
+			return nil, fmt.Errorf("cron expression %q: %v", expr, err)
+		}
+		bits[i] = b
+	}
+	if bits[4]&(1<<7) != 0 {
+		bits[4] = bits[4]&^(1<<7) | 1
+	}
+
+	return &Schedule{
+		minute:  bits[0],
+		hour:    bits[1],
+		dom:     bits[2],
+		month:   bits[3],
+		dow:     bits[4],
+		domStar: strings.HasPrefix(parts[2], "*"),
+		dowStar: strings.HasPrefix(parts[4], "*"),
+		expr:    expr,
+	}, nil
+}
+
+// parseField returns the values of a field as a bit set.
+func parseField(s string, f field) (uint64, error) {
+	var bits uint64
+	for _, item := range strings.Split(s, ",") {
+		rng, stepStr, hasStep := strings.Cut(item, "/")
+		step := 1
+		if hasStep {
+			n, err := strconv.Atoi(stepStr)
+			if err != nil || n < 1 {
+				return 0, fmt.Errorf("invalid step %q in %s field", stepStr, f.name)
+			}
+			step = n
+		}
+
+		var lo, hi int
+		if rng == "*" {
+			lo, hi = f.min, f.max
+		} else {
+			start, end, isRange := strings.Cut(rng, "-")
+			var err error
+			if lo, err = parseValue(start, f); err != nil {
+				return 0, err
+			}
+			hi = lo
+			if isRange {
+				if hi, err = parseValue(end, f); err != nil {
+					return 0, err
+				}
+			} else if hasStep {
+				// A step on a single value runs to the end of the range
+				hi = f.max
+			}
+			if hi < lo {
+				return 0, fmt.Errorf("invalid range %q in %s field", rng, f.name)
+			}
+		}
+
+		for v := lo; v <= hi; v += step {
+			bits |= 1 << v
+		}
+	}
+	return bits, nil
+}
+
+func parseValue(s string, f field) (int, error) {
+	if v, ok := f.names[strings.ToLower(s)]; ok {
+		return v, nil
+	}
+	v, err := strconv.Atoi(s)
+	if err != nil || v < f.min || v > f.max {
+		return 0, fmt.Errorf("invalid value %q in %s field, expected %d-%d", s, f.name, f.min, f.max)
+	}
+	return v, nil
+}
+
+// String returns the expression the schedule was parsed from.
+func (s *Schedule) String() string {
+	return s.expr
+}
+
+// Next returns the first activation of the schedule after t, in t's
+// location. It returns the zero time if the schedule doesn't fire within
+// the next five years. Times skipped when daylight saving time starts
+// don't fire, and times repeated when it ends fire only the first time.
+func (s *Schedule) Next(t time.Time) time.Time {
+	loc := t.Location()
+	t = t.Truncate(time.Minute).Add(time.Minute)
+	limit := t.Add(maxLookahead)
+
+	for t.Before(limit) {
+		switch {
+		case s.month&(1<<uint(t.Month())) == 0:
+			t = advance(t, time.Date(t.Year(), t.Month()+1, 1, 0, 0, 0, 0, loc))
+		case !s.dayMatches(t):
+			t = advance(t, time.Date(t.Year(), t.Month(), t.Day()+1, 0, 0, 0, 0, loc))
+		case s.hour&(1<<uint(t.Hour())) == 0:
+			t = advance(t, time.Date(t.Year(), t.Month(), t.Day(), t.Hour()+1, 0, 0, 0, loc))
+		case s.minute&(1<<uint(t.Minute())) == 0:
+			next := t.Add(time.Minute)
+			if wallClock(next).Before(wallClock(t)) {
+				// The clock was set back; skip the repeated hour
+				next = advance(t, time.Date(t.Year(), t.Month(), t.Day(), t.Hour()+1, 0, 0, 0, loc))
+			}
+			t = next
+		default:
+			return t
+		}
+	}
+	return time.Time{}
+}
+
+// advance returns next, a local time after t. A local time skipped by a
+// daylight saving time change may be normalized to a time before t; the
+// first whole hour after t is returned instead then.
+func advance(t, next time.Time) time.Time {
+	if next.After(t) {
+		return next
+	}
+	return t.Add(time.Duration(60-t.Minute()) * time.Minute)
+}
+
+// wallClock returns the local date and time of t as if it were UTC.
+func wallClock(t time.Time) time.Time {
+	return time.Date(t.Year(), t.Month(), t.Day(), t.Hour(), t.Minute(), 0, 0, time.UTC)
+}
+
+func (s *Schedule) dayMatches(t time.Time) bool {
+	dom := s.dom&(1<<uint(t.Day())) != 0
+	dow := s.dow&(1<<uint(t.Weekday())) != 0
+	if s.domStar || s.dowStar {
+		return dom && dow
+	}
+	return dom || dow
+}
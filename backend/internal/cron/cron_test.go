@@ -0,0 +1,167 @@
+package cron
+
+import (
+	"testing"
+	"time"
+)
+
+// at parses a local time in loc.
+func at(t *testing.T, loc *time.Location, value string) time.Time {
+	t.Helper()
+	v, err := time.ParseInLocation("2006-01-02 15:04", value, loc)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return v
+}
+
+func TestParseInvalid(t *testing.T) {
+	for _, expr := range []string{
+		"",
+		"* * * *",
+		"* * * * * *",
+		"60 * * * *",
+		"* 24 * * *",
+		"* * 0 * *",
+		"* * 32 * *",
+		"* * * 13 *",
+		"* * * * 8",
+		"*/0 * * * *",
+		"*/x * * * *",
+		"5-1 * * * *",
+		"1-x * * * *",
+		"* * * foo *",
+		"* * * * monday",
+		"@every 5m",
+	} {
+		if _, err := Parse(expr); err == nil {
+			t.Errorf("Parse(%q) succeeded", expr)
+		}
+	}
+}
+
+func TestNext(t *testing.T) {
+	tests := []struct {
+		expr string
+		from string
+		want []string
+	}{
+		{"*/15 * * * *", "2025-01-01 10:07", []string{"2025-01-01 10:15", "2025-01-01 10:30", "2025-01-01 10:45", "2025-01-01 11:00"}},
+		{"5-20/5 * * * *", "2025-01-01 10:20", []string{"2025-01-01 11:05", "2025-01-01 11:10"}},
+		{"10/20 3 * * *", "2025-01-01 00:00", []string{"2025-01-01 03:10", "2025-01-01 03:30", "2025-01-01 03:50", "2025-01-02 03:10"}},
+		{"0 9-17/4,22 * * *", "2025-01-01 08:00", []string{"2025-01-01 09:00", "2025-01-01 13:00", "2025-01-01 17:00", "2025-01-01 22:00"}},
+		{"0 0 1 jan,JUL *", "2025-02-01 00:00", []string{"2025-07-01 00:00", "2026-01-01 00:00"}},
+		{"0 0 * * mon-wed", "2025-01-02 00:00", []string{"2025-01-06 00:00", "2025-01-07 00:00", "2025-01-08 00:00", "2025-01-13 00:00"}},
+		// 7 and 0 are both Sunday
+		{"0 0 * * 7", "2025-01-01 00:00", []string{"2025-01-05 00:00", "2025-01-12 00:00"}},
+		{"0 0 * * 5-7", "2025-01-01 00:00", []string{"2025-01-03 00:00", "2025-01-04 00:00", "2025-01-05 00:00", "2025-01-10 00:00"}},
+		// With both day fields restricted, either one matches
+		{"0 0 13 * fri", "2025-06-01 00:00", []string{"2025-06-06 00:00", "2025-06-13 00:00", "2025-06-20 00:00", "2025-06-27 00:00", "2025-07-04 00:00", "2025-07-11 00:00", "2025-07-13 00:00"}},
+		// With one of them unrestricted, only the other one counts
+		{"0 0 13 * *", "2025-06-01 00:00", []string{"2025-06-13 00:00", "2025-07-13 00:00"}},
+		// As in Vixie cron, a stepped * is unrestricted too
+		{"0 0 */10 * fri", "2025-06-01 00:00", []string{"2025-07-11 00:00", "2025-08-01 00:00"}},
+		{"0 0 * */6 *", "2025-06-30 12:00", []string{"2025-07-01 00:00", "2025-07-02 00:00"}},
+		{"0 0 29 2 *", "2025-01-01 00:00", []string{"2028-02-29 00:00", "2032-02-29 00:00"}},
+		{"0 0 31 * *", "2025-01-31 00:00", []string{"2025-03-31 00:00", "2025-05-31 00:00"}},
+		{"@hourly", "2025-01-01 10:00", []string{"2025-01-01 11:00"}},
+		{"@weekly", "2025-01-01 10:00", []string{"2025-01-05 00:00"}},
+		{"@Yearly", "2025-01-01 00:00", []string{"2026-01-01 00:00"}},
+	}
+	for _, tt := range tests {
+		s, err := Parse(tt.expr)
+		if err != nil {
+			t.Errorf("Parse(%q): %v", tt.expr, err)
+			continue
+		}
+		next := at(t, time.UTC, tt.from)
+		for _, want := range tt.want {
+			next = s.Next(next)
+			if !next.Equal(at(t, time.UTC, want)) {
+				t.Errorf("%q: next = %s, want %s", tt.expr, next.Format("2006-01-02 15:04"), want)
+				break
+			}
+		}
+	}
+}
+
+func TestNextSkipsSeconds(t *testing.T) {
+	s, _ := Parse("* * * * *")
+	from := time.Date(2025, 1, 1, 10, 0, 59, 999, time.UTC)
+	if got, want := s.Next(from), time.Date(2025, 1, 1, 10, 1, 0, 0, time.UTC); !got.Equal(want) {
+		t.Errorf("Next(%s) = %s, want %s", from, got, want)
+	}
+}
+
+func TestNextNeverFires(t *testing.T) {
+	for _, expr := range []string{"0 0 30 2 *", "0 0 31 4,6,9,11 *"} {
+		s, err := Parse(expr)
+		if err != nil {
+			t.Fatalf("Parse(%q): %v", expr, err)
+		}
+		if next := s.Next(time.Now()); !next.IsZero() {
+			t.Errorf("%q: next = %s, want never", expr, next)
+		}
+	}
+}
+
+func TestNextDaylightSavingTime(t *testing.T) {
+	loc, err := time.LoadLocation("America/New_York")
+	if err != nil {
+		t.Skip("time zone database not available:", err)
+	}
+	tests := []struct {
+		name string
+		expr string
+		from string
+		want []time.Time
+	}{
+		{
+			// 02:30 doesn't exist on 2025-03-09
+			name: "skipped time",
+			expr: "30 2 * * *",
+			from: "2025-03-08 12:00",
+			want: []time.Time{at(t, loc, "2025-03-10 02:30")},
+		},
+		{
+			name: "hourly across the gap",
+			expr: "0 * * * *",
+			from: "2025-03-09 00:30",
+			want: []time.Time{at(t, loc, "2025-03-09 01:00"), at(t, loc, "2025-03-09 03:00"), at(t, loc, "2025-03-09 04:00")},
+		},
+		{
+			// 01:30 happens twice on 2025-11-02, first in EDT
+			name: "repeated time",
+			expr: "30 1 * * *",
+			from: "2025-11-02 00:00",
+			want: []time.Time{
+				time.Date(2025, 11, 2, 5, 30, 0, 0, time.UTC),
+				time.Date(2025, 11, 3, 6, 30, 0, 0, time.UTC),
+			},
+		},
+		{
+			name: "hourly across the overlap",
+			expr: "0 * * * *",
+			from: "2025-11-02 00:30",
+			want: []time.Time{
+				time.Date(2025, 11, 2, 5, 0, 0, 0, time.UTC), // 01:00 EDT
+				time.Date(2025, 11, 2, 7, 0, 0, 0, time.UTC), // 02:00 EST
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s, err := Parse(tt.expr)
+			if err != nil {
+				t.Fatal(err)
+			}
+			next := at(t, loc, tt.from)
+			for _, want := range tt.want {
+				next = s.Next(next)
+				if !next.Equal(want) {
+					t.Fatalf("next = %s, want %s", next, want.In(loc))
+				}
+			}
+		})
+	}
+}
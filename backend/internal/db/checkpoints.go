@@ -0,0 +1,57 @@
+package db
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+)
+
+// SyncCheckpoint is the progress of an interrupted catalog sync of a source.
+type SyncCheckpoint struct {
+	// Page is the page of search results the sync resumes at.
+	Page int
+	// Key describes the discovery settings Page refers to, such as the
+	// page size.
+	Key string
+	// StartedAt is when the interrupted sync started at the first page.
+	StartedAt time.Time
+	// UpdatedAt is when the sync got to Page.
+	UpdatedAt time.Time
+}
+
+// GetSyncCheckpoint returns where a catalog sync of source stopped. It
+// returns a checkpoint at page 0 if there is none.
+func GetSyncCheckpoint(source string) (SyncCheckpoint, error) {
+	var cp SyncCheckpoint
+	err := db.QueryRow("SELECT page, discovery_key, started_at, updated_at FROM sync_checkpoints WHERE source = $1", source).Scan(&cp.Page, &cp.Key, &cp.StartedAt, &cp.UpdatedAt)
+	if errors.Is(err, sql.ErrNoRows) {
+		return SyncCheckpoint{}, nil
+	}
+	if err != nil {
+		return SyncCheckpoint{}, fmt.Errorf("failed to get sync checkpoint of %s: %v", source, err)
+	}
+	return cp, nil
+}
+
+// SaveSyncCheckpoint records that a catalog sync of source, which started at
+// the first page at startedAt, should resume at page. key describes the
+// discovery settings page refers to.
+func SaveSyncCheckpoint(source string, page int, key string, startedAt time.Time) error {
+	_, err := db.Exec(`INSERT INTO sync_checkpoints (source, page, discovery_key, started_at, updated_at) VALUES ($1, $2, $3, $4, $5)
+		ON CONFLICT (source) DO UPDATE SET page = EXCLUDED.page, discovery_key = EXCLUDED.discovery_key, started_at = EXCLUDED.started_at, updated_at = EXCLUDED.updated_at`,
+		source, page, key, startedAt, time.Now())
+	if err != nil {
+		return fmt.Errorf("failed to save sync checkpoint of %s: %v", source, err)
+	}
+	return nil
+}
+
+// ClearSyncCheckpoint removes the checkpoint of source once a sync of it
+// completed.
+func ClearSyncCheckpoint(source string) error {
+	if _, err := db.Exec("DELETE FROM sync_checkpoints WHERE source = $1", source); err != nil {
+		return fmt.Errorf("failed to clear sync checkpoint of %s: %v", source, err)
+	}
+	return nil
+}
@@ -0,0 +1,152 @@
+package db
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"github.com/ahasunos/caas/backend/internal/models"
+)
+
+const controlColumns = "c.profile_id, p.name, c.control_id, c.title, c.description, c.impact, c.tags, c.refs, c.file, c.commit_sha"
+
+// controlSearch is the text search document of a control. It must match the
+// expression of the profile_controls_search_idx index.
+const controlSearch = "to_tsvector('english', c.control_id || ' ' || c.title || ' ' || c.description)"
+
+// ControlFilter narrows down the controls returned by ListControls. Zero
+// values are ignored.
+type ControlFilter struct {
+	ProfileID int
+	// Query is matched against the ID, title and description of controls
+	// using full-text search.
+	Query string
+	// Tag is a tag name, or name:value to match a tag's value or one of
+	// the values of a list tag.
+	Tag       string
+	ImpactMin *float64
+	Limit     int
+}
+
+// ReplaceProfileControls replaces the indexed controls of a profile with the
+// controls read from its checkout at commit sha, and records the profile's
+// fingerprint at that commit.
+func ReplaceProfileControls(profileID int, sha, fingerprint string, controls []models.Control) error {
+	tx, err := db.Begin()
+	if err != nil {
+		return fmt.Errorf("failed to start transaction: %v", err)
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.Exec("DELETE FROM profile_controls WHERE profile_id = $1", profileID); err != nil {
+		return fmt.Errorf("failed to delete controls of profile %d: %v", profileID, err)
+	}
+
+	stmt, err := tx.Prepare(`INSERT INTO profile_controls (profile_id, control_id, title, description, impact, tags, refs, file, commit_sha)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9)`)
+	if err != nil {
+		return fmt.Errorf("failed to prepare control insert: %v", err)
+	}
+	defer stmt.Close()
+
+	for _, c := range controls {
+		tags, err := json.Marshal(c.Tags)
+		if err != nil {
+			return fmt.Errorf("failed to encode tags of control %s: %v", c.ID, err)
+		}
+		refs, err := json.Marshal(c.Refs)
+		if err != nil {
+			return fmt.Errorf("failed to encode refs of control %s: %v", c.ID, err)
+		}
+		if _, err := stmt.Exec(profileID, c.ID, c.Title, c.Desc, c.Impact, tags, refs, c.File, sha); err != nil {
+			return fmt.Errorf("failed to insert control %s: %v", c.ID, err)
+		}
+	}
+
+	if _, err := tx.Exec("UPDATE inspec_profiles SET controls_sha = $1, fingerprint = $2 WHERE id = $3", sha, fingerprint, profileID); err != nil {
+		return fmt.Errorf("failed to update controls commit of profile %d: %v", profileID, err)
+	}
+	return tx.Commit()
+}
+
+// GetControlsCommit returns the commit of a profile whose controls are
+// indexed, or an empty string if they haven't been indexed yet.
+func GetControlsCommit(profileID int) (string, error) {
+	var sha string
+	err := db.QueryRow("SELECT controls_sha FROM inspec_profiles WHERE id = $1", profileID).Scan(&sha)
+	if err != nil {
+		return "", fmt.Errorf("failed to get controls commit of profile %d: %v", profileID, err)
+	}
+	return sha, nil
+}
+
+// ListControls returns indexed controls matching filter. Search results are
+// ordered by relevance, everything else by impact, highest first.
+func ListControls(filter ControlFilter) ([]models.Control, error) {
+	var conditions []string
+	var args []interface{}
+	addCondition := func(condition string, arg interface{}) {
+		args = append(args, arg)
+		conditions = append(conditions, fmt.Sprintf(condition, len(args)))
+	}
+
+	if filter.ProfileID != 0 {
+		addCondition("c.profile_id = $%d", filter.ProfileID)
+	}
+	if filter.Query != "" {
+		addCondition("("+controlSearch+" @@ plainto_tsquery('english', $%[1]d) OR c.control_id ILIKE '%%' || $%[1]d || '%%')", filter.Query)
+	}
+	if filter.Tag != "" {
+		if name, value, ok := strings.Cut(filter.Tag, ":"); ok {
+			args = append(args, name, value)
+			conditions = append(conditions, fmt.Sprintf("(c.tags->>$%[1]d::text = $%[2]d::text OR c.tags->$%[1]d::text ? $%[2]d::text)", len(args)-1, len(args)))
+		} else {
+			addCondition("c.tags ? $%d::text", filter.Tag)
+		}
+	}
+	if filter.ImpactMin != nil {
+		addCondition("c.impact >= $%d", *filter.ImpactMin)
+	}
+
+	query := "SELECT " + controlColumns + " FROM profile_controls c JOIN inspec_profiles p ON p.id = c.profile_id"
+	if len(conditions) > 0 {
+		query += " WHERE " + strings.Join(conditions, " AND ")
+	}
+	if filter.Query != "" {
+		args = append(args, filter.Query)
+		query += fmt.Sprintf(" ORDER BY ts_rank(%s, plainto_tsquery('english', $%d)) DESC, c.impact DESC, c.id", controlSearch, len(args))
+	} else {
+		query += " ORDER BY c.impact DESC, c.id"
+	}
+	if filter.Limit > 0 {
+		args = append(args, filter.Limit)
+		query += fmt.Sprintf(" LIMIT $%d", len(args))
+	}
+
+	rows, err := db.Query(query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query controls: %v", err)
+	}
+	defer rows.Close()
+
+	controls := []models.Control{}
+	for rows.Next() {
+		var c models.Control
+		var tags, refs []byte
+		if err := rows.Scan(&c.ProfileID, &c.ProfileName, &c.ID, &c.Title, &c.Desc, &c.Impact, &tags, &refs, &c.File, &c.CommitSHA); err != nil {
+			return nil, fmt.Errorf("failed to scan control: %v", err)
+		}
+		if tags != nil {
+			if err := json.Unmarshal(tags, &c.Tags); err != nil {
+				return nil, fmt.Errorf("failed to decode tags of control %s: %v", c.ID, err)
+			}
+		}
+		if refs != nil {
+			if err := json.Unmarshal(refs, &c.Refs); err != nil {
+				return nil, fmt.Errorf("failed to decode refs of control %s: %v", c.ID, err)
+			}
+		}
+		controls = append(controls, c)
+	}
+	return controls, rows.Err()
+}
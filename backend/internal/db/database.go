@@ -11,17 +11,12 @@ import (
 // Database connection details
 var db *sql.DB
 
-func InitDB() error {
-	var err error
-	// db, err = sql.Open("postgres", "host=localhost port=5432 user=postgres password=password123 dbname=inspec sslmode=disable")
-	db, err = sql.Open("postgres", "host=inspec-postgres port=5432 user=postgres password=password123 dbname=inspec sslmode=disable")
-
-	if err != nil {
-		log.Fatalf("Could not connect to database: %v", err)
-	}
-
-	// Create table if it doesn't exist
-	createTableQuery := `
+// schema lists the statements that create the tables used by the service.
+var schema = []struct {
+	table string
+	query string
+}{
+	{"inspec_profiles", `
 	CREATE TABLE IF NOT EXISTS inspec_profiles (
 	    id SERIAL PRIMARY KEY,
 	    name VARCHAR(255) NOT NULL,
@@ -29,13 +24,173 @@ func InitDB() error {
 	    description TEXT,
 	    stars INT DEFAULT 0,
 	    last_updated TIMESTAMP DEFAULT CURRENT_TIMESTAMP
-	);`
+	);
+	ALTER TABLE inspec_profiles ADD COLUMN IF NOT EXISTS commit_sha VARCHAR(40) NOT NULL DEFAULT '';
+	ALTER TABLE inspec_profiles ADD COLUMN IF NOT EXISTS metadata JSONB;
+	ALTER TABLE inspec_profiles ADD COLUMN IF NOT EXISTS controls_sha VARCHAR(40) NOT NULL DEFAULT '';
+	ALTER TABLE inspec_profiles ADD COLUMN IF NOT EXISTS repo_id BIGINT;
+	ALTER TABLE inspec_profiles ADD COLUMN IF NOT EXISTS status VARCHAR(16) NOT NULL DEFAULT 'active';
+	ALTER TABLE inspec_profiles ADD COLUMN IF NOT EXISTS status_reason TEXT NOT NULL DEFAULT '';
+	ALTER TABLE inspec_profiles ADD COLUMN IF NOT EXISTS seen_at TIMESTAMP;
+	ALTER TABLE inspec_profiles ADD COLUMN IF NOT EXISTS source VARCHAR(255) NOT NULL DEFAULT '';
+	ALTER TABLE inspec_profiles ADD COLUMN IF NOT EXISTS fork BOOLEAN NOT NULL DEFAULT false;
+	ALTER TABLE inspec_profiles ADD COLUMN IF NOT EXISTS parent_url TEXT NOT NULL DEFAULT '';
+	ALTER TABLE inspec_profiles ADD COLUMN IF NOT EXISTS fingerprint VARCHAR(64) NOT NULL DEFAULT '';
+	ALTER TABLE inspec_profiles ADD COLUMN IF NOT EXISTS canonical_id INT REFERENCES inspec_profiles(id) ON DELETE SET NULL;
+	CREATE INDEX IF NOT EXISTS inspec_profiles_repo_id_idx ON inspec_profiles (repo_id);
+	ALTER TABLE inspec_profiles ADD COLUMN IF NOT EXISTS review_status VARCHAR(16) NOT NULL DEFAULT 'pending';
+	ALTER TABLE inspec_profiles ADD COLUMN IF NOT EXISTS reviewer VARCHAR(255) NOT NULL DEFAULT '';
+	ALTER TABLE inspec_profiles ADD COLUMN IF NOT EXISTS review_notes TEXT NOT NULL DEFAULT '';
+	ALTER TABLE inspec_profiles ADD COLUMN IF NOT EXISTS reviewed_sha VARCHAR(40) NOT NULL DEFAULT '';
+	ALTER TABLE inspec_profiles ADD COLUMN IF NOT EXISTS reviewed_at TIMESTAMP;
+	ALTER TABLE inspec_profiles ADD COLUMN IF NOT EXISTS owner VARCHAR(255) NOT NULL DEFAULT '';
+	-- Profiles synced before the owner was stored; the next sync corrects
+	-- the owner of nested GitLab groups
+	UPDATE inspec_profiles SET owner = split_part(url, '/', 4) WHERE owner = '' AND url LIKE 'https://%';
+	CREATE INDEX IF NOT EXISTS inspec_profiles_owner_idx ON inspec_profiles (lower(owner));
+	CREATE INDEX IF NOT EXISTS inspec_profiles_canonical_id_idx ON inspec_profiles (canonical_id);
+	CREATE INDEX IF NOT EXISTS inspec_profiles_search_idx ON inspec_profiles
+	    USING GIN (to_tsvector('english', name || ' ' || COALESCE(description, '')));`},
+	{"jobs", `
+	CREATE TABLE IF NOT EXISTS jobs (
+	    id SERIAL PRIMARY KEY,
+	    status VARCHAR(32) NOT NULL,
+	    hostname VARCHAR(255) NOT NULL,
+	    username VARCHAR(255) NOT NULL,
+	    profile TEXT NOT NULL,
+	    exit_code INT,
+	    output TEXT NOT NULL DEFAULT '',
+	    error TEXT NOT NULL DEFAULT '',
+	    created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+	    started_at TIMESTAMP,
+	    finished_at TIMESTAMP
+	);
+	ALTER TABLE jobs ADD COLUMN IF NOT EXISTS result JSONB;
+	ALTER TABLE jobs ADD COLUMN IF NOT EXISTS timeout_seconds INT NOT NULL DEFAULT 0;
+	ALTER TABLE jobs ADD COLUMN IF NOT EXISTS engine VARCHAR(64) NOT NULL DEFAULT 'inspec';
+	ALTER TABLE jobs ADD COLUMN IF NOT EXISTS engine_version VARCHAR(64) NOT NULL DEFAULT '';
+	ALTER TABLE jobs ADD COLUMN IF NOT EXISTS ref VARCHAR(255) NOT NULL DEFAULT '';
+	ALTER TABLE jobs ADD COLUMN IF NOT EXISTS commit_sha VARCHAR(40) NOT NULL DEFAULT '';
+	ALTER TABLE jobs ADD COLUMN IF NOT EXISTS profile_version VARCHAR(64) NOT NULL DEFAULT '';
+	ALTER TABLE jobs ADD COLUMN IF NOT EXISTS instance VARCHAR(255) NOT NULL DEFAULT '';`},
+	{"runs", `
+	CREATE TABLE IF NOT EXISTS runs (
+	    id SERIAL PRIMARY KEY,
+	    job_id INT REFERENCES jobs(id) ON DELETE SET NULL,
+	    hostname VARCHAR(255) NOT NULL,
+	    profile TEXT NOT NULL,
+	    inspec_version VARCHAR(64) NOT NULL DEFAULT '',
+	    platform JSONB,
+	    profiles JSONB,
+	    duration DOUBLE PRECISION NOT NULL DEFAULT 0,
+	    total INT NOT NULL DEFAULT 0,
+	    passed INT NOT NULL DEFAULT 0,
+	    failed INT NOT NULL DEFAULT 0,
+	    skipped INT NOT NULL DEFAULT 0,
+	    created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP
+	);
+	ALTER TABLE runs ADD COLUMN IF NOT EXISTS engine VARCHAR(64) NOT NULL DEFAULT 'inspec';
+	ALTER TABLE runs ADD COLUMN IF NOT EXISTS engine_version VARCHAR(64) NOT NULL DEFAULT '';
+	ALTER TABLE runs ADD COLUMN IF NOT EXISTS commit_sha VARCHAR(40) NOT NULL DEFAULT '';
+	ALTER TABLE runs ADD COLUMN IF NOT EXISTS profile_version VARCHAR(64) NOT NULL DEFAULT '';
+	-- created_at holds UTC whatever the time zone of the session
+	ALTER TABLE runs ALTER COLUMN created_at SET DEFAULT (now() AT TIME ZONE 'UTC');
+	CREATE INDEX IF NOT EXISTS runs_hostname_created_at_idx ON runs (hostname, created_at DESC);
+	CREATE INDEX IF NOT EXISTS runs_profile_created_at_idx ON runs (profile, created_at DESC);`},
+	{"control_results", `
+	CREATE TABLE IF NOT EXISTS control_results (
+	    id SERIAL PRIMARY KEY,
+	    run_id INT NOT NULL REFERENCES runs(id) ON DELETE CASCADE,
+	    profile_name VARCHAR(255) NOT NULL,
+	    control_id TEXT NOT NULL,
+	    title TEXT NOT NULL DEFAULT '',
+	    description TEXT NOT NULL DEFAULT '',
+	    impact DOUBLE PRECISION NOT NULL DEFAULT 0,
+	    status VARCHAR(16) NOT NULL,
+	    tags JSONB,
+	    results JSONB
+	);
+	CREATE INDEX IF NOT EXISTS control_results_run_id_idx ON control_results (run_id);`},
+	{"profile_controls", `
+	CREATE TABLE IF NOT EXISTS profile_controls (
+	    id SERIAL PRIMARY KEY,
+	    profile_id INT NOT NULL REFERENCES inspec_profiles(id) ON DELETE CASCADE,
+	    control_id TEXT NOT NULL,
+	    title TEXT NOT NULL DEFAULT '',
+	    description TEXT NOT NULL DEFAULT '',
+	    impact DOUBLE PRECISION NOT NULL DEFAULT 0,
+	    tags JSONB,
+	    refs JSONB,
+	    file TEXT NOT NULL DEFAULT '',
+	    commit_sha VARCHAR(40) NOT NULL DEFAULT '',
+	    UNIQUE (profile_id, control_id)
+	);
+	CREATE INDEX IF NOT EXISTS profile_controls_search_idx ON profile_controls
+	    USING GIN (to_tsvector('english', control_id || ' ' || title || ' ' || description));
+	CREATE INDEX IF NOT EXISTS profile_controls_tags_idx ON profile_controls USING GIN (tags);`},
+	{"sync_runs", `
+	CREATE TABLE IF NOT EXISTS sync_runs (
+	    id SERIAL PRIMARY KEY,
+	    status VARCHAR(32) NOT NULL,
+	    trigger VARCHAR(32) NOT NULL,
+	    pages INT NOT NULL DEFAULT 0,
+	    repositories INT NOT NULL DEFAULT 0,
+	    duplicates INT NOT NULL DEFAULT 0,
+	    discovered INT NOT NULL DEFAULT 0,
+	    added INT NOT NULL DEFAULT 0,
+	    updated INT NOT NULL DEFAULT 0,
+	    removed INT NOT NULL DEFAULT 0,
+	    error TEXT NOT NULL DEFAULT '',
+	    started_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+	    finished_at TIMESTAMP
+	);
+	ALTER TABLE sync_runs ADD COLUMN IF NOT EXISTS sources TEXT[] NOT NULL DEFAULT '{}';
+	ALTER TABLE sync_runs ADD COLUMN IF NOT EXISTS excluded INT NOT NULL DEFAULT 0;
+	-- started_at holds UTC whatever the time zone of the session
+	ALTER TABLE sync_runs ALTER COLUMN started_at SET DEFAULT (now() AT TIME ZONE 'UTC');`},
+	{"sync_checkpoints", `
+	CREATE TABLE IF NOT EXISTS sync_checkpoints (
+	    source VARCHAR(255) PRIMARY KEY,
+	    page INT NOT NULL,
+	    updated_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP
+	);
+	ALTER TABLE sync_checkpoints ADD COLUMN IF NOT EXISTS started_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP;
+	ALTER TABLE sync_checkpoints ADD COLUMN IF NOT EXISTS discovery_key TEXT NOT NULL DEFAULT '';`},
+	{"profile_reviews", `
+	CREATE TABLE IF NOT EXISTS profile_reviews (
+	    id SERIAL PRIMARY KEY,
+	    profile_id INT NOT NULL REFERENCES inspec_profiles(id) ON DELETE CASCADE,
+	    status VARCHAR(16) NOT NULL,
+	    reviewer VARCHAR(255) NOT NULL,
+	    notes TEXT NOT NULL DEFAULT '',
+	    commit_sha VARCHAR(40) NOT NULL DEFAULT '',
+	    created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP
+	);
+	CREATE INDEX IF NOT EXISTS profile_reviews_profile_id_idx ON profile_reviews (profile_id);`},
+}
+
+// Use makes the package use conn instead of the database opened by InitDB,
+// for example a mock database in tests.
+func Use(conn *sql.DB) {
+	db = conn
+}
+
+func InitDB() error {
+	var err error
+	// db, err = sql.Open("postgres", "host=localhost port=5432 user=postgres password=password123 dbname=inspec sslmode=disable")
+	db, err = sql.Open("postgres", "host=inspec-postgres port=5432 user=postgres password=password123 dbname=inspec sslmode=disable")
 
-	_, err = db.Exec(createTableQuery)
 	if err != nil {
-		log.Fatal("Error creating table:", err)
+		log.Fatalf("Could not connect to database: %v", err)
+	}
+
+	// Create tables if they don't exist
+	for _, s := range schema {
+		if _, err = db.Exec(s.query); err != nil {
+			log.Fatalf("Error creating table %s: %v", s.table, err)
+		}
+		fmt.Printf("Table '%s' ensured to exist.\n", s.table)
 	}
 
-	fmt.Println("Table 'inspec_profiles' ensured to exist.")
 	return db.Ping()
 }
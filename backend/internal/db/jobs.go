@@ -0,0 +1,102 @@
+package db
+
+import (
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/ahasunos/caas/backend/internal/models"
+)
+
+const jobColumns = "id, status, hostname, username, profile, ref, commit_sha, profile_version, engine, engine_version, timeout_seconds, exit_code, output, error, result, created_at, started_at, finished_at"
+
+// CreateJob inserts a new queued job and fills in its ID and creation time.
+func CreateJob(job *models.Job) error {
+	job.Status = models.JobQueued
+	err := db.QueryRow("INSERT INTO jobs (status, hostname, username, profile, ref, commit_sha, engine, timeout_seconds, instance) VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9) RETURNING id, created_at",
+		job.Status, job.Hostname, job.Username, job.Profile, job.Ref, job.CommitSHA, job.Engine, job.TimeoutSeconds, job.Instance).Scan(&job.ID, &job.CreatedAt)
+	if err != nil {
+		return fmt.Errorf("failed to insert job into the database: %v", err)
+	}
+	return nil
+}
+
+// MarkJobRunning records that the job has started executing.
+func MarkJobRunning(id int) error {
+	_, err := db.Exec("UPDATE jobs SET status = $1, started_at = $2 WHERE id = $3", models.JobRunning, time.Now(), id)
+	if err != nil {
+		return fmt.Errorf("failed to mark job %d as running: %v", id, err)
+	}
+	return nil
+}
+
+// FinishJob records the terminal status, exit code, raw output, error,
+// profile revision, engine version and parsed results of a job. A nil
+// ExitCode means the process never produced one and a nil Result means no
+// report could be read.
+func FinishJob(job models.Job) error {
+	var resultJSON []byte
+	if job.Result != nil {
+		var err error
+		if resultJSON, err = json.Marshal(job.Result); err != nil {
+			return fmt.Errorf("failed to encode result of job %d: %v", job.ID, err)
+		}
+	}
+
+	_, err := db.Exec(`UPDATE jobs SET status = $1, exit_code = $2, output = $3, error = $4, result = $5, engine_version = $6,
+		commit_sha = $7, profile_version = $8, finished_at = $9 WHERE id = $10`,
+		job.Status, job.ExitCode, job.Output, job.Error, resultJSON, job.EngineVersion, job.CommitSHA, job.ProfileVersion, time.Now(), job.ID)
+	if err != nil {
+		return fmt.Errorf("failed to finish job %d: %v", job.ID, err)
+	}
+	return nil
+}
+
+// GetJob returns the job with the given ID. It returns sql.ErrNoRows if the
+// job does not exist.
+func GetJob(id int) (models.Job, error) {
+	row := db.QueryRow("SELECT "+jobColumns+" FROM jobs WHERE id = $1", id)
+	return scanJob(row)
+}
+
+// FailInterruptedJobs marks jobs of the given instance that were queued or
+// running when it stopped as failed, since their executions were lost with
+// the process. Jobs of other instances are left alone; jobs recorded before
+// instances were belong to no instance and are failed by any.
+func FailInterruptedJobs(instance string) (int64, error) {
+	res, err := db.Exec("UPDATE jobs SET status = $1, error = $2, finished_at = $3 WHERE status IN ($4, $5) AND instance IN ($6, '')",
+		models.JobFailed, "Execution interrupted by server restart", time.Now(), models.JobQueued, models.JobRunning, instance)
+	if err != nil {
+		return 0, fmt.Errorf("failed to fail interrupted jobs: %v", err)
+	}
+	return res.RowsAffected()
+}
+
+func scanJob(row *sql.Row) (models.Job, error) {
+	var job models.Job
+	var exitCode sql.NullInt64
+	var result []byte
+	var startedAt, finishedAt sql.NullTime
+	err := row.Scan(&job.ID, &job.Status, &job.Hostname, &job.Username, &job.Profile, &job.Ref, &job.CommitSHA, &job.ProfileVersion, &job.Engine, &job.EngineVersion, &job.TimeoutSeconds, &exitCode,
+		&job.Output, &job.Error, &result, &job.CreatedAt, &startedAt, &finishedAt)
+	if err != nil {
+		return models.Job{}, err
+	}
+	if result != nil {
+		if err := json.Unmarshal(result, &job.Result); err != nil {
+			return models.Job{}, fmt.Errorf("failed to decode result of job %d: %v", job.ID, err)
+		}
+	}
+	if exitCode.Valid {
+		code := int(exitCode.Int64)
+		job.ExitCode = &code
+	}
+	if startedAt.Valid {
+		job.StartedAt = &startedAt.Time
+	}
+	if finishedAt.Valid {
+		job.FinishedAt = &finishedAt.Time
+	}
+	return job, nil
+}
@@ -0,0 +1,81 @@
+package db
+
+import (
+	"regexp"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/ahasunos/caas/backend/internal/models"
+)
+
+func TestFinishJob(t *testing.T) {
+	mock := mockDB(t)
+	code := 100
+	job := models.Job{ID: 4, Status: models.JobSucceeded, ExitCode: &code, Output: "log", EngineVersion: "5.22.3",
+		Result: &models.Run{Version: "5.22.3"}}
+
+	mock.ExpectExec(regexp.QuoteMeta("UPDATE jobs SET status = $1")).
+		WithArgs(models.JobSucceeded, 100, "log", "", sqlmock.AnyArg(), "5.22.3", "", "", sqlmock.AnyArg(), 4).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	if err := FinishJob(job); err != nil {
+		t.Fatalf("FinishJob: %v", err)
+	}
+}
+
+func TestFinishJobWithoutExitCodeOrResult(t *testing.T) {
+	mock := mockDB(t)
+	job := models.Job{ID: 5, Status: models.JobFailed, Error: "failed to run inspec"}
+
+	mock.ExpectExec(regexp.QuoteMeta("UPDATE jobs SET status = $1")).
+		WithArgs(models.JobFailed, nil, "", "failed to run inspec", []byte(nil), "", "", "", sqlmock.AnyArg(), 5).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	if err := FinishJob(job); err != nil {
+		t.Fatalf("FinishJob: %v", err)
+	}
+}
+
+func TestGetJob(t *testing.T) {
+	mock := mockDB(t)
+	created := time.Date(2025, 3, 1, 12, 0, 0, 0, time.UTC)
+
+	mock.ExpectQuery(regexp.QuoteMeta("SELECT " + jobColumns + " FROM jobs WHERE id = $1")).
+		WithArgs(6).
+		WillReturnRows(sqlmock.NewRows(jobColumnNames()).
+			AddRow(6, models.JobSucceeded, "web-1", "root", "profile", "", "", "", "inspec", "5.22.3", 60, 101, "log", "",
+				[]byte(`{"version":"5.22.3","summary":{"total":1,"skipped":1}}`), created, created, nil))
+
+	job, err := GetJob(6)
+	if err != nil {
+		t.Fatalf("GetJob: %v", err)
+	}
+	if job.ExitCode == nil || *job.ExitCode != 101 {
+		t.Errorf("exit code = %v, want 101", job.ExitCode)
+	}
+	if job.Result == nil || job.Result.Summary.Skipped != 1 {
+		t.Errorf("result = %+v, want one skipped control", job.Result)
+	}
+	if job.StartedAt == nil || job.FinishedAt != nil {
+		t.Errorf("started at %v and finished at %v, want only a start time", job.StartedAt, job.FinishedAt)
+	}
+}
+
+// jobColumnNames returns the names of jobColumns.
+func jobColumnNames() []string {
+	return regexp.MustCompile(`,\s*`).Split(jobColumns, -1)
+}
+
+func TestFailInterruptedJobsOfInstance(t *testing.T) {
+	mock := mockDB(t)
+
+	mock.ExpectExec(regexp.QuoteMeta("UPDATE jobs SET status = $1, error = $2, finished_at = $3 WHERE status IN ($4, $5) AND instance IN ($6, '')")).
+		WithArgs(models.JobFailed, "Execution interrupted by server restart", sqlmock.AnyArg(), models.JobQueued, models.JobRunning, "api-1").
+		WillReturnResult(sqlmock.NewResult(0, 2))
+	n, err := FailInterruptedJobs("api-1")
+	if err != nil {
+		t.Fatalf("FailInterruptedJobs: %v", err)
+	}
+	if n != 2 {
+		t.Errorf("failed %d jobs, want 2", n)
+	}
+}
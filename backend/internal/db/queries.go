@@ -1,19 +1,26 @@
 package db
 
 import (
+	"database/sql"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
 	"fmt"
 	"log"
+	"strconv"
+	"strings"
 	"time"
 
-	"github.com/ahasunos/caas/backend/internal/github"
 	"github.com/ahasunos/caas/backend/internal/models"
+	"github.com/lib/pq"
 )
 
 // Function to insert profile into the database
 func InsertProfileIntoDatabase(profile models.Profile) error {
-	// Check if the profile already exists in the database
+	// Check if the profile already exists in the database, possibly under
+	// the name its repository had before it was renamed
 	var count int
-	err := db.QueryRow("SELECT COUNT(*) FROM inspec_profiles WHERE url = $1", profile.URL).Scan(&count)
+	err := db.QueryRow("SELECT COUNT(*) FROM inspec_profiles WHERE url = $1 OR "+sameRepo("$2", "$1"), profile.URL, profile.RepoID).Scan(&count)
 	if err != nil {
 		return fmt.Errorf("failed to check if profile exists: %v", err)
 	}
@@ -24,8 +31,12 @@ func InsertProfileIntoDatabase(profile models.Profile) error {
 	}
 
 	// Insert new profile into the database
-	_, err = db.Exec("INSERT INTO inspec_profiles (name, url, description, stars, last_updated) VALUES ($1, $2, $3, $4, $5)",
-		profile.Name, profile.URL, profile.Description, profile.Stars, profile.LastUpdated)
+	metadata, err := marshalMetadata(profile.Metadata)
+	if err != nil {
+		return err
+	}
+	_, err = db.Exec("INSERT INTO inspec_profiles (name, url, description, stars, last_updated, metadata, repo_id, status, source, fork, parent_url, owner) VALUES ($1, $2, $3, $4, $5, $6, NULLIF($7::bigint, 0), $8, $9, $10, $11, $12)",
+		profile.Name, profile.URL, profile.Description, profile.Stars, profile.LastUpdated, metadata, profile.RepoID, profileStatus(profile), profile.Source, profile.Fork, profile.ParentURL, profile.Owner)
 	if err != nil {
 		return fmt.Errorf("failed to insert profile into the database: %v", err)
 	}
@@ -33,61 +44,450 @@ func InsertProfileIntoDatabase(profile models.Profile) error {
 	return nil
 }
 
-// Function to get profiles from database
-func GetProfilesFromDatabase() ([]models.Profile, error) {
-	rows, err := db.Query("SELECT id, name, url, description, stars, last_updated FROM inspec_profiles ORDER BY stars DESC")
+// sameRepo is a condition matching profiles of the repository with the ID
+// given by the repoID parameter on the host of the URL parameter. A zero ID
+// matches nothing.
+func sameRepo(repoID, url string) string {
+	return fmt.Sprintf("(repo_id = %s AND split_part(url, '/', 3) = split_part(%s, '/', 3))", repoID, url)
+}
+
+// profileStatus returns the status of profile, active if it is not set.
+func profileStatus(profile models.Profile) string {
+	if profile.Status == "" {
+		return models.ProfileActive
+	}
+	return profile.Status
+}
+
+// UpsertProfile updates the profile of the same repository, or inserts the
+// profile if there is none. Repositories are matched by their ID, so that a
+// renamed or transferred repository updates the URL and name of its
+// existing profile, and otherwise by URL. A profile that had been removed
+// is restored. The profile's ID, stored commit SHA and fingerprint are
+// filled in from the database. It reports whether the profile was inserted.
+func UpsertProfile(profile *models.Profile) (bool, error) {
+	metadata, err := marshalMetadata(profile.Metadata)
+	if err != nil {
+		return false, err
+	}
+
+	var oldURL string
+	err = db.QueryRow(`SELECT id, url FROM inspec_profiles WHERE url = $1 OR `+sameRepo("$2", "$1")+`
+		ORDER BY `+sameRepo("$2", "$1")+` IS TRUE DESC, status = $3, id LIMIT 1`,
+		profile.URL, profile.RepoID, models.ProfileRemoved).Scan(&profile.ID, &oldURL)
+	if errors.Is(err, sql.ErrNoRows) {
+		err = db.QueryRow(`INSERT INTO inspec_profiles (name, url, description, stars, last_updated, metadata, repo_id, status, source, fork, parent_url, owner, seen_at)
+			VALUES ($1, $2, $3, $4, $5, $6, NULLIF($7::bigint, 0), $8, $9, $10, $11, $12, $5) RETURNING id, commit_sha, fingerprint`,
+			profile.Name, profile.URL, profile.Description, profile.Stars, time.Now(), metadata, profile.RepoID, profileStatus(*profile), profile.Source,
+			profile.Fork, profile.ParentURL, profile.Owner).Scan(&profile.ID, &profile.CommitSHA, &profile.Fingerprint)
+		if err != nil {
+			return false, fmt.Errorf("failed to insert profile %s: %v", profile.URL, err)
+		}
+		return true, nil
+	}
+	if err != nil {
+		return false, fmt.Errorf("failed to look up profile %s: %v", profile.URL, err)
+	}
+
+	tx, err := db.Begin()
+	if err != nil {
+		return false, fmt.Errorf("failed to start transaction: %v", err)
+	}
+	defer tx.Rollback()
+
+	err = tx.QueryRow(`UPDATE inspec_profiles SET name = $1, url = $2, stars = $3, description = $4, last_updated = $5, metadata = $6,
+		repo_id = COALESCE(NULLIF($7::bigint, 0), repo_id), status = $8, status_reason = '', source = COALESCE(NULLIF($9, ''), source), seen_at = $5,
+		fork = $10, parent_url = CASE WHEN $10 THEN COALESCE(NULLIF($11, ''), parent_url) ELSE '' END, owner = $12
+		WHERE id = $13 RETURNING commit_sha, fingerprint`,
+		profile.Name, profile.URL, profile.Stars, profile.Description, time.Now(), metadata, profile.RepoID, profileStatus(*profile), profile.Source,
+		profile.Fork, profile.ParentURL, profile.Owner, profile.ID).Scan(&profile.CommitSHA, &profile.Fingerprint)
+	if err != nil {
+		return false, fmt.Errorf("failed to update profile %s: %v", profile.URL, err)
+	}
+
+	if oldURL != profile.URL {
+		// Profiles added under the new URL before the rename was followed
+		// are duplicates of this one
+		_, err = tx.Exec("UPDATE inspec_profiles SET status = $1, status_reason = $2 WHERE url = $3 AND id <> $4 AND status <> $1",
+			models.ProfileRemoved, fmt.Sprintf("duplicate of profile %d", profile.ID), profile.URL, profile.ID)
+		if err != nil {
+			return false, fmt.Errorf("failed to remove duplicates of profile %s: %v", profile.URL, err)
+		}
+		log.Printf("Profile %d moved from %s to %s", profile.ID, oldURL, profile.URL)
+	}
+	return false, tx.Commit()
+}
+
+// ListUnseenProfiles returns the profiles of source that are not removed and
+// were last seen by a catalog sync before since.
+func ListUnseenProfiles(source string, since time.Time) ([]models.Profile, error) {
+	return listProfiles("WHERE source = $1 AND status <> $2 AND (seen_at IS NULL OR seen_at < $3) ORDER BY id",
+		source, models.ProfileRemoved, since)
+}
+
+// ListAllProfiles returns every catalog profile, including removed ones.
+func ListAllProfiles() ([]models.Profile, error) {
+	return listProfiles("ORDER BY id")
+}
+
+// ListProfilesWithoutSource returns the profiles that aren't tagged with
+// their source yet, as they were added before sources were recorded.
+func ListProfilesWithoutSource() ([]models.Profile, error) {
+	return listProfiles("WHERE source = '' ORDER BY id")
+}
+
+func listProfiles(where string, args ...interface{}) ([]models.Profile, error) {
+	rows, err := db.Query("SELECT "+profileColumns+" FROM inspec_profiles "+where, args...)
 	if err != nil {
-		log.Printf("Error querying database: %v", err)
-		return nil, err
+		return nil, fmt.Errorf("failed to query profiles: %v", err)
 	}
 	defer rows.Close()
 
 	var profiles []models.Profile
 	for rows.Next() {
-		var profile models.Profile
-		if err := rows.Scan(&profile.ID, &profile.Name, &profile.URL, &profile.Description, &profile.Stars, &profile.LastUpdated); err != nil {
-			log.Printf("Error scanning row: %v", err)
-			return nil, err
+		profile, err := scanProfile(rows)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan profile: %v", err)
 		}
 		profiles = append(profiles, profile)
 	}
+	return profiles, rows.Err()
+}
 
-	return profiles, nil
+// SetProfileSource tags a profile with the name of its source.
+func SetProfileSource(id int, source string) error {
+	_, err := db.Exec("UPDATE inspec_profiles SET source = $1 WHERE id = $2", source, id)
+	if err != nil {
+		return fmt.Errorf("failed to update source of profile %d: %v", id, err)
+	}
+	return nil
 }
 
-// Function to update profiles from GitHub
-func UpdateProfilesFromGitHub() error {
-	// Fetch profiles from GitHub API
-	profiles, err := github.FetchProfilesFromGitHub()
+// SetProfileRepoID records the ID of a profile's repository.
+func SetProfileRepoID(id int, repoID int64) error {
+	_, err := db.Exec("UPDATE inspec_profiles SET repo_id = $1 WHERE id = $2", repoID, id)
 	if err != nil {
-		return err
+		return fmt.Errorf("failed to update repository ID of profile %d: %v", id, err)
 	}
+	return nil
+}
 
-	// Update or insert profiles in database
-	for _, profile := range profiles {
-		// Check if profile already exists
-		var count int
-		err := db.QueryRow("SELECT COUNT(*) FROM inspec_profiles WHERE url = $1", profile.URL).Scan(&count)
-		if err != nil {
-			return err
+// SetProfileCanonical groups a profile under the canonical profile with the
+// given ID, or ungroups it if the ID is zero.
+func SetProfileCanonical(id, canonicalID int) error {
+	_, err := db.Exec("UPDATE inspec_profiles SET canonical_id = NULLIF($1, 0) WHERE id = $2", canonicalID, id)
+	if err != nil {
+		return fmt.Errorf("failed to update canonical profile of profile %d: %v", id, err)
+	}
+	return nil
+}
+
+// RemoveProfile marks a profile as removed from its source for the given
+// reason. Removed profiles are kept, along with their controls, so that
+// past runs can still be traced back to them.
+func RemoveProfile(id int, reason string) error {
+	_, err := db.Exec("UPDATE inspec_profiles SET status = $1, status_reason = $2, last_updated = $3 WHERE id = $4",
+		models.ProfileRemoved, reason, time.Now(), id)
+	if err != nil {
+		return fmt.Errorf("failed to remove profile %d: %v", id, err)
+	}
+	return nil
+}
+
+// GetProfileByURL returns the catalog profile with the given URL, preferring
+// one that isn't removed. It returns sql.ErrNoRows if the URL is not in the
+// catalog.
+func GetProfileByURL(url string) (models.Profile, error) {
+	return scanProfile(db.QueryRow("SELECT "+profileColumns+" FROM inspec_profiles WHERE url = $1 ORDER BY status = $2, id LIMIT 1", url, models.ProfileRemoved))
+}
+
+// GetProfile returns the catalog profile with the given ID. It returns
+// sql.ErrNoRows if there is no such profile.
+func GetProfile(id int) (models.Profile, error) {
+	return scanProfile(db.QueryRow("SELECT "+profileColumns+" FROM inspec_profiles WHERE id = $1", id))
+}
+
+// SetProfileCommit records the latest known commit of a profile's repository.
+func SetProfileCommit(id int, sha string) error {
+	_, err := db.Exec("UPDATE inspec_profiles SET commit_sha = $1 WHERE id = $2", sha, id)
+	if err != nil {
+		return fmt.Errorf("failed to update commit of profile %d: %v", id, err)
+	}
+	return nil
+}
+
+// profileColumns are the inspec_profiles columns read by scanProfile,
+// along with the number of profiles grouped under each profile.
+const profileColumns = "id, name, url, description, stars, commit_sha, metadata, COALESCE(repo_id, 0), source, owner, fork, parent_url, fingerprint, COALESCE(canonical_id, 0), " +
+	"(SELECT COUNT(*) FROM inspec_profiles d WHERE d.canonical_id = inspec_profiles.id), status, status_reason, " +
+	"review_status, reviewer, review_notes, reviewed_sha, reviewed_at, last_updated"
+
+func scanProfile(row scanner, extra ...interface{}) (models.Profile, error) {
+	var profile models.Profile
+	var metadata []byte
+	dest := []interface{}{&profile.ID, &profile.Name, &profile.URL, &profile.Description, &profile.Stars, &profile.CommitSHA, &metadata, &profile.RepoID, &profile.Source,
+		&profile.Owner, &profile.Fork, &profile.ParentURL, &profile.Fingerprint, &profile.CanonicalID, &profile.Duplicates, &profile.Status, &profile.StatusReason,
+		&profile.ReviewStatus, &profile.Reviewer, &profile.ReviewNotes, &profile.ReviewedSHA, &profile.ReviewedAt, &profile.LastUpdated}
+	err := row.Scan(append(dest, extra...)...)
+	if err != nil {
+		return profile, err
+	}
+	if metadata != nil {
+		profile.Metadata = &models.ProfileMetadata{}
+		if err := json.Unmarshal(metadata, profile.Metadata); err != nil {
+			return profile, fmt.Errorf("failed to decode metadata of profile %d: %v", profile.ID, err)
+		}
+	}
+	return profile, nil
+}
+
+// marshalMetadata encodes profile metadata for the metadata JSONB column.
+// Profiles without metadata are stored as NULL.
+func marshalMetadata(metadata *models.ProfileMetadata) ([]byte, error) {
+	if metadata == nil {
+		return nil, nil
+	}
+	data, err := json.Marshal(metadata)
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode profile metadata: %v", err)
+	}
+	return data, nil
+}
+
+// profileSearch is the text search document of a profile. It must match the
+// expression of the inspec_profiles_search_idx index.
+const profileSearch = "to_tsvector('english', name || ' ' || COALESCE(description, ''))"
+
+// ErrInvalidCursor is returned by ListProfiles for a cursor that wasn't
+// returned for the same sort order.
+var ErrInvalidCursor = errors.New("invalid cursor")
+
+// profileSort is a sort order of ListProfiles. Results are ordered by expr
+// and then by ID, and paginated on both. The value of expr is passed through
+// cursors as text and cast back to typ.
+type profileSort struct {
+	expr string
+	typ  string
+	desc bool
+}
+
+// profileSorts are the sort keys ListProfiles accepts. $Q in an expression
+// refers to the search query.
+var profileSorts = map[string]profileSort{
+	"stars":     {expr: "COALESCE(stars, 0)", typ: "int", desc: true},
+	"name":      {expr: "lower(name)", typ: "text"},
+	"updated":   {expr: "last_updated", typ: "timestamp", desc: true},
+	"relevance": {expr: "ts_rank(" + profileSearch + ", plainto_tsquery('english', $Q))::double precision", typ: "double precision", desc: true},
+}
+
+// IsProfileSort reports whether key is a sort key accepted by ListProfiles.
+// The relevance sort is only available with a search query.
+func IsProfileSort(key string) bool {
+	_, ok := profileSorts[key]
+	return ok
+}
+
+// ProfileFilter narrows down and orders the profiles returned by
+// ListProfiles. Zero values are ignored.
+type ProfileFilter struct {
+	// Query is matched against the name and description of profiles using
+	// full-text search.
+	Query    string
+	MinStars int
+	// Platform matches the platform, platform family or OS family a profile
+	// declares support for in its inspec.yml.
+	Platform string
+	// Owner is the user or organization owning a profile's repository.
+	Owner string
+	// Statuses lists the profile statuses to include.
+	Statuses []string
+	// ReviewStatuses lists the review statuses to include.
+	ReviewStatuses []string
+	// Source is the name of the catalog source profiles were found on.
+	Source string
+	// Collapse leaves out forks and duplicates grouped under a canonical
+	// profile.
+	Collapse bool
+	// CanonicalID selects the profiles grouped under the profile with this
+	// ID.
+	CanonicalID int
+	// Sort is one of the keys of profileSorts, stars if empty. Ascending
+	// reverses its natural order.
+	Sort      string
+	Ascending *bool
+	// Cursor is the NextCursor of the previous page.
+	Cursor string
+	Limit  int
+}
+
+// ProfilePage is a page of profiles returned by ListProfiles.
+type ProfilePage struct {
+	Profiles []models.Profile
+	// Total counts all profiles matching the filter, across pages.
+	Total int
+	// NextCursor fetches the next page; it is empty on the last page.
+	NextCursor string
+}
+
+// profileCursor is the position after the last profile of a page.
+type profileCursor struct {
+	Sort      string `json:"s"`
+	Ascending bool   `json:"a"`
+	Key       string `json:"k"`
+	ID        int    `json:"i"`
+}
+
+// ListProfiles returns a page of the catalog profiles matching filter.
+func ListProfiles(filter ProfileFilter) (ProfilePage, error) {
+	var conditions []string
+	var args []interface{}
+	addCondition := func(condition string, arg interface{}) {
+		args = append(args, arg)
+		conditions = append(conditions, fmt.Sprintf(condition, len(args)))
+	}
+
+	queryArg := 0
+	if filter.Query != "" {
+		addCondition(profileSearch+" @@ plainto_tsquery('english', $%d)", filter.Query)
+		queryArg = len(args)
+	}
+	if filter.MinStars > 0 {
+		addCondition("COALESCE(stars, 0) >= $%d", filter.MinStars)
+	}
+	if filter.Platform != "" {
+		addCondition(`EXISTS (SELECT 1 FROM jsonb_array_elements(metadata->'supports') s, jsonb_each_text(s) kv
+			WHERE kv.key IN ('platform', 'platform-name', 'platform-family', 'os-name', 'os-family') AND lower(kv.value) = lower($%d))`, filter.Platform)
+	}
+	if filter.Owner != "" {
+		addCondition("lower(owner) = lower($%d)", filter.Owner)
+	}
+	if filter.Source != "" {
+		addCondition("source = $%d", filter.Source)
+	}
+	if len(filter.Statuses) > 0 {
+		addCondition("status = ANY($%d)", pq.Array(filter.Statuses))
+	}
+	if len(filter.ReviewStatuses) > 0 {
+		addCondition("review_status = ANY($%d)", pq.Array(filter.ReviewStatuses))
+	}
+	if filter.Collapse {
+		conditions = append(conditions, "canonical_id IS NULL")
+	}
+	if filter.CanonicalID != 0 {
+		addCondition("canonical_id = $%d", filter.CanonicalID)
+	}
+
+	var page ProfilePage
+	countQuery := "SELECT COUNT(*) FROM inspec_profiles"
+	if len(conditions) > 0 {
+		countQuery += " WHERE " + strings.Join(conditions, " AND ")
+	}
+	if err := db.QueryRow(countQuery, args...).Scan(&page.Total); err != nil {
+		return page, fmt.Errorf("failed to count profiles: %v", err)
+	}
+
+	if filter.Sort == "" {
+		filter.Sort = "stars"
+	}
+	sort, ok := profileSorts[filter.Sort]
+	if !ok || filter.Sort == "relevance" && queryArg == 0 {
+		return page, fmt.Errorf("invalid sort key %q", filter.Sort)
+	}
+	sortExpr := strings.ReplaceAll(sort.expr, "$Q", fmt.Sprintf("$%d", queryArg))
+	ascending := !sort.desc
+	if filter.Ascending != nil {
+		ascending = *filter.Ascending
+	}
+	direction, comparison := "DESC", "<"
+	if ascending {
+		direction, comparison = "ASC", ">"
+	}
+
+	if filter.Cursor != "" {
+		cursor, err := decodeProfileCursor(filter.Cursor)
+		if err != nil || cursor.Sort != filter.Sort || cursor.Ascending != ascending {
+			return page, ErrInvalidCursor
 		}
+		args = append(args, cursor.Key, cursor.ID)
+		conditions = append(conditions, fmt.Sprintf("(%s, id) %s ($%d::%s, $%d)", sortExpr, comparison, len(args)-1, sort.typ, len(args)))
+	}
+
+	query := "SELECT " + profileColumns + ", (" + sortExpr + ")::text FROM inspec_profiles"
+	if len(conditions) > 0 {
+		query += " WHERE " + strings.Join(conditions, " AND ")
+	}
+	query += fmt.Sprintf(" ORDER BY %s %s, id %s", sortExpr, direction, direction)
+	if filter.Limit > 0 {
+		// Fetch one more profile to learn whether there is a next page
+		args = append(args, filter.Limit+1)
+		query += fmt.Sprintf(" LIMIT $%d", len(args))
+	}
+
+	rows, err := db.Query(query, args...)
+	if err != nil {
+		return page, fmt.Errorf("failed to query profiles: %v", err)
+	}
+	defer rows.Close()
 
-		if count > 0 {
-			// Update existing profile
-			_, err = db.Exec("UPDATE inspec_profiles SET stars = $1, description = $2, last_updated = $3 WHERE url = $4",
-				profile.Stars, profile.Description, time.Now(), profile.URL)
-			if err != nil {
-				return err
-			}
-		} else {
-			// Insert new profile
-			_, err = db.Exec("INSERT INTO inspec_profiles (name, url, description, stars, last_updated) VALUES ($1, $2, $3, $4, $5)",
-				profile.Name, profile.URL, profile.Description, profile.Stars, time.Now())
-			if err != nil {
-				return err
-			}
+	page.Profiles = []models.Profile{}
+	var keys []string
+	for rows.Next() {
+		var key string
+		profile, err := scanProfile(rows, &key)
+		if err != nil {
+			return page, fmt.Errorf("failed to scan profile: %v", err)
 		}
+		page.Profiles = append(page.Profiles, profile)
+		keys = append(keys, key)
+	}
+	if err := rows.Err(); err != nil {
+		return page, fmt.Errorf("failed to query profiles: %v", err)
 	}
 
-	return nil
+	if filter.Limit > 0 && len(page.Profiles) > filter.Limit {
+		page.Profiles = page.Profiles[:filter.Limit]
+		last := page.Profiles[filter.Limit-1]
+		page.NextCursor = encodeProfileCursor(profileCursor{
+			Sort:      filter.Sort,
+			Ascending: ascending,
+			Key:       keys[filter.Limit-1],
+			ID:        last.ID,
+		})
+	}
+	return page, nil
+}
+
+func encodeProfileCursor(cursor profileCursor) string {
+	data, _ := json.Marshal(cursor)
+	return base64.RawURLEncoding.EncodeToString(data)
+}
+
+// validCursorKey reports whether key can be cast to typ, so that a tampered
+// cursor is rejected rather than failing the query.
+func validCursorKey(typ, key string) bool {
+	var err error
+	switch typ {
+	case "int":
+		_, err = strconv.ParseInt(key, 10, 32)
+	case "double precision":
+		_, err = strconv.ParseFloat(key, 64)
+	case "timestamp":
+		_, err = time.Parse("2006-01-02 15:04:05.999999999", key)
+	}
+	return err == nil
+}
+
+func decodeProfileCursor(s string) (profileCursor, error) {
+	var cursor profileCursor
+	data, err := base64.RawURLEncoding.DecodeString(s)
+	if err != nil {
+		return cursor, err
+	}
+	if err := json.Unmarshal(data, &cursor); err != nil {
+		return cursor, err
+	}
+	if sort, ok := profileSorts[cursor.Sort]; !ok || !validCursorKey(sort.typ, cursor.Key) {
+		return cursor, ErrInvalidCursor
+	}
+	return cursor, nil
 }
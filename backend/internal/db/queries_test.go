@@ -0,0 +1,40 @@
+package db
+
+import (
+	"errors"
+	"regexp"
+	"testing"
+
+	"github.com/DATA-DOG/go-sqlmock"
+)
+
+func TestListProfilesFiltersOnOwner(t *testing.T) {
+	mock := mockDB(t)
+
+	mock.ExpectQuery(regexp.QuoteMeta("SELECT COUNT(*) FROM inspec_profiles WHERE lower(owner) = lower($1)")).
+		WithArgs("Dev-Sec").
+		WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(0))
+	mock.ExpectQuery(regexp.QuoteMeta("FROM inspec_profiles WHERE lower(owner) = lower($1) ORDER BY")).
+		WithArgs("Dev-Sec").
+		WillReturnRows(sqlmock.NewRows([]string{"id"}))
+
+	page, err := ListProfiles(ProfileFilter{Owner: "Dev-Sec"})
+	if err != nil {
+		t.Fatalf("ListProfiles: %v", err)
+	}
+	if page.Total != 0 || len(page.Profiles) != 0 {
+		t.Errorf("page = %+v, want no profiles", page)
+	}
+}
+
+func TestListProfilesRejectsMalformedCursorKey(t *testing.T) {
+	mock := mockDB(t)
+
+	mock.ExpectQuery(regexp.QuoteMeta("SELECT COUNT(*) FROM inspec_profiles")).
+		WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(1))
+
+	cursor := encodeProfileCursor(profileCursor{Sort: "stars", Key: "abc", ID: 1})
+	if _, err := ListProfiles(ProfileFilter{Cursor: cursor}); !errors.Is(err, ErrInvalidCursor) {
+		t.Errorf("ListProfiles = %v, want ErrInvalidCursor", err)
+	}
+}
@@ -0,0 +1,51 @@
+package db
+
+import (
+	"fmt"
+
+	"github.com/ahasunos/caas/backend/internal/models"
+)
+
+// ReviewProfile sets the review status of a profile and records the review
+// in its history. The review's ID and creation time are filled in.
+func ReviewProfile(review *models.ProfileReview) error {
+	tx, err := db.Begin()
+	if err != nil {
+		return fmt.Errorf("failed to start transaction: %v", err)
+	}
+	defer tx.Rollback()
+
+	err = tx.QueryRow(`INSERT INTO profile_reviews (profile_id, status, reviewer, notes, commit_sha)
+		VALUES ($1, $2, $3, $4, $5) RETURNING id, created_at`,
+		review.ProfileID, review.Status, review.Reviewer, review.Notes, review.CommitSHA).Scan(&review.ID, &review.CreatedAt)
+	if err != nil {
+		return fmt.Errorf("failed to record review of profile %d: %v", review.ProfileID, err)
+	}
+
+	_, err = tx.Exec(`UPDATE inspec_profiles SET review_status = $1, reviewer = $2, review_notes = $3, reviewed_sha = $4, reviewed_at = $5
+		WHERE id = $6`, review.Status, review.Reviewer, review.Notes, review.CommitSHA, review.CreatedAt, review.ProfileID)
+	if err != nil {
+		return fmt.Errorf("failed to update review status of profile %d: %v", review.ProfileID, err)
+	}
+	return tx.Commit()
+}
+
+// ListProfileReviews returns the review history of a profile, latest first.
+func ListProfileReviews(profileID int) ([]models.ProfileReview, error) {
+	rows, err := db.Query(`SELECT id, profile_id, status, reviewer, notes, commit_sha, created_at
+		FROM profile_reviews WHERE profile_id = $1 ORDER BY id DESC`, profileID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query reviews of profile %d: %v", profileID, err)
+	}
+	defer rows.Close()
+
+	reviews := []models.ProfileReview{}
+	for rows.Next() {
+		var r models.ProfileReview
+		if err := rows.Scan(&r.ID, &r.ProfileID, &r.Status, &r.Reviewer, &r.Notes, &r.CommitSHA, &r.CreatedAt); err != nil {
+			return nil, fmt.Errorf("failed to scan review: %v", err)
+		}
+		reviews = append(reviews, r)
+	}
+	return reviews, rows.Err()
+}
@@ -0,0 +1,233 @@
+package db
+
+import (
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/ahasunos/caas/backend/internal/models"
+)
+
+const runColumns = "id, job_id, hostname, profile, commit_sha, profile_version, engine, engine_version, inspec_version, platform, duration, total, passed, failed, skipped, created_at"
+
+// RunFilter narrows down the runs returned by ListRuns. Zero values are
+// ignored.
+type RunFilter struct {
+	Hostname string
+	Profile  string
+	Since    time.Time
+	Until    time.Time
+	Limit    int
+}
+
+// SaveRun stores a run and its control results, filling in the run's ID and
+// creation time.
+func SaveRun(run *models.Run) error {
+	platform, err := json.Marshal(run.Platform)
+	if err != nil {
+		return fmt.Errorf("failed to encode run platform: %v", err)
+	}
+	// Profile metadata is kept on the run; controls go to their own table
+	profiles := make([]models.ProfileResult, len(run.Profiles))
+	for i, p := range run.Profiles {
+		p.Controls = nil
+		profiles[i] = p
+	}
+	profilesJSON, err := json.Marshal(profiles)
+	if err != nil {
+		return fmt.Errorf("failed to encode run profiles: %v", err)
+	}
+
+	tx, err := db.Begin()
+	if err != nil {
+		return fmt.Errorf("failed to start transaction: %v", err)
+	}
+	defer tx.Rollback()
+
+	var jobID sql.NullInt64
+	if run.JobID != 0 {
+		jobID = sql.NullInt64{Int64: int64(run.JobID), Valid: true}
+	}
+	err = tx.QueryRow(`INSERT INTO runs (job_id, hostname, profile, commit_sha, profile_version, engine, engine_version, inspec_version, platform, profiles, duration, total, passed, failed, skipped)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13, $14, $15) RETURNING id, created_at`,
+		jobID, run.Hostname, run.Profile, run.CommitSHA, run.ProfileVersion, run.Engine, run.EngineVersion, run.Version, platform, profilesJSON, run.Statistics.Duration,
+		run.Summary.Total, run.Summary.Passed, run.Summary.Failed, run.Summary.Skipped).Scan(&run.ID, &run.CreatedAt)
+	if err != nil {
+		return fmt.Errorf("failed to insert run into the database: %v", err)
+	}
+
+	stmt, err := tx.Prepare(`INSERT INTO control_results (run_id, profile_name, control_id, title, description, impact, status, tags, results)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9)`)
+	if err != nil {
+		return fmt.Errorf("failed to prepare control result insert: %v", err)
+	}
+	defer stmt.Close()
+
+	for _, p := range run.Profiles {
+		for _, c := range p.Controls {
+			tags, err := json.Marshal(c.Tags)
+			if err != nil {
+				return fmt.Errorf("failed to encode tags of control %s: %v", c.ID, err)
+			}
+			results, err := json.Marshal(c.Results)
+			if err != nil {
+				return fmt.Errorf("failed to encode results of control %s: %v", c.ID, err)
+			}
+			if _, err := stmt.Exec(run.ID, p.Name, c.ID, c.Title, c.Desc, c.Impact, c.Status, tags, results); err != nil {
+				return fmt.Errorf("failed to insert control result %s: %v", c.ID, err)
+			}
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit run: %v", err)
+	}
+	return nil
+}
+
+// ListRuns returns run summaries matching the filter, newest first. The
+// returned runs do not include profiles or control results.
+func ListRuns(filter RunFilter) ([]models.Run, error) {
+	var conditions []string
+	var args []interface{}
+	addCondition := func(condition string, arg interface{}) {
+		args = append(args, arg)
+		conditions = append(conditions, fmt.Sprintf(condition, len(args)))
+	}
+
+	if filter.Hostname != "" {
+		addCondition("hostname = $%d", filter.Hostname)
+	}
+	if filter.Profile != "" {
+		addCondition("profile = $%d", filter.Profile)
+	}
+	if !filter.Since.IsZero() {
+		addCondition("created_at >= $%d", filter.Since)
+	}
+	if !filter.Until.IsZero() {
+		addCondition("created_at <= $%d", filter.Until)
+	}
+
+	query := "SELECT " + runColumns + " FROM runs"
+	if len(conditions) > 0 {
+		query += " WHERE " + strings.Join(conditions, " AND ")
+	}
+	query += " ORDER BY created_at DESC, id DESC"
+	if filter.Limit > 0 {
+		args = append(args, filter.Limit)
+		query += fmt.Sprintf(" LIMIT $%d", len(args))
+	}
+
+	rows, err := db.Query(query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query runs: %v", err)
+	}
+	defer rows.Close()
+
+	runs := []models.Run{}
+	for rows.Next() {
+		run, err := scanRun(rows)
+		if err != nil {
+			return nil, err
+		}
+		runs = append(runs, run)
+	}
+	return runs, rows.Err()
+}
+
+// GetRun returns a run with all of its profiles and control results. It
+// returns sql.ErrNoRows if the run does not exist.
+func GetRun(id int) (models.Run, error) {
+	row := db.QueryRow("SELECT "+runColumns+", profiles FROM runs WHERE id = $1", id)
+
+	var profiles []byte
+	run, err := scanRun(row, &profiles)
+	if err != nil {
+		return models.Run{}, err
+	}
+	if profiles != nil {
+		if err := json.Unmarshal(profiles, &run.Profiles); err != nil {
+			return models.Run{}, fmt.Errorf("failed to decode profiles of run %d: %v", id, err)
+		}
+	}
+
+	if err := loadControlResults(&run); err != nil {
+		return models.Run{}, err
+	}
+	return run, nil
+}
+
+// GetLatestRunID returns the ID of the most recent run against hostname
+// created at or before the given time, optionally restricted to a profile.
+// It returns sql.ErrNoRows if there is no such run.
+func GetLatestRunID(hostname, profile string, at time.Time) (int, error) {
+	var id int
+	err := db.QueryRow(`SELECT id FROM runs WHERE hostname = $1 AND ($2 = '' OR profile = $2) AND created_at <= $3
+		ORDER BY created_at DESC, id DESC LIMIT 1`, hostname, profile, at).Scan(&id)
+	return id, err
+}
+
+// loadControlResults attaches the stored control results of a run to the
+// matching profiles of the run.
+func loadControlResults(run *models.Run) error {
+	rows, err := db.Query(`SELECT profile_name, control_id, title, description, impact, status, tags, results
+		FROM control_results WHERE run_id = $1 ORDER BY id`, run.ID)
+	if err != nil {
+		return fmt.Errorf("failed to query control results: %v", err)
+	}
+	defer rows.Close()
+
+	index := make(map[string]int, len(run.Profiles))
+	for i, p := range run.Profiles {
+		index[p.Name] = i
+	}
+
+	for rows.Next() {
+		var profileName string
+		var c models.ControlResult
+		var tags, results []byte
+		if err := rows.Scan(&profileName, &c.ID, &c.Title, &c.Desc, &c.Impact, &c.Status, &tags, &results); err != nil {
+			return fmt.Errorf("failed to scan control result: %v", err)
+		}
+		if err := json.Unmarshal(tags, &c.Tags); err != nil {
+			return fmt.Errorf("failed to decode tags of control %s: %v", c.ID, err)
+		}
+		if err := json.Unmarshal(results, &c.Results); err != nil {
+			return fmt.Errorf("failed to decode results of control %s: %v", c.ID, err)
+		}
+
+		i, ok := index[profileName]
+		if !ok {
+			run.Profiles = append(run.Profiles, models.ProfileResult{Name: profileName})
+			i = len(run.Profiles) - 1
+			index[profileName] = i
+		}
+		run.Profiles[i].Controls = append(run.Profiles[i].Controls, c)
+	}
+	return rows.Err()
+}
+
+// scanner is implemented by *sql.Row and *sql.Rows.
+type scanner interface {
+	Scan(dest ...interface{}) error
+}
+
+func scanRun(row scanner, extra ...interface{}) (models.Run, error) {
+	var run models.Run
+	var jobID sql.NullInt64
+	var platform []byte
+	dest := []interface{}{&run.ID, &jobID, &run.Hostname, &run.Profile, &run.CommitSHA, &run.ProfileVersion, &run.Engine, &run.EngineVersion, &run.Version, &platform, &run.Statistics.Duration,
+		&run.Summary.Total, &run.Summary.Passed, &run.Summary.Failed, &run.Summary.Skipped, &run.CreatedAt}
+	if err := row.Scan(append(dest, extra...)...); err != nil {
+		return models.Run{}, err
+	}
+	run.JobID = int(jobID.Int64)
+	if platform != nil {
+		if err := json.Unmarshal(platform, &run.Platform); err != nil {
+			return models.Run{}, fmt.Errorf("failed to decode platform of run %d: %v", run.ID, err)
+		}
+	}
+	return run, nil
+}
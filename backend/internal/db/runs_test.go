@@ -0,0 +1,126 @@
+package db
+
+import (
+	"database/sql"
+	"errors"
+	"reflect"
+	"regexp"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/ahasunos/caas/backend/internal/models"
+)
+
+func testRun() *models.Run {
+	return &models.Run{
+		JobID:         3,
+		Hostname:      "web-1",
+		Profile:       "https://github.com/dev-sec/linux-baseline",
+		CommitSHA:     "0123456789abcdef0123456789abcdef01234567",
+		Engine:        "inspec",
+		EngineVersion: "5.22.3",
+		Version:       "5.22.3",
+		Platform:      models.Platform{Name: "ubuntu", Release: "22.04"},
+		Statistics:    models.Statistics{Duration: 1.5},
+		Summary:       models.RunSummary{Total: 2, Passed: 1, Failed: 1},
+		Profiles: []models.ProfileResult{{
+			Name: "linux-baseline",
+			Controls: []models.ControlResult{
+				{ID: "os-01", Title: "Trusted hosts", Impact: 1, Status: models.StatusPassed, Results: []models.TestResult{{Status: models.StatusPassed}}},
+				{ID: "os-02", Title: "Shadow", Impact: 0.7, Status: models.StatusFailed, Tags: map[string]interface{}{"nist": "AC-6"}},
+			},
+		}},
+	}
+}
+
+func TestSaveRun(t *testing.T) {
+	mock := mockDB(t)
+	run := testRun()
+	created := time.Date(2025, 3, 1, 12, 0, 0, 0, time.UTC)
+
+	mock.ExpectBegin()
+	mock.ExpectQuery(regexp.QuoteMeta("INSERT INTO runs")).
+		WithArgs(3, "web-1", run.Profile, run.CommitSHA, "", "inspec", "5.22.3", "5.22.3",
+			[]byte(`{"name":"ubuntu","release":"22.04"}`), []byte(`[{"name":"linux-baseline","title":"","version":"","sha256":"","status":"","controls":null}]`),
+			1.5, 2, 1, 1, 0).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "created_at"}).AddRow(11, created))
+	prepared := mock.ExpectPrepare(regexp.QuoteMeta("INSERT INTO control_results"))
+	prepared.ExpectExec().
+		WithArgs(11, "linux-baseline", "os-01", "Trusted hosts", "", 1.0, models.StatusPassed, []byte("null"), []byte(`[{"status":"passed","code_desc":"","run_time":0}]`)).
+		WillReturnResult(sqlmock.NewResult(1, 1))
+	prepared.ExpectExec().
+		WithArgs(11, "linux-baseline", "os-02", "Shadow", "", 0.7, models.StatusFailed, []byte(`{"nist":"AC-6"}`), []byte("null")).
+		WillReturnResult(sqlmock.NewResult(2, 1))
+	mock.ExpectCommit()
+
+	if err := SaveRun(run); err != nil {
+		t.Fatalf("SaveRun: %v", err)
+	}
+	if run.ID != 11 || !run.CreatedAt.Equal(created) {
+		t.Errorf("run ID %d created at %s, want 11 at %s", run.ID, run.CreatedAt, created)
+	}
+	if len(run.Profiles[0].Controls) != 2 {
+		t.Errorf("SaveRun modified the controls of the run")
+	}
+}
+
+func TestSaveRunRollsBackOnError(t *testing.T) {
+	mock := mockDB(t)
+
+	mock.ExpectBegin()
+	mock.ExpectQuery(regexp.QuoteMeta("INSERT INTO runs")).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "created_at"}).AddRow(11, time.Now()))
+	mock.ExpectPrepare(regexp.QuoteMeta("INSERT INTO control_results")).
+		ExpectExec().WillReturnError(errors.New("disk full"))
+	mock.ExpectRollback()
+
+	if err := SaveRun(testRun()); err == nil {
+		t.Fatal("SaveRun succeeded although a control result could not be stored")
+	}
+}
+
+func TestGetRun(t *testing.T) {
+	mock := mockDB(t)
+	created := time.Date(2025, 3, 1, 12, 0, 0, 0, time.UTC)
+
+	mock.ExpectQuery(regexp.QuoteMeta("SELECT " + runColumns + ", profiles FROM runs WHERE id = $1")).
+		WithArgs(11).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "job_id", "hostname", "profile", "commit_sha", "profile_version", "engine", "engine_version",
+			"inspec_version", "platform", "duration", "total", "passed", "failed", "skipped", "created_at", "profiles"}).
+			AddRow(11, nil, "web-1", "linux-baseline", "", "2.9.0", "inspec", "5.22.3", "5.22.3", []byte(`{"name":"ubuntu","release":"22.04"}`),
+				1.5, 2, 1, 1, 0, created, []byte(`[{"name":"linux-baseline","version":"2.9.0"}]`)))
+	mock.ExpectQuery(regexp.QuoteMeta("FROM control_results WHERE run_id = $1")).
+		WithArgs(11).
+		WillReturnRows(sqlmock.NewRows([]string{"profile_name", "control_id", "title", "description", "impact", "status", "tags", "results"}).
+			AddRow("linux-baseline", "os-01", "Trusted hosts", "", 1.0, "passed", []byte("null"), []byte(`[{"status":"passed"}]`)).
+			AddRow("dependency", "dep-01", "From a dependency", "", 0.5, "skipped", []byte(`{"cis":"1.1"}`), []byte("null")))
+
+	run, err := GetRun(11)
+	if err != nil {
+		t.Fatalf("GetRun: %v", err)
+	}
+	if run.JobID != 0 || run.Platform.Name != "ubuntu" || run.Summary.Total != 2 || run.ProfileVersion != "2.9.0" {
+		t.Errorf("run = %+v", run)
+	}
+	want := []models.ProfileResult{
+		{Name: "linux-baseline", Version: "2.9.0", Controls: []models.ControlResult{
+			{ID: "os-01", Title: "Trusted hosts", Impact: 1, Status: "passed", Results: []models.TestResult{{Status: "passed"}}},
+		}},
+		{Name: "dependency", Controls: []models.ControlResult{
+			{ID: "dep-01", Title: "From a dependency", Impact: 0.5, Status: "skipped", Tags: map[string]interface{}{"cis": "1.1"}},
+		}},
+	}
+	if !reflect.DeepEqual(run.Profiles, want) {
+		t.Errorf("profiles = %+v, want %+v", run.Profiles, want)
+	}
+}
+
+func TestGetRunNotFound(t *testing.T) {
+	mock := mockDB(t)
+	mock.ExpectQuery("FROM runs").WithArgs(12).WillReturnError(sql.ErrNoRows)
+
+	if _, err := GetRun(12); !errors.Is(err, sql.ErrNoRows) {
+		t.Errorf("GetRun error = %v, want sql.ErrNoRows", err)
+	}
+}
@@ -0,0 +1,157 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/ahasunos/caas/backend/internal/models"
+	"github.com/lib/pq"
+)
+
+const syncRunColumns = "id, status, trigger, sources, pages, repositories, duplicates, excluded, discovered, added, updated, removed, error, started_at, finished_at"
+
+// syncLockKey is the Postgres advisory lock held while a catalog sync runs,
+// so that only one sync runs at a time across all API instances.
+const syncLockKey = 0x63617461 // "cata"
+
+// TryLockSync takes the catalog sync lock if no other sync holds it. The
+// lock is held on a dedicated connection until release is called, and is
+// dropped by Postgres if the process dies.
+func TryLockSync() (release func(), ok bool, err error) {
+	ctx := context.Background()
+	conn, err := db.Conn(ctx)
+	if err != nil {
+		return nil, false, fmt.Errorf("failed to get connection for sync lock: %v", err)
+	}
+
+	if err := conn.QueryRowContext(ctx, "SELECT pg_try_advisory_lock($1)", syncLockKey).Scan(&ok); err != nil {
+		conn.Close()
+		return nil, false, fmt.Errorf("failed to take sync lock: %v", err)
+	}
+	if !ok {
+		conn.Close()
+		return nil, false, nil
+	}
+
+	release = func() {
+		if _, err := conn.ExecContext(ctx, "SELECT pg_advisory_unlock($1)", syncLockKey); err != nil {
+			// Discard the connection so that closing it releases the lock
+			log.Printf("Error releasing sync lock: %v", err)
+			conn.Raw(func(any) error { return driver.ErrBadConn })
+		}
+		conn.Close()
+	}
+	return release, true, nil
+}
+
+// CreateSyncRun inserts a running sync run and fills in its ID and start
+// time.
+func CreateSyncRun(run *models.SyncRun) error {
+	run.Status = models.SyncRunning
+	err := db.QueryRow("INSERT INTO sync_runs (status, trigger, sources) VALUES ($1, $2, $3) RETURNING id, started_at",
+		run.Status, run.Trigger, sourceNames(run.Sources)).Scan(&run.ID, &run.StartedAt)
+	if err != nil {
+		return fmt.Errorf("failed to insert sync run into the database: %v", err)
+	}
+	return nil
+}
+
+// FinishSyncRun records the terminal status, counts and error of a sync run.
+func FinishSyncRun(run models.SyncRun) error {
+	_, err := db.Exec(`UPDATE sync_runs SET status = $1, pages = $2, repositories = $3, duplicates = $4, excluded = $5, discovered = $6,
+		added = $7, updated = $8, removed = $9, error = $10, finished_at = $11 WHERE id = $12`,
+		run.Status, run.Pages, run.Repositories, run.Duplicates, run.Excluded, run.Discovered, run.Added, run.Updated, run.Removed, run.Error, time.Now(), run.ID)
+	if err != nil {
+		return fmt.Errorf("failed to finish sync run %d: %v", run.ID, err)
+	}
+	return nil
+}
+
+// GetSyncRun returns the sync run with the given ID. It returns
+// sql.ErrNoRows if the run does not exist.
+func GetSyncRun(id int) (models.SyncRun, error) {
+	return scanSyncRun(db.QueryRow("SELECT "+syncRunColumns+" FROM sync_runs WHERE id = $1", id))
+}
+
+// GetRunningSyncRun returns the sync run that is currently running. It
+// returns sql.ErrNoRows if there is none.
+func GetRunningSyncRun() (models.SyncRun, error) {
+	return scanSyncRun(db.QueryRow("SELECT "+syncRunColumns+" FROM sync_runs WHERE status = $1 ORDER BY id DESC LIMIT 1", models.SyncRunning))
+}
+
+// HasSyncRunSince reports whether a sync run of the given sources with the
+// given trigger started at or after since. started_at holds UTC, so since
+// is compared in UTC whatever its location.
+func HasSyncRunSince(trigger string, sources []string, since time.Time) (bool, error) {
+	var exists bool
+	err := db.QueryRow("SELECT EXISTS (SELECT 1 FROM sync_runs WHERE trigger = $1 AND sources = $2 AND started_at >= $3)",
+		trigger, sourceNames(sources), since.UTC()).Scan(&exists)
+	if err != nil {
+		return false, fmt.Errorf("failed to query sync runs: %v", err)
+	}
+	return exists, nil
+}
+
+// ListSyncRuns returns the most recent sync runs, newest first.
+func ListSyncRuns(limit int) ([]models.SyncRun, error) {
+	rows, err := db.Query("SELECT "+syncRunColumns+" FROM sync_runs ORDER BY id DESC LIMIT $1", limit)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query sync runs: %v", err)
+	}
+	defer rows.Close()
+
+	runs := []models.SyncRun{}
+	for rows.Next() {
+		run, err := scanSyncRun(rows)
+		if err != nil {
+			return nil, err
+		}
+		runs = append(runs, run)
+	}
+	return runs, rows.Err()
+}
+
+// FailInterruptedSyncRuns marks sync runs that were running when their
+// server stopped as failed. Runs are only touched if no sync holds the
+// sync lock, so runs of other API instances are left alone.
+func FailInterruptedSyncRuns() (int64, error) {
+	release, ok, err := TryLockSync()
+	if err != nil || !ok {
+		return 0, err
+	}
+	defer release()
+
+	res, err := db.Exec("UPDATE sync_runs SET status = $1, error = $2, finished_at = $3 WHERE status = $4",
+		models.SyncFailed, "Sync interrupted by server restart", time.Now(), models.SyncRunning)
+	if err != nil {
+		return 0, fmt.Errorf("failed to fail interrupted sync runs: %v", err)
+	}
+	return res.RowsAffected()
+}
+
+// sourceNames encodes the sources of a sync run for the sources column,
+// which holds an empty array rather than NULL for syncs of all sources.
+func sourceNames(names []string) interface{} {
+	if names == nil {
+		names = []string{}
+	}
+	return pq.Array(names)
+}
+
+func scanSyncRun(row scanner) (models.SyncRun, error) {
+	var run models.SyncRun
+	var finishedAt sql.NullTime
+	err := row.Scan(&run.ID, &run.Status, &run.Trigger, pq.Array(&run.Sources), &run.Pages, &run.Repositories, &run.Duplicates, &run.Excluded, &run.Discovered,
+		&run.Added, &run.Updated, &run.Removed, &run.Error, &run.StartedAt, &finishedAt)
+	if err != nil {
+		return models.SyncRun{}, err
+	}
+	if finishedAt.Valid {
+		run.FinishedAt = &finishedAt.Time
+	}
+	return run, nil
+}
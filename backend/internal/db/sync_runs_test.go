@@ -0,0 +1,61 @@
+package db
+
+import (
+	"database/sql/driver"
+	"regexp"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/ahasunos/caas/backend/internal/models"
+)
+
+func TestSyncRunExcluded(t *testing.T) {
+	mock := mockDB(t)
+
+	run := models.SyncRun{ID: 7, Status: models.SyncSucceeded, Pages: 3, Repositories: 250, Duplicates: 4, Excluded: 12, Discovered: 40}
+	mock.ExpectExec(regexp.QuoteMeta("UPDATE sync_runs SET status = $1, pages = $2, repositories = $3, duplicates = $4, excluded = $5")).
+		WithArgs(run.Status, 3, 250, 4, 12, 40, 0, 0, 0, "", sqlmock.AnyArg(), 7).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	if err := FinishSyncRun(run); err != nil {
+		t.Fatal(err)
+	}
+
+	mock.ExpectQuery(regexp.QuoteMeta("SELECT " + syncRunColumns + " FROM sync_runs WHERE id = $1")).
+		WithArgs(7).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "status", "trigger", "sources", "pages", "repositories", "duplicates",
+			"excluded", "discovered", "added", "updated", "removed", "error", "started_at", "finished_at"}).
+			AddRow(7, models.SyncSucceeded, models.SyncTriggerManual, "{}", 3, 250, 4, 12, 40, 0, 0, 0, "", time.Now(), time.Now()))
+	got, err := GetSyncRun(7)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got.Excluded != 12 || got.Discovered != 40 {
+		t.Errorf("GetSyncRun = %+v, want 12 excluded and 40 discovered", got)
+	}
+}
+
+func TestHasSyncRunSinceComparesInUTC(t *testing.T) {
+	mock := mockDB(t)
+
+	slot := time.Date(2025, 3, 1, 4, 0, 0, 0, time.FixedZone("CET", 3600))
+	mock.ExpectQuery(regexp.QuoteMeta("SELECT EXISTS (SELECT 1 FROM sync_runs WHERE trigger = $1 AND sources = $2 AND started_at >= $3)")).
+		WithArgs(models.SyncTriggerScheduled, `{"github.com"}`, utcArg(slot)).
+		WillReturnRows(sqlmock.NewRows([]string{"exists"}).AddRow(true))
+
+	done, err := HasSyncRunSince(models.SyncTriggerScheduled, []string{"github.com"}, slot)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !done {
+		t.Error("HasSyncRunSince = false, want true")
+	}
+}
+
+// utcArg matches a time argument equal to the time and given in UTC.
+type utcArg time.Time
+
+func (t utcArg) Match(v driver.Value) bool {
+	got, ok := v.(time.Time)
+	return ok && got.Location() == time.UTC && got.Equal(time.Time(t))
+}
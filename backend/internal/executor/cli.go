@@ -0,0 +1,180 @@
+package executor
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/ahasunos/caas/backend/internal/models"
+	"github.com/ahasunos/caas/backend/internal/report"
+	"github.com/ahasunos/caas/backend/internal/workspace"
+)
+
+// versionTimeout bounds how long asking an engine for its version may take.
+const versionTimeout = 30 * time.Second
+
+// CLI is the Executor that shells out to an InSpec-compatible command line,
+// such as Chef InSpec or its open-source build Cinc Auditor.
+type CLI struct {
+	// engines maps engine names to the binary that runs them.
+	engines    map[string]string
+	licenseKey string
+
+	mu       sync.Mutex
+	versions map[string]*engineVersion // binary -> its version probe
+}
+
+// engineVersion is the version an engine binary reports, probed once.
+type engineVersion struct {
+	once    sync.Once
+	version string
+}
+
+// NewCLI returns a CLI executor for the given engines. licenseKey is passed
+// to Chef InSpec binaries; Cinc Auditor binaries (named cinc-*) need none.
+func NewCLI(engines map[string]string, licenseKey string) *CLI {
+	return &CLI{
+		engines:    engines,
+		licenseKey: licenseKey,
+		versions:   make(map[string]*engineVersion),
+	}
+}
+
+// Execute runs `<engine> exec` for req with both the CLI and JSON reporters,
+// returning the CLI log along with the parsed report. InSpec exits with 100
+// when controls fail and 101 when controls are skipped; both still count as
+// a completed scan. Other exit codes fail the scan, but the report is still
+// returned if InSpec wrote one. The engine and its children are terminated
+// when ctx is done.
+func (e *CLI) Execute(ctx context.Context, req Request, out io.Writer) (Result, error) {
+	var res Result
+
+	binary, ok := e.engines[req.Engine]
+	if !ok {
+		return res, fmt.Errorf("unknown engine %q", req.Engine)
+	}
+	res.EngineVersion = e.version(binary)
+
+	// Every run gets its own private directory, removed however the run ends
+	ws, err := workspace.New()
+	if err != nil {
+		return res, err
+	}
+	defer ws.Close()
+
+	privateKeyPath, err := ws.WriteKey(req.PrivateKey)
+	if err != nil {
+		return res, fmt.Errorf("failed to save private key: %v", err)
+	}
+	reportPath := ws.Path("report.json")
+
+	log.Printf("Executing InSpec profile %s on %s as %s with %s %s (job %d)", req.Profile, req.Hostname, req.Username, req.Engine, res.EngineVersion, req.JobID)
+
+	start := time.Now()
+	cmd, done := e.command(ctx, binary, req, privateKeyPath, reportPath)
+	cmd.Dir = ws.Dir
+
+	// Execute command and capture output, following it live if asked to
+	var output bytes.Buffer
+	cmd.Stdout = &output
+	if out != nil {
+		cmd.Stdout = io.MultiWriter(&output, out)
+	}
+	cmd.Stderr = cmd.Stdout
+	err = cmd.Run()
+	done()
+	log.Printf("InSpec command executed in %s (job %d)", time.Since(start), req.JobID)
+	res.Output = output.String()
+
+	var exitErr *exec.ExitError
+	var runErr error
+	if errors.As(err, &exitErr) {
+		code := exitErr.ExitCode()
+		res.ExitCode = &code
+		if code != 100 && code != 101 {
+			runErr = fmt.Errorf("%s exited with code %d", req.Engine, code)
+		}
+	} else if err != nil {
+		return res, fmt.Errorf("failed to run %s: %v", req.Engine, err)
+	} else {
+		code := 0
+		res.ExitCode = &code
+	}
+
+	res.Report, err = collectReport(reportPath)
+	if runErr != nil {
+		// A failed scan keeps whatever report InSpec managed to write
+		return res, runErr
+	}
+	return res, err
+}
+
+// command builds the `exec` invocation of binary for req. done must be
+// called once the command has exited; see setProcessGroup.
+func (e *CLI) command(ctx context.Context, binary string, req Request, privateKeyPath, reportPath string) (cmd *exec.Cmd, done func()) {
+	args := []string{"exec", req.Profile, "-t", fmt.Sprintf("ssh://%s@%s", req.Username, req.Hostname), "-i", privateKeyPath,
+		"--reporter", "cli", "json:" + reportPath, "--no-color"}
+	if !isCinc(binary) {
+		args = append(args, "--chef-license", "accept")
+		if e.licenseKey != "" {
+			args = append(args, "--chef-license-key", e.licenseKey)
+		}
+	}
+
+	cmd = exec.CommandContext(ctx, binary, args...)
+	return cmd, setProcessGroup(cmd)
+}
+
+// version returns the version reported by `binary version`. Each binary is
+// asked only once, even if the probe fails, and without holding e.mu, so a
+// slow probe doesn't hold up scans of other engines. It returns an empty
+// string if the version can't be determined.
+func (e *CLI) version(binary string) string {
+	e.mu.Lock()
+	probe, ok := e.versions[binary]
+	if !ok {
+		probe = new(engineVersion)
+		e.versions[binary] = probe
+	}
+	e.mu.Unlock()
+
+	probe.once.Do(func() {
+		// Not bound to the scan's context: a cancelled scan must not leave
+		// the failure cached for every later one
+		ctx, cancel := context.WithTimeout(context.Background(), versionTimeout)
+		defer cancel()
+		output, err := exec.CommandContext(ctx, binary, "version").Output()
+		if err != nil {
+			log.Printf("Could not determine version of %s: %v", binary, err)
+			return
+		}
+
+		// Anything after the first line is upgrade notices and the like
+		probe.version, _, _ = strings.Cut(strings.TrimSpace(string(output)), "\n")
+	})
+	return probe.version
+}
+
+// isCinc reports whether binary is a Cinc Auditor build, which doesn't take
+// Chef license options.
+func isCinc(binary string) bool {
+	return strings.HasPrefix(filepath.Base(binary), "cinc")
+}
+
+// collectReport reads and parses the JSON report written by InSpec.
+func collectReport(path string) (*models.Run, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read InSpec report: %v", err)
+	}
+	return report.Parse(data)
+}
@@ -0,0 +1,144 @@
+//go:build unix
+
+package executor
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+)
+
+// stubReport is a minimal report the stub engine writes.
+const stubReport = `{"version": "5.22.3", "profiles": [{"name": "p", "controls": [{"id": "c", "results": [{"status": "failed"}]}]}]}`
+
+// stubEngine writes a shell script that behaves like the InSpec CLI: it
+// prints a version, or writes report (if not empty) to the path of its
+// json reporter and exits with code. It returns the script's path.
+func stubEngine(t *testing.T, report string, code int) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "inspec")
+	script := fmt.Sprintf(`#!/bin/sh
+if [ "$1" = version ]; then
+	echo 5.22.3
+	echo "A new version is available"
+	exit 0
+fi
+for arg in "$@"; do
+	case "$arg" in
+	json:*) report="${arg#json:}" ;;
+	esac
+done
+echo "running $2"
+if [ -n '%s' ]; then
+	printf '%%s' '%s' > "$report"
+fi
+exit %d
+`, report, report, code)
+	if err := os.WriteFile(path, []byte(script), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestCLIExecute(t *testing.T) {
+	tests := []struct {
+		name       string
+		report     string
+		code       int
+		wantErr    string
+		wantReport bool
+	}{
+		{name: "passed", report: stubReport, code: 0, wantReport: true},
+		{name: "failed controls", report: stubReport, code: 100, wantReport: true},
+		{name: "skipped controls", report: stubReport, code: 101, wantReport: true},
+		{name: "error with report", report: stubReport, code: 1, wantErr: "exited with code 1", wantReport: true},
+		{name: "error without report", code: 1, wantErr: "exited with code 1"},
+		{name: "error with invalid report", report: "{", code: 172, wantErr: "exited with code 172"},
+		{name: "success without report", code: 0, wantErr: "failed to read InSpec report"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			e := NewCLI(map[string]string{"inspec": stubEngine(t, tt.report, tt.code)}, "")
+			res, err := e.Execute(context.Background(), Request{JobID: 1, Hostname: "host", Username: "user", Profile: "profile", Engine: "inspec", PrivateKey: []byte("key")}, nil)
+
+			if tt.wantErr == "" && err != nil {
+				t.Fatalf("Execute: %v", err)
+			}
+			if tt.wantErr != "" && (err == nil || !strings.Contains(err.Error(), tt.wantErr)) {
+				t.Fatalf("Execute error = %v, want %q", err, tt.wantErr)
+			}
+			if res.ExitCode == nil || *res.ExitCode != tt.code {
+				t.Errorf("exit code = %v, want %d", res.ExitCode, tt.code)
+			}
+			if got := res.Report != nil; got != tt.wantReport {
+				t.Errorf("report returned = %t, want %t", got, tt.wantReport)
+			}
+			if res.Report != nil && res.Report.Summary.Failed != 1 {
+				t.Errorf("report summary = %+v, want one failed control", res.Report.Summary)
+			}
+			if !strings.Contains(res.Output, "running profile") {
+				t.Errorf("output = %q, want the engine's log", res.Output)
+			}
+			if res.EngineVersion != "5.22.3" {
+				t.Errorf("engine version = %q, want 5.22.3", res.EngineVersion)
+			}
+		})
+	}
+}
+
+func TestCLIExecuteUnknownEngine(t *testing.T) {
+	e := NewCLI(map[string]string{"inspec": "inspec"}, "")
+	if _, err := e.Execute(context.Background(), Request{Engine: "nope"}, nil); err == nil {
+		t.Fatal("Execute with an unknown engine succeeded")
+	}
+}
+
+func TestCLIVersionProbedOnce(t *testing.T) {
+	for _, code := range []int{0, 1} {
+		t.Run(fmt.Sprintf("exit %d", code), func(t *testing.T) {
+			dir := t.TempDir()
+			calls := filepath.Join(dir, "calls")
+			path := filepath.Join(dir, "inspec")
+			script := fmt.Sprintf("#!/bin/sh\necho probe >> %q\nsleep 0.2\necho 5.22.3\nexit %d\n", calls, code)
+			if err := os.WriteFile(path, []byte(script), 0o755); err != nil {
+				t.Fatal(err)
+			}
+
+			e := NewCLI(map[string]string{"inspec": path}, "")
+			var wg sync.WaitGroup
+			versions := make([]string, 4)
+			for i := range versions {
+				wg.Add(1)
+				go func() {
+					defer wg.Done()
+					versions[i] = e.version(path)
+				}()
+			}
+			wg.Wait()
+
+			want := "5.22.3"
+			if code != 0 {
+				want = ""
+			}
+			for _, v := range versions {
+				if v != want {
+					t.Errorf("version = %q, want %q", v, want)
+				}
+			}
+			if got := e.version(path); got != want {
+				t.Errorf("cached version = %q, want %q", got, want)
+			}
+			data, err := os.ReadFile(calls)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if n := strings.Count(string(data), "probe"); n != 1 {
+				t.Errorf("binary probed %d times, want once", n)
+			}
+		})
+	}
+}
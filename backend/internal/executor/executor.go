@@ -0,0 +1,45 @@
+// Package executor runs InSpec profiles against remote hosts. The Executor
+// interface decouples the job runner from the InSpec CLI so that it can be
+// replaced by a Fake in tests.
+package executor
+
+import (
+	"context"
+	"io"
+
+	"github.com/ahasunos/caas/backend/internal/models"
+)
+
+// Request describes a single profile execution.
+type Request struct {
+	// JobID identifies the job the execution belongs to, for logging.
+	JobID    int
+	Hostname string
+	Username string
+	Profile  string
+	// Engine names the scan engine to run the profile with.
+	Engine string
+	// PrivateKey is the decoded SSH private key used to log in to Hostname.
+	PrivateKey []byte
+}
+
+// Result is what an Executor collects from an execution.
+type Result struct {
+	// Output is the raw log of the run.
+	Output string
+	// ExitCode is nil if the process could not be started.
+	ExitCode *int
+	// Report holds the parsed report, or nil if none was produced.
+	Report *models.Run
+	// EngineVersion is the version of the engine that ran the profile.
+	EngineVersion string
+}
+
+// Executor runs InSpec profiles.
+type Executor interface {
+	// Execute prepares the credentials of req, runs its profile and collects
+	// the report. If out is not nil, the log is also written to it as it is
+	// produced. Execution stops when ctx is done. A scan that completed with
+	// failing or skipped controls is not an error.
+	Execute(ctx context.Context, req Request, out io.Writer) (Result, error)
+}
@@ -0,0 +1,82 @@
+package executor
+
+import (
+	"context"
+	"io"
+	"sync"
+	"time"
+
+	"github.com/ahasunos/caas/backend/internal/models"
+)
+
+// FakeStep scripts the outcome of one call to Fake.Execute.
+type FakeStep struct {
+	// Delay is how long the execution takes. A cancelled context cuts it
+	// short, as it would a real scan.
+	Delay time.Duration
+	// Output is written to the live output and returned as the log.
+	Output   string
+	ExitCode int
+	// Report is returned as a copy, so the same report can be reused.
+	Report *models.Run
+	Err    error
+}
+
+// Fake is a scriptable Executor that never starts a process. Each call to
+// Execute plays the next step of the script; once the script is exhausted,
+// the last step is repeated. A Fake without steps returns an empty, passing
+// result.
+type Fake struct {
+	mu    sync.Mutex
+	steps []FakeStep
+	calls []Request
+}
+
+// NewFake returns a Fake that plays steps in order.
+func NewFake(steps ...FakeStep) *Fake {
+	return &Fake{steps: steps}
+}
+
+// Calls returns the requests the Fake has received so far.
+func (f *Fake) Calls() []Request {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	return append([]Request(nil), f.calls...)
+}
+
+// Execute plays the next scripted step.
+func (f *Fake) Execute(ctx context.Context, req Request, out io.Writer) (Result, error) {
+	f.mu.Lock()
+	var step FakeStep
+	if n := len(f.calls); n < len(f.steps) {
+		step = f.steps[n]
+	} else if len(f.steps) > 0 {
+		step = f.steps[len(f.steps)-1]
+	}
+	req.PrivateKey = append([]byte(nil), req.PrivateKey...)
+	f.calls = append(f.calls, req)
+	f.mu.Unlock()
+
+	if step.Delay > 0 {
+		timer := time.NewTimer(step.Delay)
+		defer timer.Stop()
+		select {
+		case <-timer.C:
+		case <-ctx.Done():
+			return Result{}, ctx.Err()
+		}
+	}
+
+	if out != nil && step.Output != "" {
+		io.WriteString(out, step.Output)
+	}
+
+	code := step.ExitCode
+	res := Result{Output: step.Output, ExitCode: &code, EngineVersion: "fake"}
+	if step.Report != nil {
+		run := *step.Report
+		res.Report = &run
+	}
+	return res, step.Err
+}
@@ -0,0 +1,11 @@
+//go:build !unix
+
+package executor
+
+import "os/exec"
+
+// setProcessGroup is a no-op on platforms without process groups; the
+// command's context still kills the InSpec process itself.
+func setProcessGroup(cmd *exec.Cmd) (done func()) {
+	return func() {}
+}
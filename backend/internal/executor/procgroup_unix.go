@@ -0,0 +1,52 @@
+//go:build unix
+
+package executor
+
+import (
+	"os/exec"
+	"sync"
+	"syscall"
+	"time"
+)
+
+// killGracePeriod is how long InSpec gets to exit after SIGTERM before its
+// whole process group is killed.
+const killGracePeriod = 10 * time.Second
+
+// setProcessGroup makes cmd the leader of a new process group and arranges
+// for the whole group, including SSH sessions and other children spawned by
+// InSpec, to be terminated when the command's context is done. The returned
+// function must be called once cmd.Wait has returned: it stops a pending
+// kill, as the group ID may be reused once the group is gone.
+func setProcessGroup(cmd *exec.Cmd) (done func()) {
+	var mu sync.Mutex
+	var kill *time.Timer
+	exited := false
+
+	cmd.SysProcAttr = &syscall.SysProcAttr{Setpgid: true}
+	cmd.Cancel = func() error {
+		pgid := -cmd.Process.Pid
+		if err := syscall.Kill(pgid, syscall.SIGTERM); err != nil {
+			return err
+		}
+		mu.Lock()
+		defer mu.Unlock()
+		if !exited {
+			kill = time.AfterFunc(killGracePeriod, func() {
+				syscall.Kill(pgid, syscall.SIGKILL)
+			})
+		}
+		return nil
+	}
+	// Don't wait forever on pipes held open by children that ignore SIGTERM
+	cmd.WaitDelay = killGracePeriod + time.Second
+
+	return func() {
+		mu.Lock()
+		defer mu.Unlock()
+		exited = true
+		if kill != nil {
+			kill.Stop()
+		}
+	}
+}
@@ -0,0 +1,131 @@
+package github
+
+import (
+	"context"
+	"crypto"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// appAuth authenticates as an installation of a GitHub App. It signs a
+// short-lived JWT with the app's private key and exchanges it for an
+// installation access token, which is reused until shortly before it
+// expires.
+type appAuth struct {
+	http           *http.Client
+	apiURL         string
+	appID          int
+	installationID int
+	key            *rsa.PrivateKey
+
+	mu      sync.Mutex
+	token   string
+	expires time.Time
+}
+
+func newAppAuth(client *http.Client, apiURL string, appID, installationID int, privateKey string) (*appAuth, error) {
+	if installationID == 0 {
+		return nil, errors.New("a GitHub App installation ID is required")
+	}
+	key, err := parsePrivateKey([]byte(privateKey))
+	if err != nil {
+		return nil, err
+	}
+	return &appAuth{http: client, apiURL: apiURL, appID: appID, installationID: installationID, key: key}, nil
+}
+
+// parsePrivateKey parses the PEM encoded RSA key GitHub generates for apps.
+func parsePrivateKey(data []byte) (*rsa.PrivateKey, error) {
+	block, _ := pem.Decode(data)
+	if block == nil {
+		return nil, errors.New("GitHub App private key is not PEM encoded")
+	}
+	if key, err := x509.ParsePKCS1PrivateKey(block.Bytes); err == nil {
+		return key, nil
+	}
+	key, err := x509.ParsePKCS8PrivateKey(block.Bytes)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse GitHub App private key: %v", err)
+	}
+	rsaKey, ok := key.(*rsa.PrivateKey)
+	if !ok {
+		return nil, errors.New("GitHub App private key is not an RSA key")
+	}
+	return rsaKey, nil
+}
+
+// Token returns an installation access token, requesting a new one if the
+// current token expires within a minute.
+func (a *appAuth) Token(ctx context.Context) (string, error) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	if a.token != "" && time.Until(a.expires) > time.Minute {
+		return a.token, nil
+	}
+
+	jwt, err := a.jwt(time.Now())
+	if err != nil {
+		return "", err
+	}
+	url := fmt.Sprintf("%s/app/installations/%d/access_tokens", a.apiURL, a.installationID)
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, url, nil)
+	if err != nil {
+		return "", fmt.Errorf("failed to create request: %v", err)
+	}
+	req.Header.Set("User-Agent", userAgent)
+	req.Header.Set("Accept", "application/vnd.github+json")
+	req.Header.Set("Authorization", "Bearer "+jwt)
+
+	resp, err := a.http.Do(req)
+	if err != nil {
+		return "", fmt.Errorf("failed to request GitHub App installation token: %v", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusCreated {
+		return "", fmt.Errorf("GitHub returned status %d for the GitHub App installation token", resp.StatusCode)
+	}
+
+	var result struct {
+		Token     string    `json:"token"`
+		ExpiresAt time.Time `json:"expires_at"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+		return "", fmt.Errorf("failed to decode GitHub App installation token: %v", err)
+	}
+	a.token, a.expires = result.Token, result.ExpiresAt
+	return a.token, nil
+}
+
+// jwt returns a JWT identifying the app, signed with RS256. It is backdated
+// a minute to allow for clock drift and valid for the maximum of ten
+// minutes.
+func (a *appAuth) jwt(now time.Time) (string, error) {
+	header := base64.RawURLEncoding.EncodeToString([]byte(`{"alg":"RS256","typ":"JWT"}`))
+	claims, err := json.Marshal(map[string]interface{}{
+		"iat": now.Add(-time.Minute).Unix(),
+		"exp": now.Add(9 * time.Minute).Unix(),
+		"iss": a.appID,
+	})
+	if err != nil {
+		return "", err
+	}
+	unsigned := header + "." + base64.RawURLEncoding.EncodeToString(claims)
+
+	digest := sha256.Sum256([]byte(unsigned))
+	signature, err := rsa.SignPKCS1v15(rand.Reader, a.key, crypto.SHA256, digest[:])
+	if err != nil {
+		return "", fmt.Errorf("failed to sign GitHub App JWT: %v", err)
+	}
+	return unsigned + "." + base64.RawURLEncoding.EncodeToString(signature), nil
+}
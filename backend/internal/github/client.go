@@ -0,0 +1,407 @@
+package github
+
+import (
+	"cmp"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"path"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Defaults for the options of public GitHub.
+const (
+	defaultAPIURL      = "https://api.github.com"
+	defaultWebURL      = "https://github.com"
+	defaultSearchQuery = "inspec profile"
+)
+
+// userAgent identifies the service to GitHub, which rejects requests
+// without one.
+const userAgent = "InSpecService"
+
+// maxCachedBytes bounds the total size of the response bodies kept for
+// conditional requests.
+const maxCachedBytes = 32 << 20
+
+// ErrRateLimited is returned when GitHub's rate limit would make a request
+// wait longer than the client's MaxWait.
+var ErrRateLimited = errors.New("GitHub rate limit exceeded")
+
+// Options configures a Client. Without a token or GitHub App the client
+// makes unauthenticated requests, which GitHub limits to 60 an hour.
+type Options struct {
+	// Name identifies the host in logs and sync checkpoints.
+	Name string
+	// APIURL is the base URL of the REST API: https://api.github.com for
+	// GitHub and https://<host>/api/v3 for GitHub Enterprise Server.
+	APIURL string
+	// WebURL is the base URL of the host's repositories, e.g.
+	// https://github.com. Only repositories below it are looked up.
+	WebURL string
+	// SearchQuery is the repository search that discovers profiles. It
+	// defaults to "inspec profile" unless other discovery rules are set.
+	SearchQuery string
+	// Orgs and Users are organizations and users all of whose repositories
+	// are checked for profiles.
+	Orgs  []string
+	Users []string
+	// Topics discover the repositories tagged with any of them.
+	Topics []string
+	// Repos are repositories, given as owner/name, that are checked for
+	// profiles.
+	Repos []string
+	// Exclude are glob patterns of the owner/name of repositories that
+	// discovery skips, e.g. "octocat/*" or "*/linux-baseline-fork".
+	Exclude []string
+	// HTTPClient sends the requests; a client with a one minute timeout is
+	// used if it is nil.
+	HTTPClient *http.Client
+	// PageSize is the number of search results requested per page, at most
+	// 100.
+	PageSize int
+	// Workers bounds how many repositories are checked for an inspec.yml
+	// file at the same time during discovery.
+	Workers int
+
+	// Token is a personal access token or any other OAuth token.
+	Token string
+	// AppID, AppInstallationID and AppPrivateKey authenticate as an
+	// installation of a GitHub App. They are ignored if Token is set.
+	AppID             int
+	AppInstallationID int
+	AppPrivateKey     string
+	// MaxRetries bounds how often a request failing with a server error or
+	// a secondary rate limit is retried.
+	MaxRetries int
+	// MaxWait is the longest the client waits for a rate limit to reset
+	// before giving up with ErrRateLimited.
+	MaxWait time.Duration
+}
+
+// Client is a GitHub API client shared by everything that talks to GitHub.
+// It honours GitHub's rate limits, retries transient failures with backoff
+// and revalidates responses it has seen before with their ETag, which
+// doesn't count against the rate limit.
+type Client struct {
+	name        string
+	apiURL      string
+	webURL      string
+	searchQuery string
+	orgs        []string
+	users       []string
+	topics      []string
+	repos       []string
+	exclude     []string
+	pageSize    int
+	workers     int
+
+	http       *http.Client
+	token      func(ctx context.Context) (string, error)
+	maxRetries int
+	maxWait    time.Duration
+
+	mu sync.Mutex
+	// blocked holds, per rate limit resource (core, search, ...), when an
+	// exhausted limit resets.
+	blocked map[string]time.Time
+	// cache holds the latest response with an ETag per request, oldest
+	// first in order; cacheSize is the total size of their bodies.
+	cache     map[string]cachedResponse
+	order     []string
+	cacheSize int
+}
+
+type cachedResponse struct {
+	etag string
+	body []byte
+}
+
+// response is a successful response of the GitHub API.
+type response struct {
+	status int
+	header http.Header
+	body   []byte
+}
+
+// NewClient returns a client authenticating as configured in opts.
+func NewClient(opts Options) (*Client, error) {
+	c := &Client{
+		name:        opts.Name,
+		apiURL:      strings.TrimSuffix(cmp.Or(opts.APIURL, defaultAPIURL), "/"),
+		webURL:      strings.TrimSuffix(cmp.Or(opts.WebURL, defaultWebURL), "/"),
+		searchQuery: opts.SearchQuery,
+		orgs:        opts.Orgs,
+		users:       opts.Users,
+		topics:      opts.Topics,
+		repos:       opts.Repos,
+		pageSize:    min(cmp.Or(opts.PageSize, defaultPageSize), maxSearchPerPage),
+		workers:     cmp.Or(opts.Workers, defaultWorkers),
+		http:        opts.HTTPClient,
+		maxRetries:  opts.MaxRetries,
+		maxWait:     opts.MaxWait,
+		blocked:     make(map[string]time.Time),
+		cache:       make(map[string]cachedResponse),
+	}
+	if c.http == nil {
+		c.http = &http.Client{Timeout: time.Minute}
+	}
+	if c.name == "" {
+		c.name = strings.TrimPrefix(strings.TrimPrefix(c.webURL, "https://"), "http://")
+	}
+	if c.searchQuery == "" && len(c.orgs)+len(c.users)+len(c.topics)+len(c.repos) == 0 {
+		c.searchQuery = defaultSearchQuery
+	}
+	for _, pattern := range opts.Exclude {
+		pattern = strings.ToLower(pattern)
+		if _, err := path.Match(pattern, ""); err != nil {
+			return nil, fmt.Errorf("invalid exclude pattern %q: %v", pattern, err)
+		}
+		c.exclude = append(c.exclude, pattern)
+	}
+
+	switch {
+	case opts.Token != "":
+		c.token = func(context.Context) (string, error) { return opts.Token, nil }
+	case opts.AppID != 0:
+		app, err := newAppAuth(c.http, c.apiURL, opts.AppID, opts.AppInstallationID, opts.AppPrivateKey)
+		if err != nil {
+			return nil, err
+		}
+		c.token = app.Token
+	}
+	return c, nil
+}
+
+// Name returns the name of the host the client talks to.
+func (c *Client) Name() string {
+	return c.name
+}
+
+// WebURL returns the base URL of the host's repositories.
+func (c *Client) WebURL() string {
+	return c.webURL
+}
+
+// Owns reports whether repoURL is the URL of a repository on the client's
+// host, of the form <web URL>/<owner>/<repo>.
+func (c *Client) Owns(repoURL string) bool {
+	path, ok := strings.CutPrefix(repoURL, c.webURL+"/")
+	if !ok {
+		return false
+	}
+	owner, repo, ok := strings.Cut(strings.TrimSuffix(path, "/"), "/")
+	return ok && owner != "" && repo != "" && !strings.Contains(repo, "/")
+}
+
+// Token returns the token requests are authenticated with, or an empty
+// string if the client is unauthenticated. Installation tokens of GitHub
+// Apps are short-lived, so the token should be requested for every use.
+func (c *Client) Token(ctx context.Context) (string, error) {
+	if c.token == nil {
+		return "", nil
+	}
+	return c.token(ctx)
+}
+
+// get sends a GET request to the GitHub API, waiting out rate limits and
+// retrying transient failures. Responses are returned whatever their
+// status, except for rate limit and server errors that persist.
+func (c *Client) get(ctx context.Context, url, accept string) (*response, error) {
+	resource := rateLimitResource(url)
+	key := accept + " " + url
+
+	for attempt := 0; ; attempt++ {
+		if err := c.waitForReset(ctx, resource); err != nil {
+			return nil, err
+		}
+
+		req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+		if err != nil {
+			return nil, fmt.Errorf("failed to create request: %v", err)
+		}
+		req.Header.Set("User-Agent", userAgent)
+		req.Header.Set("Accept", accept)
+		req.Header.Set("X-GitHub-Api-Version", "2022-11-28")
+		if c.token != nil {
+			token, err := c.token(ctx)
+			if err != nil {
+				return nil, err
+			}
+			req.Header.Set("Authorization", "Bearer "+token)
+		}
+		cached, isCached := c.cached(key)
+		if isCached {
+			req.Header.Set("If-None-Match", cached.etag)
+		}
+
+		resp, err := c.http.Do(req)
+		if err != nil {
+			if ctx.Err() != nil || attempt >= c.maxRetries {
+				return nil, err
+			}
+			if err := sleep(ctx, backoff(attempt)); err != nil {
+				return nil, err
+			}
+			continue
+		}
+		body, err := io.ReadAll(resp.Body)
+		resp.Body.Close()
+		if err != nil {
+			return nil, fmt.Errorf("failed to read response: %v", err)
+		}
+		c.updateRateLimit(resp.Header)
+
+		if resp.StatusCode == http.StatusNotModified && isCached {
+			return &response{status: http.StatusOK, header: resp.Header, body: cached.body}, nil
+		}
+
+		if wait, retry := retryDelay(resp, body, attempt); retry {
+			if attempt >= c.maxRetries {
+				return nil, fmt.Errorf("GitHub API returned status %d after %d attempts", resp.StatusCode, attempt+1)
+			}
+			if wait > c.maxWait {
+				return nil, fmt.Errorf("%w: retry possible in %s", ErrRateLimited, wait.Round(time.Second))
+			}
+			log.Printf("GitHub API returned status %d for %s, retrying in %s", resp.StatusCode, url, wait.Round(time.Second))
+			if err := sleep(ctx, wait); err != nil {
+				return nil, err
+			}
+			continue
+		}
+
+		if etag := resp.Header.Get("ETag"); resp.StatusCode == http.StatusOK && etag != "" {
+			c.store(key, cachedResponse{etag: etag, body: body})
+		}
+		return &response{status: resp.StatusCode, header: resp.Header, body: body}, nil
+	}
+}
+
+// rateLimitResource returns the rate limit resource a request counts
+// against.
+func rateLimitResource(url string) string {
+	if strings.Contains(url, "/search/") {
+		return "search"
+	}
+	return "core"
+}
+
+// waitForReset waits until the rate limit of resource resets if it is
+// exhausted.
+func (c *Client) waitForReset(ctx context.Context, resource string) error {
+	c.mu.Lock()
+	reset := c.blocked[resource]
+	c.mu.Unlock()
+
+	wait := time.Until(reset)
+	if wait <= 0 {
+		return nil
+	}
+	if wait > c.maxWait {
+		return fmt.Errorf("%w: %s limit resets at %s", ErrRateLimited, resource, reset.Format(time.RFC3339))
+	}
+	log.Printf("GitHub %s rate limit exhausted, waiting %s", resource, wait.Round(time.Second))
+	return sleep(ctx, wait)
+}
+
+// updateRateLimit records when the rate limit resets if a response used
+// up the last request.
+func (c *Client) updateRateLimit(header http.Header) {
+	if header.Get("X-RateLimit-Remaining") != "0" {
+		return
+	}
+	reset, err := strconv.ParseInt(header.Get("X-RateLimit-Reset"), 10, 64)
+	if err != nil {
+		return
+	}
+	resource := header.Get("X-RateLimit-Resource")
+	if resource == "" {
+		resource = "core"
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	// Allow for clock skew between GitHub and this host
+	c.blocked[resource] = time.Unix(reset, 0).Add(time.Second)
+}
+
+// retryDelay reports whether a request should be retried and after how
+// long: rate limited requests once the limit resets or as GitHub asks in
+// Retry-After, server errors with exponential backoff.
+func retryDelay(resp *http.Response, body []byte, attempt int) (time.Duration, bool) {
+	switch resp.StatusCode {
+	case http.StatusForbidden, http.StatusTooManyRequests:
+		if s := resp.Header.Get("Retry-After"); s != "" {
+			if seconds, err := strconv.Atoi(s); err == nil {
+				return time.Duration(seconds) * time.Second, true
+			}
+		}
+		if resp.Header.Get("X-RateLimit-Remaining") == "0" {
+			if reset, err := strconv.ParseInt(resp.Header.Get("X-RateLimit-Reset"), 10, 64); err == nil {
+				return time.Until(time.Unix(reset, 0).Add(time.Second)), true
+			}
+		}
+		// Secondary rate limits come without headers; GitHub asks clients
+		// to wait at least a minute before retrying
+		if resp.StatusCode == http.StatusTooManyRequests || strings.Contains(strings.ToLower(string(body)), "rate limit") {
+			return time.Minute << attempt, true
+		}
+	case http.StatusInternalServerError, http.StatusBadGateway, http.StatusServiceUnavailable, http.StatusGatewayTimeout:
+		return backoff(attempt), true
+	}
+	return 0, false
+}
+
+// backoff returns the delay before retrying a failed request.
+func backoff(attempt int) time.Duration {
+	return time.Second << attempt
+}
+
+func sleep(ctx context.Context, d time.Duration) error {
+	t := time.NewTimer(d)
+	defer t.Stop()
+	select {
+	case <-t.C:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+func (c *Client) cached(key string) (cachedResponse, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	r, ok := c.cache[key]
+	return r, ok
+}
+
+// store caches r for key, evicting the oldest responses while the cache
+// holds more than maxCachedBytes. Responses too large to fit aren't
+// cached.
+func (c *Client) store(key string, r cachedResponse) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if old, ok := c.cache[key]; ok {
+		c.cacheSize -= len(old.body)
+		delete(c.cache, key)
+		c.order = slices.DeleteFunc(c.order, func(k string) bool { return k == key })
+	}
+	if len(r.body) > maxCachedBytes {
+		return
+	}
+	c.cache[key] = r
+	c.order = append(c.order, key)
+	c.cacheSize += len(r.body)
+	for c.cacheSize > maxCachedBytes {
+		c.cacheSize -= len(c.cache[c.order[0]].body)
+		delete(c.cache, c.order[0])
+		c.order = c.order[1:]
+	}
+}
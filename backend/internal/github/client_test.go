@@ -0,0 +1,174 @@
+package github
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// testClient returns a client for the API served by handler.
+func testClient(t *testing.T, opts Options, handler http.HandlerFunc) *Client {
+	t.Helper()
+	server := httptest.NewServer(handler)
+	t.Cleanup(server.Close)
+	opts.APIURL = server.URL
+	c, err := NewClient(opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return c
+}
+
+func TestGetRetriesAfterRetryAfter(t *testing.T) {
+	var calls atomic.Int32
+	c := testClient(t, Options{MaxRetries: 2}, func(w http.ResponseWriter, r *http.Request) {
+		if calls.Add(1) == 1 {
+			w.Header().Set("Retry-After", "0")
+			w.WriteHeader(http.StatusForbidden)
+			w.Write([]byte(`{"message": "You have exceeded a secondary rate limit."}`))
+			return
+		}
+		w.Write([]byte("ok"))
+	})
+
+	resp, err := c.get(context.Background(), c.apiURL+"/repos/acme/baseline", "application/json")
+	if err != nil {
+		t.Fatalf("get: %v", err)
+	}
+	if resp.status != http.StatusOK || string(resp.body) != "ok" {
+		t.Errorf("response = %d %q, want 200 \"ok\"", resp.status, resp.body)
+	}
+	if n := calls.Load(); n != 2 {
+		t.Errorf("server got %d requests, want 2", n)
+	}
+}
+
+func TestGetGivesUpAfterMaxRetries(t *testing.T) {
+	var calls atomic.Int32
+	c := testClient(t, Options{MaxRetries: 1}, func(w http.ResponseWriter, r *http.Request) {
+		calls.Add(1)
+		w.Header().Set("Retry-After", "0")
+		w.WriteHeader(http.StatusTooManyRequests)
+	})
+
+	if _, err := c.get(context.Background(), c.apiURL+"/repos/acme/baseline", "application/json"); err == nil {
+		t.Fatal("get succeeded, want an error")
+	}
+	if n := calls.Load(); n != 2 {
+		t.Errorf("server got %d requests, want 2", n)
+	}
+}
+
+func TestGetRateLimited(t *testing.T) {
+	reset := strconv.FormatInt(time.Now().Add(time.Hour).Unix(), 10)
+	var calls atomic.Int32
+	c := testClient(t, Options{MaxRetries: 3, MaxWait: time.Minute}, func(w http.ResponseWriter, r *http.Request) {
+		calls.Add(1)
+		w.Header().Set("X-RateLimit-Remaining", "0")
+		w.Header().Set("X-RateLimit-Reset", reset)
+		w.Header().Set("X-RateLimit-Resource", "core")
+		if r.URL.Path == "/rate_limited" {
+			w.WriteHeader(http.StatusForbidden)
+			return
+		}
+		w.Write([]byte("ok"))
+	})
+	ctx := context.Background()
+
+	if _, err := c.get(ctx, c.apiURL+"/rate_limited", "application/json"); !errors.Is(err, ErrRateLimited) {
+		t.Errorf("get of a rate limited request = %v, want ErrRateLimited", err)
+	}
+
+	// The last request of the limit succeeds, the next one doesn't wait
+	// longer than MaxWait for the reset
+	c = testClient(t, Options{MaxWait: time.Minute}, func(w http.ResponseWriter, r *http.Request) {
+		calls.Add(1)
+		w.Header().Set("X-RateLimit-Remaining", "0")
+		w.Header().Set("X-RateLimit-Reset", reset)
+		w.Write([]byte("ok"))
+	})
+	calls.Store(0)
+	if _, err := c.get(ctx, c.apiURL+"/repos/acme/baseline", "application/json"); err != nil {
+		t.Fatalf("get: %v", err)
+	}
+	if _, err := c.get(ctx, c.apiURL+"/repos/acme/other", "application/json"); !errors.Is(err, ErrRateLimited) {
+		t.Errorf("get after the limit was used up = %v, want ErrRateLimited", err)
+	}
+	if _, err := c.get(ctx, c.apiURL+"/search/repositories", "application/json"); err != nil {
+		t.Errorf("get of the search resource: %v", err)
+	}
+	if n := calls.Load(); n != 2 {
+		t.Errorf("server got %d requests, want 2", n)
+	}
+}
+
+func TestGetRevalidatesWithETag(t *testing.T) {
+	var calls atomic.Int32
+	c := testClient(t, Options{}, func(w http.ResponseWriter, r *http.Request) {
+		calls.Add(1)
+		if r.Header.Get("If-None-Match") == `"v1"` {
+			w.WriteHeader(http.StatusNotModified)
+			return
+		}
+		w.Header().Set("ETag", `"v1"`)
+		w.Write([]byte("profile"))
+	})
+	ctx := context.Background()
+	url := c.apiURL + "/repos/acme/baseline/contents/inspec.yml"
+
+	for i := range 2 {
+		resp, err := c.get(ctx, url, "application/json")
+		if err != nil {
+			t.Fatalf("get %d: %v", i, err)
+		}
+		if resp.status != http.StatusOK || string(resp.body) != "profile" {
+			t.Errorf("get %d = %d %q, want 200 \"profile\"", i, resp.status, resp.body)
+		}
+	}
+	if n := calls.Load(); n != 2 {
+		t.Errorf("server got %d requests, want 2", n)
+	}
+
+	// The cache is keyed by the media type too
+	if _, err := c.get(ctx, url, "application/vnd.github.raw"); err != nil {
+		t.Fatal(err)
+	}
+	if len(c.cache) != 2 {
+		t.Errorf("cache holds %d responses, want 2", len(c.cache))
+	}
+}
+
+func TestStoreEvictsOldest(t *testing.T) {
+	c, err := NewClient(Options{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	half := make([]byte, maxCachedBytes/2)
+	c.store("a", cachedResponse{etag: "a", body: half})
+	c.store("b", cachedResponse{etag: "b", body: half})
+	c.store("a", cachedResponse{etag: "a2", body: half})
+	c.store("c", cachedResponse{etag: "c", body: half})
+
+	if _, ok := c.cached("b"); ok {
+		t.Error("b still cached, want it evicted as the oldest")
+	}
+	if r, ok := c.cached("a"); !ok || r.etag != "a2" {
+		t.Errorf("cached(a) = %q, %v, want the refreshed response", r.etag, ok)
+	}
+	if c.cacheSize != maxCachedBytes {
+		t.Errorf("cacheSize = %d, want %d", c.cacheSize, maxCachedBytes)
+	}
+
+	c.store("huge", cachedResponse{etag: "huge", body: make([]byte, maxCachedBytes+1)})
+	if _, ok := c.cached("huge"); ok {
+		t.Error("response larger than the cache was cached")
+	}
+	if _, ok := c.cached("c"); !ok {
+		t.Error("c evicted by a response that isn't cached")
+	}
+}
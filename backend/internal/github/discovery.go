@@ -0,0 +1,393 @@
+package github
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"net/url"
+	"path"
+	"slices"
+	"strings"
+	"sync"
+
+	"github.com/ahasunos/caas/backend/internal/models"
+)
+
+// Limits of GitHub's search API.
+const (
+	maxSearchPerPage = 100
+	maxSearchResults = 1000
+)
+
+// Defaults for discovery options.
+const (
+	defaultPageSize = 100
+	defaultWorkers  = 8
+)
+
+// positionBits is the number of bits of a discovery position that hold the
+// page; the bits above them hold the index of the listing.
+const positionBits = 16
+
+// DiscoveryStats counts what a discovery run found.
+type DiscoveryStats struct {
+	// Pages is the number of pages of repositories fetched.
+	Pages int `json:"pages"`
+	// Repositories is the number of distinct repositories found.
+	Repositories int `json:"repositories"`
+	// Duplicates counts repositories that appeared again on a later page,
+	// as search results shift while they are paged through, or in another
+	// listing.
+	Duplicates int `json:"duplicates"`
+	// Excluded counts repositories skipped by an exclude pattern.
+	Excluded int `json:"excluded"`
+	// Profiles is the number of repositories with an inspec.yml file.
+	Profiles int `json:"profiles"`
+}
+
+// listing is a paged list of repositories that discovery goes through: a
+// repository search, the repositories of an organization or user, or an
+// explicit list of repositories.
+type listing struct {
+	// name describes the listing in logs, e.g. "organization dev-sec".
+	name string
+	// url returns the API URL of a page of a search or of an owner's
+	// repositories.
+	url func(page int) string
+	// search is set for search listings, whose results are wrapped in a
+	// search result and capped.
+	search bool
+	// described skips repositories without a description, which filters
+	// out much of the noise of free-text searches.
+	described bool
+	// repos are the owner/name of the repositories of an explicit list.
+	repos []string
+}
+
+// repoPage is a page of repositories of a listing, or the error fetching
+// it.
+type repoPage struct {
+	listing int
+	page    int
+	repos   []models.GitHubRepo
+	err     error
+}
+
+// listings returns the listings configured for the client: the search
+// query, then organizations, users, topics and explicit repositories.
+func (c *Client) listings() []listing {
+	var ls []listing
+	searchListing := func(name, q string, described bool) listing {
+		return listing{name: name, search: true, described: described, url: func(page int) string {
+			return fmt.Sprintf("%s/search/repositories?q=%s&sort=stars&per_page=%d&page=%d", c.apiURL, url.QueryEscape(q), c.pageSize, page)
+		}}
+	}
+	ownerListing := func(label, kind, owner, repoType string) listing {
+		return listing{name: label + " " + owner, url: func(page int) string {
+			return fmt.Sprintf("%s/%s/%s/repos?type=%s&sort=full_name&per_page=%d&page=%d", c.apiURL, kind, url.PathEscape(owner), repoType, c.pageSize, page)
+		}}
+	}
+
+	if c.searchQuery != "" {
+		ls = append(ls, searchListing("search "+c.searchQuery, c.searchQuery, true))
+	}
+	for _, org := range c.orgs {
+		ls = append(ls, ownerListing("organization", "orgs", org, "all"))
+	}
+	for _, user := range c.users {
+		ls = append(ls, ownerListing("user", "users", user, "owner"))
+	}
+	for _, topic := range c.topics {
+		ls = append(ls, searchListing("topic "+topic, "topic:"+topic, false))
+	}
+	if len(c.repos) > 0 {
+		ls = append(ls, listing{name: "repositories", repos: c.repos})
+	}
+	return ls
+}
+
+// DiscoveryKey describes the settings that the positions passed to the
+// callback of FetchProfilesFromGitHub depend on: the page size and the
+// listings in order, summed up by a hash. A position recorded under another
+// key points elsewhere and must not be resumed from.
+func (c *Client) DiscoveryKey() string {
+	h := sha256.New()
+	for _, l := range c.listings() {
+		fmt.Fprintln(h, l.name)
+		for _, repo := range l.repos {
+			fmt.Fprintln(h, repo)
+		}
+	}
+	return fmt.Sprintf("per_page=%d listings=%x", c.pageSize, h.Sum(nil)[:8])
+}
+
+// Exhaustive reports whether discovery lists every repository matching the
+// client's rules. Search results, of the search query and of topics alike,
+// are capped and shift while they are paged through, so a repository
+// discovery missed may still match.
+func (c *Client) Exhaustive() bool {
+	return !slices.ContainsFunc(c.listings(), func(l listing) bool { return l.search })
+}
+
+// Excluded reports whether the repository with the given owner/name
+// matches one of the client's exclude patterns.
+func (c *Client) Excluded(fullName string) bool {
+	fullName = strings.ToLower(fullName)
+	for _, pattern := range c.exclude {
+		if ok, _ := path.Match(pattern, fullName); ok {
+			return true
+		}
+	}
+	return false
+}
+
+// Function to fetch profiles from GitHub API. Discovery is a pipeline: the
+// next page of repositories is fetched while the repositories of the
+// current page are checked for an inspec.yml file by a bounded pool of
+// workers. Repositories are listed by the client's search query,
+// organizations, users, topics and explicit repositories in turn, skipping
+// those matching an exclude pattern. Listing starts at position start, and
+// the profiles found on each page are handed to fn in listing order along
+// with the position of the next page, so that a sync interrupted by an
+// error can resume where it stopped.
+func (c *Client) FetchProfilesFromGitHub(ctx context.Context, start int, fn func(next int, profiles []models.Profile) error) (DiscoveryStats, error) {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	var stats DiscoveryStats
+	seen := make(map[int64]bool)
+	listings := c.listings()
+
+	for p := range c.pages(ctx, listings, max(start, 1)) {
+		if p.err != nil {
+			return stats, p.err
+		}
+		stats.Pages++
+
+		// Skip repositories already seen on earlier pages
+		var repos []models.GitHubRepo
+		for _, repo := range p.repos {
+			if seen[repo.ID] {
+				stats.Duplicates++
+				continue
+			}
+			seen[repo.ID] = true
+			stats.Repositories++
+			if c.Excluded(repo.FullName) {
+				stats.Excluded++
+				continue
+			}
+			if repo.Description != "" || !listings[p.listing].described {
+				repos = append(repos, repo)
+			}
+		}
+
+		profiles, err := c.checkRepos(ctx, repos)
+		if err != nil {
+			return stats, err
+		}
+		stats.Profiles += len(profiles)
+
+		if err := fn(p.listing<<positionBits|(p.page+1), profiles); err != nil {
+			return stats, err
+		}
+	}
+	return stats, nil
+}
+
+// pages fetches pages of the listings, one page ahead of the consumer,
+// starting at position start, until the listings are exhausted, an error
+// occurs or ctx is cancelled.
+func (c *Client) pages(ctx context.Context, listings []listing, start int) <-chan repoPage {
+	pages := make(chan repoPage, 1)
+	go func() {
+		defer close(pages)
+		first, page := start>>positionBits, start&(1<<positionBits-1)
+		for i := first; i < len(listings); i++ {
+			if i > first {
+				page = 1
+			}
+			for ; ; page++ {
+				repos, more, err := c.listPage(ctx, listings[i], page)
+				if err == nil && len(repos) == 0 && !more {
+					break
+				}
+				select {
+				case pages <- repoPage{listing: i, page: page, repos: repos, err: err}:
+				case <-ctx.Done():
+					return
+				}
+				if err != nil {
+					return
+				}
+				if !more {
+					break
+				}
+			}
+		}
+	}()
+	return pages
+}
+
+// listPage fetches a page of a listing and reports whether there are more.
+func (c *Client) listPage(ctx context.Context, l listing, page int) ([]models.GitHubRepo, bool, error) {
+	switch {
+	case l.repos != nil:
+		return c.fetchRepos(ctx, l.repos, page)
+	case l.search:
+		// GitHub only returns the first 1000 search results
+		if (page-1)*c.pageSize >= maxSearchResults {
+			return nil, false, nil
+		}
+		result, err := c.searchRepositories(ctx, l.url(page))
+		if err != nil {
+			return nil, false, err
+		}
+		more := len(result.Items) > 0 && page*c.pageSize < min(result.TotalCount, maxSearchResults)
+		return result.Items, more, nil
+	default:
+		var repos []models.GitHubRepo
+		err := c.getJSON(ctx, l.url(page), &repos)
+		if errors.Is(err, ErrRepositoryNotFound) {
+			log.Printf("Configured %s not found on %s", l.name, c.name)
+			return nil, false, nil
+		}
+		if err != nil {
+			return nil, false, fmt.Errorf("failed to list repositories of %s: %w", l.name, err)
+		}
+		return repos, len(repos) == c.pageSize, nil
+	}
+}
+
+func (c *Client) searchRepositories(ctx context.Context, searchURL string) (models.GitHubSearchResult, error) {
+	var result models.GitHubSearchResult
+
+	resp, err := c.get(ctx, searchURL, "application/vnd.github+json")
+	if err != nil {
+		return result, fmt.Errorf("failed to fetch profiles from GitHub: %w", err)
+	}
+	// Pages past the end of the results are reported as unprocessable
+	if resp.status == http.StatusUnprocessableEntity {
+		return result, nil
+	}
+	if resp.status != http.StatusOK {
+		return result, fmt.Errorf("unexpected status code %d from GitHub API", resp.status)
+	}
+
+	if err := json.Unmarshal(resp.body, &result); err != nil {
+		return result, fmt.Errorf("failed to decode response from GitHub: %v", err)
+	}
+	return result, nil
+}
+
+// fetchRepos fetches a page of an explicit list of repositories. Missing
+// repositories are logged and left out.
+func (c *Client) fetchRepos(ctx context.Context, names []string, page int) ([]models.GitHubRepo, bool, error) {
+	from := min((page-1)*c.pageSize, len(names))
+	to := min(page*c.pageSize, len(names))
+
+	var repos []models.GitHubRepo
+	for _, name := range names[from:to] {
+		var repo models.GitHubRepo
+		err := c.getJSON(ctx, fmt.Sprintf("%s/repos/%s", c.apiURL, name), &repo)
+		if errors.Is(err, ErrRepositoryNotFound) {
+			log.Printf("Configured repository %s not found on %s", name, c.name)
+			continue
+		}
+		if err != nil {
+			return nil, false, fmt.Errorf("failed to fetch repository %s: %w", name, err)
+		}
+		repos = append(repos, repo)
+	}
+	return repos, to < len(names), nil
+}
+
+// getJSON decodes the response to a GitHub API request into v. It returns
+// ErrRepositoryNotFound for a 404 response.
+func (c *Client) getJSON(ctx context.Context, apiURL string, v interface{}) error {
+	resp, err := c.get(ctx, apiURL, "application/vnd.github+json")
+	if err != nil {
+		return err
+	}
+	if resp.status == http.StatusNotFound {
+		return ErrRepositoryNotFound
+	}
+	if resp.status != http.StatusOK {
+		return fmt.Errorf("unexpected status code %d from GitHub API", resp.status)
+	}
+	if err := json.Unmarshal(resp.body, v); err != nil {
+		return fmt.Errorf("failed to decode response from GitHub: %v", err)
+	}
+	return nil
+}
+
+// checkRepos fetches the inspec.yml files of repos using up to c.workers
+// requests at a time, and returns the repositories that have one as
+// profiles, in the order of repos. The parents of forks are looked up.
+// Repositories whose check fails are left out, unless the failure means
+// that the remaining checks would fail too.
+func (c *Client) checkRepos(ctx context.Context, repos []models.GitHubRepo) ([]models.Profile, error) {
+	ctx, cancel := context.WithCancelCause(ctx)
+	defer cancel(nil)
+
+	found := make([]*models.Profile, len(repos))
+	sem := make(chan struct{}, c.workers)
+	var wg sync.WaitGroup
+
+	for i, repo := range repos {
+		select {
+		case sem <- struct{}{}:
+		case <-ctx.Done():
+		}
+		if ctx.Err() != nil {
+			break
+		}
+
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			defer func() { <-sem }()
+
+			// Check if inspec.yml exists in the repository's root
+			meta, err := c.FetchMetadata(ctx, repo.HTMLURL)
+			if errors.Is(err, ErrRateLimited) {
+				cancel(err)
+				return
+			}
+			if err != nil {
+				return
+			}
+			profile := repoProfile(repo)
+			profile.Metadata = meta
+			if profile.Fork && profile.ParentURL == "" {
+				parent, err := c.forkParent(ctx, repo)
+				if errors.Is(err, ErrRateLimited) {
+					cancel(err)
+					return
+				}
+				if err != nil {
+					log.Printf("Could not look up parent of fork %s: %v", repo.HTMLURL, err)
+				}
+				profile.ParentURL = parent
+			}
+			found[i] = &profile
+		}()
+	}
+	wg.Wait()
+
+	if ctx.Err() != nil {
+		return nil, context.Cause(ctx)
+	}
+
+	var profiles []models.Profile
+	for _, profile := range found {
+		if profile != nil {
+			profiles = append(profiles, *profile)
+		}
+	}
+	return profiles, nil
+}
@@ -0,0 +1,55 @@
+package github
+
+import "testing"
+
+func TestExhaustive(t *testing.T) {
+	tests := []struct {
+		name string
+		opts Options
+		want bool
+	}{
+		{name: "default search", opts: Options{}, want: false},
+		{name: "search query", opts: Options{SearchQuery: "inspec", Orgs: []string{"dev-sec"}}, want: false},
+		{name: "topics", opts: Options{Topics: []string{"inspec-profile"}}, want: false},
+		{name: "owners and repositories", opts: Options{Orgs: []string{"dev-sec"}, Users: []string{"octocat"}, Repos: []string{"acme/baseline"}}, want: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c, err := NewClient(tt.opts)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := c.Exhaustive(); got != tt.want {
+				t.Errorf("Exhaustive() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestDiscoveryKey(t *testing.T) {
+	key := func(opts Options) string {
+		t.Helper()
+		c, err := NewClient(opts)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return c.DiscoveryKey()
+	}
+
+	base := Options{Orgs: []string{"dev-sec", "acme"}, Repos: []string{"octocat/baseline"}}
+	if key(base) != key(Options{Orgs: []string{"dev-sec", "acme"}, Repos: []string{"octocat/baseline"}, Exclude: []string{"*/fork"}}) {
+		t.Error("key changed with an exclude pattern, which doesn't move positions")
+	}
+	for name, opts := range map[string]Options{
+		"page size":       {Orgs: base.Orgs, Repos: base.Repos, PageSize: 50},
+		"listing order":   {Orgs: []string{"acme", "dev-sec"}, Repos: base.Repos},
+		"added listing":   {Orgs: base.Orgs, Topics: []string{"inspec"}, Repos: base.Repos},
+		"repositories":    {Orgs: base.Orgs, Repos: []string{"octocat/other"}},
+		"search instead":  {SearchQuery: "inspec"},
+		"users, not orgs": {Users: base.Orgs, Repos: base.Repos},
+	} {
+		if key(opts) == key(base) {
+			t.Errorf("key unchanged by a different %s", name)
+		}
+	}
+}
@@ -1,102 +1,107 @@
 package github
 
 import (
+	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
+	"log"
 	"net/http"
-	"strings"
 	"time"
 
+	"github.com/ahasunos/caas/backend/internal/metadata"
 	"github.com/ahasunos/caas/backend/internal/models"
 )
 
-// Function to fetch profile details from GitHub
-func FetchProfileDetailsFromGitHub(repoURL string) (models.Profile, error) {
+// ErrRepositoryNotFound is returned when a repository doesn't exist, or is
+// no longer available, e.g. because it was deleted or blocked.
+var ErrRepositoryNotFound = errors.New("GitHub repository not found")
+
+// Function to fetch profile details from GitHub. Requests for renamed or
+// transferred repositories are redirected by GitHub, so the returned
+// profile carries the repository's current URL.
+func (c *Client) FetchProfileDetailsFromGitHub(ctx context.Context, repoURL string) (models.Profile, error) {
 	// Extract repository owner and name from URL
-	repoParts := strings.Split(repoURL, "/")
-	owner := repoParts[len(repoParts)-2]
-	repo := repoParts[len(repoParts)-1]
-	apiURL := fmt.Sprintf("https://api.github.com/repos/%s/%s", owner, repo)
+	owner, repo := splitRepoURL(repoURL)
+	url := fmt.Sprintf("%s/repos/%s/%s", c.apiURL, owner, repo)
 
 	// Fetch repository details from GitHub
-	resp, err := http.Get(apiURL)
+	resp, err := c.get(ctx, url, "application/vnd.github+json")
 	if err != nil {
-		return models.Profile{}, fmt.Errorf("failed to fetch repository details: %v", err)
+		return models.Profile{}, fmt.Errorf("failed to fetch repository details: %w", err)
 	}
-	defer resp.Body.Close()
 
 	// Check if the response status is OK
-	if resp.StatusCode != http.StatusOK {
-		return models.Profile{}, fmt.Errorf("GitHub repository not found or not accessible")
+	switch resp.status {
+	case http.StatusOK:
+	case http.StatusNotFound, http.StatusGone, http.StatusUnavailableForLegalReasons:
+		return models.Profile{}, fmt.Errorf("%w: status %d", ErrRepositoryNotFound, resp.status)
+	default:
+		return models.Profile{}, fmt.Errorf("GitHub repository not accessible: status %d", resp.status)
 	}
 
-	var repoDetails struct {
-		Name        string `json:"name"`
-		Description string `json:"description"`
-		HTMLURL     string `json:"html_url"`
-		Stargazers  int    `json:"stargazers_count"`
-	}
-	if err := json.NewDecoder(resp.Body).Decode(&repoDetails); err != nil {
+	var repoDetails models.GitHubRepo
+	if err := json.Unmarshal(resp.body, &repoDetails); err != nil {
 		return models.Profile{}, fmt.Errorf("failed to decode repository details: %v", err)
 	}
 
 	// Construct Profile object
-	return models.Profile{
-		Name:        repoDetails.Name,
-		URL:         repoDetails.HTMLURL,
-		Description: repoDetails.Description,
-		Stars:       repoDetails.Stargazers,
-		LastUpdated: time.Now(),
-	}, nil
+	profile := repoProfile(repoDetails)
+	profile.LastUpdated = time.Now()
+	return profile, nil
 }
 
-// Function to fetch profiles from GitHub API
-func FetchProfilesFromGitHub() ([]models.Profile, error) {
-	var allProfiles []models.Profile
-	page := 1
-	perPage := 10
-
-	for {
-		url := fmt.Sprintf("https://api.github.com/search/repositories?q=inspec+profile&sort=stars&per_page=%d&page=%d", perPage, page)
-		req, err := http.NewRequest("GET", url, nil)
-		if err != nil {
-			return nil, fmt.Errorf("failed to create request: %v", err)
-		}
-		req.Header.Set("User-Agent", "InSpecService")
-
-		resp, err := http.DefaultClient.Do(req)
-		if err != nil {
-			return nil, fmt.Errorf("failed to fetch profiles from GitHub: %v", err)
-		}
-		defer resp.Body.Close()
-
-		var result models.GitHubSearchResult
-		err = json.NewDecoder(resp.Body).Decode(&result)
-		if err != nil {
-			return nil, fmt.Errorf("failed to decode response from GitHub: %v", err)
-		}
-
-		// Exit if no more profiles are found
-		if len(result.Items) == 0 {
-			break
-		}
+// repoProfile returns the catalog profile of a repository.
+func repoProfile(repo models.GitHubRepo) models.Profile {
+	status := models.ProfileActive
+	if repo.Archived {
+		status = models.ProfileArchived
+	}
+	return models.Profile{
+		Name:        repo.Name,
+		URL:         repo.HTMLURL,
+		Description: repo.Description,
+		Stars:       repo.Stars,
+		RepoID:      repo.ID,
+		Owner:       repo.Owner.Login,
+		Status:      status,
+		Fork:        repo.Fork,
+		ParentURL:   parentURL(repo),
+	}
+}
 
-		// Loop through the items and add valid profiles that have inspec.yml
-		for _, repo := range result.Items {
-			// Check if inspec.yml exists in the repository's root
-			if repo.Description != "" && HasInSpecYML(repo.HTMLURL) {
-				allProfiles = append(allProfiles, models.Profile{
-					Name:        repo.Name,
-					URL:         repo.HTMLURL,
-					Description: repo.Description,
-					Stars:       repo.Stars,
-				})
-			}
-		}
+func parentURL(repo models.GitHubRepo) string {
+	if repo.Parent == nil {
+		return ""
+	}
+	return repo.Parent.HTMLURL
+}
 
-		// Move to the next page
-		page++
+// forkParent returns the URL of the repository a fork was forked from.
+// Search results and repository listings don't include it, so the fork is
+// fetched on its own.
+func (c *Client) forkParent(ctx context.Context, repo models.GitHubRepo) (string, error) {
+	var details models.GitHubRepo
+	owner, name := splitRepoURL(repo.HTMLURL)
+	if err := c.getJSON(ctx, fmt.Sprintf("%s/repos/%s/%s", c.apiURL, owner, name), &details); err != nil {
+		return "", err
 	}
+	return parentURL(details), nil
+}
 
-	return allProfiles, nil
+// FetchMetadata fetches and parses the inspec.yml file of a repository. It
+// returns ErrNoInSpecYML if the repository is not an InSpec profile. A file
+// that can't be parsed still marks a profile, so nil metadata is returned
+// for it without an error.
+func (c *Client) FetchMetadata(ctx context.Context, repoURL string) (*models.ProfileMetadata, error) {
+	data, err := c.FetchInSpecYML(ctx, repoURL)
+	if err != nil {
+		return nil, err
+	}
+	meta, err := metadata.Parse(data)
+	if err != nil {
+		log.Printf("Could not parse inspec.yml of %s: %v", repoURL, err)
+		return nil, nil
+	}
+	return &meta, nil
 }
@@ -1,44 +1,51 @@
 package github
 
 import (
+	"context"
+	"errors"
 	"fmt"
 	"log"
 	"net/http"
 	"strings"
 )
 
-// Function to check if the inspec.yml file exists in the repository's root
-// Function to check if the inspec.yml file exists in the repository's root
-func HasInSpecYML(repoURL string) bool {
-	// Construct the API URL to get the contents of the repo
+// ErrNoInSpecYML is returned when a repository has no inspec.yml file at its root.
+var ErrNoInSpecYML = errors.New("inspec.yml not found")
 
-	repoParts := strings.Split(repoURL, "/")
-	owner := repoParts[len(repoParts)-2]
-	repo := repoParts[len(repoParts)-1]
-	apiURL := fmt.Sprintf("https://api.github.com/repos/%s/%s/contents/inspec.yml", owner, repo)
+// Function to fetch the inspec.yml file from the repository's root
+func (c *Client) FetchInSpecYML(ctx context.Context, repoURL string) ([]byte, error) {
+	// Construct the API URL to get the contents of the repo
+	owner, repo := splitRepoURL(repoURL)
+	url := fmt.Sprintf("%s/repos/%s/%s/contents/inspec.yml", c.apiURL, owner, repo)
 
-	// Send GET request to check for inspec.yml file
-	resp, err := http.Get(apiURL)
+	// Ask for the file itself rather than its base64 encoded JSON description
+	resp, err := c.get(ctx, url, "application/vnd.github.raw+json")
 	if err != nil {
-		log.Printf("Error fetching %s: %v", apiURL, err)
-		return false
+		log.Printf("Error fetching %s: %v", url, err)
+		return nil, fmt.Errorf("failed to fetch inspec.yml: %w", err)
 	}
-	defer resp.Body.Close()
 
 	// Debugging log to check the status code and response
-	log.Printf("GitHub API response for %s: %v", apiURL, resp.StatusCode)
+	log.Printf("GitHub API response for %s: %v", url, resp.status)
 
-	// Check if the status code is 200 (OK) and the content is the expected 'inspec.yml' file
-	if resp.StatusCode == http.StatusOK {
+	switch resp.status {
+	case http.StatusOK:
 		log.Printf("inspec.yml found in repository %s", repoURL)
-		return true
+		return resp.body, nil
+	case http.StatusNotFound:
+		log.Printf("inspec.yml not found in repository %s", repoURL)
+		return nil, ErrNoInSpecYML
+	default:
+		log.Printf("Unexpected status code %d from GitHub API for %s", resp.status, url)
+		return nil, fmt.Errorf("unexpected status code %d from GitHub API", resp.status)
 	}
+}
 
-	// Handle other status codes (e.g., 404 if the file is not found)
-	if resp.StatusCode == http.StatusNotFound {
-		log.Printf("inspec.yml not found in repository %s", repoURL)
-	} else {
-		log.Printf("Unexpected status code %d from GitHub API for %s", resp.StatusCode, apiURL)
+// splitRepoURL returns the owner and name of the repository at repoURL.
+func splitRepoURL(repoURL string) (owner, repo string) {
+	repoParts := strings.Split(strings.TrimSuffix(repoURL, "/"), "/")
+	if len(repoParts) < 2 {
+		return "", repoURL
 	}
-	return false
+	return repoParts[len(repoParts)-2], repoParts[len(repoParts)-1]
 }
@@ -0,0 +1,35 @@
+// Package metadata reads the inspec.yml file that describes an InSpec profile.
+package metadata
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/ahasunos/caas/backend/internal/models"
+	"gopkg.in/yaml.v3"
+)
+
+// FileName is the name of the metadata file at the root of every profile.
+const FileName = "inspec.yml"
+
+// Parse decodes the content of an inspec.yml file. Attributes declared by
+// older profiles are returned as inputs.
+func Parse(data []byte) (models.ProfileMetadata, error) {
+	var meta models.ProfileMetadata
+	if err := yaml.Unmarshal(data, &meta); err != nil {
+		return models.ProfileMetadata{}, fmt.Errorf("failed to parse %s: %v", FileName, err)
+	}
+	meta.Inputs = append(meta.Inputs, meta.Attributes...)
+	meta.Attributes = nil
+	return meta, nil
+}
+
+// ReadDir reads and parses the inspec.yml file of the profile in dir.
+func ReadDir(dir string) (models.ProfileMetadata, error) {
+	data, err := os.ReadFile(filepath.Join(dir, FileName))
+	if err != nil {
+		return models.ProfileMetadata{}, fmt.Errorf("failed to read %s: %v", FileName, err)
+	}
+	return Parse(data)
+}
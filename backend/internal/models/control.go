@@ -0,0 +1,27 @@
+package models
+
+// Control is a control declared by a catalog profile, as read from the
+// profile's controls directory.
+type Control struct {
+	ProfileID   int    `json:"profile_id,omitempty"`
+	ProfileName string `json:"profile_name,omitempty"`
+	ID          string `json:"id"`
+	Title       string `json:"title,omitempty"`
+	Desc        string `json:"desc,omitempty"`
+	// Impact defaults to 0.5, as in InSpec, for controls that don't set it.
+	Impact float64                `json:"impact"`
+	Tags   map[string]interface{} `json:"tags,omitempty"`
+	Refs   []ControlRef           `json:"refs,omitempty"`
+	// File is the path of the file declaring the control, relative to the
+	// profile's root.
+	File string `json:"file,omitempty"`
+	// CommitSHA is the commit of the profile the control was read from.
+	CommitSHA string `json:"commit_sha,omitempty"`
+}
+
+// ControlRef is an external reference of a control, such as a benchmark
+// section or a documentation link.
+type ControlRef struct {
+	Ref string `json:"ref,omitempty"`
+	URL string `json:"url,omitempty"`
+}
@@ -0,0 +1,24 @@
+package models
+
+// RunDiff describes how control results changed between two runs.
+type RunDiff struct {
+	From         int             `json:"from"`
+	To           int             `json:"to"`
+	NewlyFailing []ControlChange `json:"newly_failing"`
+	NewlyPassing []ControlChange `json:"newly_passing"`
+	NewlySkipped []ControlChange `json:"newly_skipped"`
+	Added        []ControlChange `json:"added"`
+	Removed      []ControlChange `json:"removed"`
+	Unchanged    int             `json:"unchanged"`
+}
+
+// ControlChange is a control whose status differs between two runs. Before
+// is empty for added controls and After is empty for removed ones.
+type ControlChange struct {
+	Profile string  `json:"profile"`
+	ID      string  `json:"id"`
+	Title   string  `json:"title"`
+	Impact  float64 `json:"impact"`
+	Before  string  `json:"before,omitempty"`
+	After   string  `json:"after,omitempty"`
+}
@@ -0,0 +1,58 @@
+package models
+
+import "time"
+
+// Job statuses reported by the job status API.
+const (
+	JobQueued    = "queued"
+	JobRunning   = "running"
+	JobSucceeded = "succeeded"
+	JobFailed    = "failed"
+	JobTimedOut  = "timed_out"
+	JobCancelled = "cancelled"
+)
+
+// Job represents an asynchronous execution of an InSpec profile against a host.
+type Job struct {
+	ID       int    `json:"id"`
+	Status   string `json:"status"`
+	Hostname string `json:"hostname"`
+	Username string `json:"username"`
+	Profile  string `json:"profile"`
+	// Ref is the branch, tag or commit of the profile that was requested.
+	// CommitSHA is the commit it resolved to and ProfileVersion the version
+	// declared in the profile's inspec.yml at that commit.
+	Ref            string `json:"ref,omitempty"`
+	CommitSHA      string `json:"commit_sha,omitempty"`
+	ProfileVersion string `json:"profile_version,omitempty"`
+	// Engine is the scan engine (e.g. inspec or cinc-auditor) running the
+	// job and EngineVersion the version it reported.
+	Engine        string `json:"engine"`
+	EngineVersion string `json:"engine_version,omitempty"`
+	// TimeoutSeconds is how long the scan may run once started.
+	TimeoutSeconds int        `json:"timeout_seconds"`
+	ExitCode       *int       `json:"exit_code,omitempty"`
+	Output         string     `json:"output,omitempty"`
+	Error          string     `json:"error,omitempty"`
+	Result         *Run       `json:"result,omitempty"`
+	CreatedAt      time.Time  `json:"created_at"`
+	StartedAt      *time.Time `json:"started_at,omitempty"`
+	FinishedAt     *time.Time `json:"finished_at,omitempty"`
+
+	// Instance is the ID of the replica running the job. It is not
+	// returned by the API.
+	Instance string `json:"-"`
+
+	// QueuePosition is the 1-based position of a queued job in the
+	// execution queue. It is not persisted.
+	QueuePosition int `json:"queue_position,omitempty"`
+}
+
+// Finished reports whether the job has reached a terminal status.
+func (j Job) Finished() bool {
+	switch j.Status {
+	case JobQueued, JobRunning:
+		return false
+	}
+	return true
+}
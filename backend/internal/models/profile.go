@@ -2,25 +2,127 @@ package models
 
 import "time"
 
+// Profile statuses. Archived profiles are still available but no longer
+// maintained; removed profiles are gone from their source and kept only so
+// that past runs can be traced back to them.
+const (
+	ProfileActive   = "active"
+	ProfileArchived = "archived"
+	ProfileRemoved  = "removed"
+)
+
 // Profile represents an InSpec profile.
 type Profile struct {
-	ID          int       `json:"id"`
-	Name        string    `json:"name"`
-	URL         string    `json:"url"`
-	Description string    `json:"description"`
-	Stars       int       `json:"stars"`
-	LastUpdated time.Time `json:"last_updated"`
+	ID          int    `json:"id"`
+	Name        string `json:"name"`
+	URL         string `json:"url"`
+	Description string `json:"description"`
+	Stars       int    `json:"stars"`
+	// CommitSHA is the latest commit of the profile's repository seen by
+	// the catalog sync. Executions use the cached checkout of this commit.
+	CommitSHA string `json:"commit_sha,omitempty"`
+	// Metadata is parsed from the profile's inspec.yml during sync.
+	Metadata *ProfileMetadata `json:"metadata,omitempty"`
+	// RepoID is the ID of the profile's repository on its GitHub host,
+	// which stays the same when the repository is renamed or transferred.
+	RepoID int64 `json:"repo_id,omitempty"`
+	// Source is the name of the catalog source the profile was found on.
+	Source string `json:"source,omitempty"`
+	// Owner is the user, organization or group owning the profile's
+	// repository, as named by its source.
+	Owner string `json:"owner,omitempty"`
+	// Fork is set for profiles whose repository is a fork, and ParentURL
+	// is the URL of the repository it was forked from, if known.
+	Fork      bool   `json:"fork,omitempty"`
+	ParentURL string `json:"parent_url,omitempty"`
+	// Fingerprint is the SHA-256 hash of the profile's inspec.yml and
+	// control IDs, computed when its controls are indexed. Profiles with
+	// the same fingerprint are duplicates of each other.
+	Fingerprint string `json:"fingerprint,omitempty"`
+	// CanonicalID is the ID of the profile that this fork or duplicate is
+	// grouped under, and Duplicates counts the profiles grouped under this
+	// one.
+	CanonicalID int `json:"canonical_id,omitempty"`
+	Duplicates  int `json:"duplicates,omitempty"`
+	// Status is active, archived or removed. StatusReason explains why a
+	// profile was removed.
+	Status       string `json:"status"`
+	StatusReason string `json:"status_reason,omitempty"`
+	// ReviewStatus is pending, approved or blocked. Only approved profiles
+	// may be executed if the server requires it. Reviewer, ReviewNotes,
+	// ReviewedSHA and ReviewedAt describe the latest review; executions of
+	// an approved profile run the reviewed commit.
+	ReviewStatus string     `json:"review_status"`
+	Reviewer     string     `json:"reviewer,omitempty"`
+	ReviewNotes  string     `json:"review_notes,omitempty"`
+	ReviewedSHA  string     `json:"reviewed_sha,omitempty"`
+	ReviewedAt   *time.Time `json:"reviewed_at,omitempty"`
+	LastUpdated  time.Time  `json:"last_updated"`
 }
 
 // GitHubSearchResult struct to parse GitHub API search response
 type GitHubSearchResult struct {
-	Items []GitHubRepo `json:"items"`
+	TotalCount int          `json:"total_count"`
+	Items      []GitHubRepo `json:"items"`
 }
 
 // GitHubRepo struct represents a GitHub repository
 type GitHubRepo struct {
-	Name        string `json:"name"`
+	ID       int64  `json:"id"`
+	Name     string `json:"name"`
+	FullName string `json:"full_name"`
+	Owner    struct {
+		Login string `json:"login"`
+	} `json:"owner"`
 	HTMLURL     string `json:"html_url"`
 	Description string `json:"description"`
 	Stars       int    `json:"stargazers_count"`
+	Archived    bool   `json:"archived"`
+	Fork        bool   `json:"fork"`
+	// Parent is the repository a fork was forked from. It is only returned
+	// when a single repository is fetched.
+	Parent *GitHubRepo `json:"parent,omitempty"`
+}
+
+// ProfileMetadata holds the fields of a profile's inspec.yml file.
+type ProfileMetadata struct {
+	Name          string              `json:"name" yaml:"name"`
+	Title         string              `json:"title,omitempty" yaml:"title"`
+	Version       string              `json:"version,omitempty" yaml:"version"`
+	Maintainer    string              `json:"maintainer,omitempty" yaml:"maintainer"`
+	Copyright     string              `json:"copyright,omitempty" yaml:"copyright"`
+	License       string              `json:"license,omitempty" yaml:"license"`
+	Summary       string              `json:"summary,omitempty" yaml:"summary"`
+	Supports      []map[string]string `json:"supports,omitempty" yaml:"supports"`
+	InSpecVersion string              `json:"inspec_version,omitempty" yaml:"inspec_version"`
+	Inputs        []ProfileInput      `json:"inputs,omitempty" yaml:"inputs"`
+	Dependencies  []ProfileDependency `json:"dependencies,omitempty" yaml:"depends"`
+
+	// Attributes is the name older profiles use for inputs.
+	Attributes []ProfileInput `json:"-" yaml:"attributes"`
+}
+
+// ProfileInput is an input (formerly attribute) declared by a profile.
+type ProfileInput struct {
+	Name        string      `json:"name" yaml:"name"`
+	Description string      `json:"description,omitempty" yaml:"description"`
+	Type        string      `json:"type,omitempty" yaml:"type"`
+	Value       interface{} `json:"value,omitempty" yaml:"value"`
+	Required    bool        `json:"required,omitempty" yaml:"required"`
+	Sensitive   bool        `json:"sensitive,omitempty" yaml:"sensitive"`
+}
+
+// ProfileDependency is a profile another profile depends on. Only the
+// fields matching the dependency's source are set.
+type ProfileDependency struct {
+	Name        string `json:"name" yaml:"name"`
+	URL         string `json:"url,omitempty" yaml:"url"`
+	Git         string `json:"git,omitempty" yaml:"git"`
+	Branch      string `json:"branch,omitempty" yaml:"branch"`
+	Tag         string `json:"tag,omitempty" yaml:"tag"`
+	Commit      string `json:"commit,omitempty" yaml:"commit"`
+	Version     string `json:"version,omitempty" yaml:"version"`
+	Path        string `json:"path,omitempty" yaml:"path"`
+	Supermarket string `json:"supermarket,omitempty" yaml:"supermarket"`
+	Compliance  string `json:"compliance,omitempty" yaml:"compliance"`
 }
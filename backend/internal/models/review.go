@@ -0,0 +1,24 @@
+package models
+
+import "time"
+
+// Review statuses of catalog profiles. New profiles are pending until an
+// admin approves or blocks them.
+const (
+	ReviewPending  = "pending"
+	ReviewApproved = "approved"
+	ReviewBlocked  = "blocked"
+)
+
+// ProfileReview is a change of the review status of a catalog profile.
+type ProfileReview struct {
+	ID        int    `json:"id"`
+	ProfileID int    `json:"profile_id"`
+	Status    string `json:"status"`
+	Reviewer  string `json:"reviewer"`
+	Notes     string `json:"notes,omitempty"`
+	// CommitSHA is the commit of the profile's repository that was
+	// reviewed, if the profile comes from a git source.
+	CommitSHA string    `json:"commit_sha,omitempty"`
+	CreatedAt time.Time `json:"created_at"`
+}
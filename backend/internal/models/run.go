@@ -0,0 +1,89 @@
+package models
+
+import "time"
+
+// Control and test statuses reported by InSpec.
+const (
+	StatusPassed  = "passed"
+	StatusFailed  = "failed"
+	StatusSkipped = "skipped"
+)
+
+// Run is the structured result of an InSpec execution, decoded from the
+// output of InSpec's JSON reporter. The identifying fields at the top are
+// filled in once the run has been stored.
+type Run struct {
+	ID       int    `json:"id,omitempty"`
+	JobID    int    `json:"job_id,omitempty"`
+	Hostname string `json:"hostname,omitempty"`
+	Profile  string `json:"profile,omitempty"`
+	// CommitSHA and ProfileVersion record the exact revision of the profile
+	// that was executed.
+	CommitSHA      string `json:"commit_sha,omitempty"`
+	ProfileVersion string `json:"profile_version,omitempty"`
+	// Engine and EngineVersion identify the scan engine that produced the
+	// run; Version below is the version written into the report itself.
+	Engine        string    `json:"engine,omitempty"`
+	EngineVersion string    `json:"engine_version,omitempty"`
+	CreatedAt     time.Time `json:"created_at"`
+
+	Version    string          `json:"version"`
+	Platform   Platform        `json:"platform"`
+	Profiles   []ProfileResult `json:"profiles,omitempty"`
+	Statistics Statistics      `json:"statistics"`
+	Summary    RunSummary      `json:"summary"`
+}
+
+// Platform describes the target an InSpec run was executed against.
+type Platform struct {
+	Name     string `json:"name"`
+	Release  string `json:"release"`
+	TargetID string `json:"target_id,omitempty"`
+}
+
+// Statistics holds the timing information reported by InSpec.
+type Statistics struct {
+	Duration float64 `json:"duration"`
+}
+
+// RunSummary counts controls by status across all profiles of a run.
+// Controls with a test that errored count as failed.
+type RunSummary struct {
+	Total   int `json:"total"`
+	Passed  int `json:"passed"`
+	Failed  int `json:"failed"`
+	Skipped int `json:"skipped"`
+}
+
+// ProfileResult holds the results of one profile (or dependency) of a run.
+type ProfileResult struct {
+	Name          string          `json:"name"`
+	Title         string          `json:"title"`
+	Version       string          `json:"version"`
+	SHA256        string          `json:"sha256"`
+	Status        string          `json:"status"`
+	StatusMessage string          `json:"status_message,omitempty"`
+	Controls      []ControlResult `json:"controls"`
+}
+
+// ControlResult holds the outcome of a single control. Status is derived
+// from the control's test results.
+type ControlResult struct {
+	ID      string                 `json:"id"`
+	Title   string                 `json:"title"`
+	Desc    string                 `json:"desc"`
+	Impact  float64                `json:"impact"`
+	Tags    map[string]interface{} `json:"tags,omitempty"`
+	Status  string                 `json:"status"`
+	Results []TestResult           `json:"results"`
+}
+
+// TestResult is the outcome of one `describe` block within a control.
+type TestResult struct {
+	Status      string  `json:"status"`
+	CodeDesc    string  `json:"code_desc"`
+	Message     string  `json:"message,omitempty"`
+	SkipMessage string  `json:"skip_message,omitempty"`
+	RunTime     float64 `json:"run_time"`
+	StartTime   string  `json:"start_time,omitempty"`
+}
@@ -0,0 +1,43 @@
+package models
+
+import "time"
+
+// Sync run statuses reported by the sync status API.
+const (
+	SyncRunning   = "running"
+	SyncSucceeded = "succeeded"
+	SyncFailed    = "failed"
+)
+
+// Sync run triggers.
+const (
+	SyncTriggerManual    = "manual"
+	SyncTriggerScheduled = "scheduled"
+)
+
+// SyncRun is a run of the catalog sync, which discovers profiles on the
+// configured sources and updates the catalog.
+type SyncRun struct {
+	ID      int    `json:"id"`
+	Status  string `json:"status"`
+	Trigger string `json:"trigger"`
+	// Sources names the catalog sources synced; all sources are synced if
+	// it is empty.
+	Sources []string `json:"sources,omitempty"`
+	// Pages, Repositories and Duplicates count the search result pages
+	// fetched, the distinct repositories on them and the repositories seen
+	// twice. Excluded counts the repositories skipped by an exclude pattern
+	// and Discovered the repositories that are profiles.
+	Pages        int `json:"pages"`
+	Repositories int `json:"repositories"`
+	Duplicates   int `json:"duplicates"`
+	Excluded     int `json:"excluded"`
+	Discovered   int `json:"discovered"`
+	// Added, Updated and Removed count the changes to the catalog.
+	Added      int        `json:"added"`
+	Updated    int        `json:"updated"`
+	Removed    int        `json:"removed"`
+	Error      string     `json:"error,omitempty"`
+	StartedAt  time.Time  `json:"started_at"`
+	FinishedAt *time.Time `json:"finished_at,omitempty"`
+}
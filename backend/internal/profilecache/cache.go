@@ -0,0 +1,370 @@
+// Package profilecache keeps local checkouts of profile repositories, pinned
+// to a commit, so that executions don't download a profile on every run.
+// Checkouts are evicted least recently used first once the cache grows past
+// its size limit.
+package profilecache
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"fmt"
+	"io/fs"
+	"log"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// shaPattern matches a full git commit SHA.
+var shaPattern = regexp.MustCompile(`^[0-9a-f]{40}$`)
+
+// entry is one cached checkout of a repository at a commit.
+type entry struct {
+	path     string
+	size     int64
+	lastUsed time.Time
+	// users counts the executions currently reading the checkout; entries
+	// in use are never evicted.
+	users int
+}
+
+// Credentials returns the username and token to access the repository at
+// repoURL with, or an empty token for repositories that need no
+// authentication. An empty username stands for x-access-token, which
+// GitHub and GitLab accept for any token.
+type Credentials func(ctx context.Context, repoURL string) (username, token string, err error)
+
+// Cache is a size-bounded store of profile checkouts on local disk, laid
+// out as <dir>/<hash of repository URL>/<commit SHA>.
+type Cache struct {
+	dir      string
+	maxBytes int64
+	creds    Credentials
+
+	mu       sync.Mutex
+	entries  map[string]*entry        // relative path -> entry
+	fetching map[string]chan struct{} // relative path -> closed when fetched
+}
+
+// New returns a cache rooted at dir holding at most maxBytes of checkouts.
+// Checkouts left by a previous process are picked up again.
+func New(dir string, maxBytes int64) (*Cache, error) {
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		return nil, fmt.Errorf("failed to create profile cache: %v", err)
+	}
+
+	c := &Cache{
+		dir:      dir,
+		maxBytes: maxBytes,
+		entries:  make(map[string]*entry),
+		fetching: make(map[string]chan struct{}),
+	}
+
+	checkouts, err := filepath.Glob(filepath.Join(dir, "*", "*"))
+	if err != nil {
+		return nil, err
+	}
+	for _, path := range checkouts {
+		key, _ := filepath.Rel(dir, path)
+		if !shaPattern.MatchString(filepath.Base(key)) {
+			// Leftover of an interrupted fetch
+			os.RemoveAll(path)
+			continue
+		}
+		info, err := os.Stat(path)
+		if err != nil {
+			continue
+		}
+		c.entries[key] = &entry{path: path, size: dirSize(path), lastUsed: info.ModTime()}
+	}
+	c.evict()
+
+	return c, nil
+}
+
+// SetCredentials makes the cache authenticate to repositories with the
+// tokens creds returns. It must be called before the cache is used.
+func (c *Cache) SetCredentials(creds Credentials) {
+	c.creds = creds
+}
+
+// ResolveRef returns the commit SHA that ref (a branch, tag or commit SHA)
+// points to in the repository at repoURL. An empty ref resolves the
+// repository's default branch.
+func (c *Cache) ResolveRef(ctx context.Context, repoURL, ref string) (string, error) {
+	if err := checkArgs(repoURL, ref); err != nil {
+		return "", err
+	}
+	if shaPattern.MatchString(ref) {
+		return ref, nil
+	}
+	if ref == "" {
+		ref = "HEAD"
+	}
+
+	output, err := c.git(ctx, "", repoURL, "ls-remote", "--", repoURL, ref, ref+"^{}")
+	if err != nil {
+		return "", fmt.Errorf("failed to resolve %s of %s: %v", ref, repoURL, err)
+	}
+
+	// ls-remote matches patterns against the end of ref names, so pick the
+	// exact match, preferring tags over branches like git does and the
+	// commit an annotated tag points to over the tag object itself
+	shas := make(map[string]string)
+	for _, line := range strings.Split(strings.TrimSpace(output), "\n") {
+		if fields := strings.Fields(line); len(fields) == 2 {
+			shas[fields[1]] = fields[0]
+		}
+	}
+	for _, name := range []string{"refs/tags/" + ref + "^{}", "refs/tags/" + ref, "refs/heads/" + ref, ref} {
+		if sha, ok := shas[name]; ok {
+			return sha, nil
+		}
+	}
+	return "", fmt.Errorf("ref %s not found in %s", ref, repoURL)
+}
+
+// checkArgs returns an error if git would take repoURL or ref for an
+// option, such as --upload-pack, rather than a repository or ref.
+func checkArgs(repoURL, ref string) error {
+	if strings.HasPrefix(repoURL, "-") {
+		return fmt.Errorf("invalid repository URL %q", repoURL)
+	}
+	if strings.HasPrefix(ref, "-") {
+		return fmt.Errorf("invalid ref %q", ref)
+	}
+	return nil
+}
+
+// Get returns the path of a checkout of repoURL at commit sha, fetching it
+// if it isn't cached yet. The checkout is protected from eviction until
+// release is called.
+func (c *Cache) Get(ctx context.Context, repoURL, sha string) (path string, release func(), err error) {
+	if !shaPattern.MatchString(sha) {
+		return "", nil, fmt.Errorf("invalid commit SHA %q", sha)
+	}
+	if err := checkArgs(repoURL, sha); err != nil {
+		return "", nil, err
+	}
+	key := filepath.Join(repoKey(repoURL), sha)
+
+	for {
+		c.mu.Lock()
+		if e, ok := c.entries[key]; ok {
+			e.users++
+			e.lastUsed = time.Now()
+			c.mu.Unlock()
+			os.Chtimes(e.path, e.lastUsed, e.lastUsed)
+			return e.path, c.releaser(key), nil
+		}
+		if done, ok := c.fetching[key]; ok {
+			// Someone else is fetching the same checkout; wait for them
+			c.mu.Unlock()
+			select {
+			case <-done:
+				continue
+			case <-ctx.Done():
+				return "", nil, ctx.Err()
+			}
+		}
+		done := make(chan struct{})
+		c.fetching[key] = done
+		c.mu.Unlock()
+
+		path, size, err := c.fetch(ctx, repoURL, sha, key)
+
+		c.mu.Lock()
+		delete(c.fetching, key)
+		close(done)
+		if err != nil {
+			c.mu.Unlock()
+			return "", nil, err
+		}
+		c.entries[key] = &entry{path: path, size: size, lastUsed: time.Now(), users: 1}
+		c.evict()
+		c.mu.Unlock()
+
+		return path, c.releaser(key), nil
+	}
+}
+
+// Has reports whether any commit of repoURL is cached.
+func (c *Cache) Has(repoURL string) bool {
+	prefix := repoKey(repoURL) + string(filepath.Separator)
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	for key := range c.entries {
+		if strings.HasPrefix(key, prefix) {
+			return true
+		}
+	}
+	return false
+}
+
+// Refresh fetches the given commit of repoURL into the cache if an older
+// checkout of the repository is already cached, so that the next execution
+// of a profile in use doesn't have to wait for the download.
+func (c *Cache) Refresh(ctx context.Context, repoURL, sha string) error {
+	if !c.Has(repoURL) {
+		return nil
+	}
+	_, release, err := c.Get(ctx, repoURL, sha)
+	if err != nil {
+		return err
+	}
+	release()
+	return nil
+}
+
+// fetch downloads a shallow checkout of repoURL at sha into the cache,
+// returning its path and size on disk.
+func (c *Cache) fetch(ctx context.Context, repoURL, sha, key string) (string, int64, error) {
+	path := filepath.Join(c.dir, key)
+	if err := os.MkdirAll(filepath.Dir(path), 0700); err != nil {
+		return "", 0, fmt.Errorf("failed to create profile cache directory: %v", err)
+	}
+
+	// Fetch into a temporary directory so that an interrupted fetch never
+	// looks like a complete checkout
+	tmp, err := os.MkdirTemp(filepath.Dir(path), "fetch-")
+	if err != nil {
+		return "", 0, fmt.Errorf("failed to create profile cache directory: %v", err)
+	}
+	defer os.RemoveAll(tmp)
+
+	log.Printf("Fetching profile %s at %s into the cache", repoURL, sha)
+	for _, args := range [][]string{
+		{"init", "--quiet"},
+		{"fetch", "--quiet", "--depth", "1", "--", repoURL, sha},
+		{"checkout", "--quiet", "FETCH_HEAD"},
+	} {
+		if _, err := c.git(ctx, tmp, repoURL, args...); err != nil {
+			return "", 0, fmt.Errorf("failed to fetch %s at %s: %v", repoURL, sha, err)
+		}
+	}
+	// InSpec doesn't need the history, and without it the checkout is smaller
+	if err := os.RemoveAll(filepath.Join(tmp, ".git")); err != nil {
+		return "", 0, fmt.Errorf("failed to clean up checkout: %v", err)
+	}
+
+	if err := os.Rename(tmp, path); err != nil {
+		return "", 0, fmt.Errorf("failed to store checkout: %v", err)
+	}
+	return path, dirSize(path), nil
+}
+
+// releaser returns a function that marks the entry as no longer in use.
+func (c *Cache) releaser(key string) func() {
+	var once sync.Once
+	return func() {
+		once.Do(func() {
+			c.mu.Lock()
+			defer c.mu.Unlock()
+
+			if e, ok := c.entries[key]; ok {
+				e.users--
+			}
+			c.evict()
+		})
+	}
+}
+
+// evict removes least recently used checkouts that aren't in use until the
+// cache fits in its size limit. The caller must hold c.mu.
+func (c *Cache) evict() {
+	var total int64
+	keys := make([]string, 0, len(c.entries))
+	for key, e := range c.entries {
+		total += e.size
+		keys = append(keys, key)
+	}
+	if total <= c.maxBytes {
+		return
+	}
+
+	sort.Slice(keys, func(i, j int) bool {
+		return c.entries[keys[i]].lastUsed.Before(c.entries[keys[j]].lastUsed)
+	})
+	for _, key := range keys {
+		if total <= c.maxBytes {
+			break
+		}
+		e := c.entries[key]
+		if e.users > 0 {
+			continue
+		}
+		if err := os.RemoveAll(e.path); err != nil {
+			log.Printf("Failed to evict %s from the profile cache: %v", e.path, err)
+			continue
+		}
+		delete(c.entries, key)
+		total -= e.size
+	}
+}
+
+// repoKey returns the directory name used for a repository URL.
+func repoKey(repoURL string) string {
+	sum := sha256.Sum256([]byte(strings.TrimSuffix(strings.TrimSuffix(repoURL, "/"), ".git")))
+	return hex.EncodeToString(sum[:8])
+}
+
+// dirSize returns the total size of the files below path.
+func dirSize(path string) int64 {
+	var size int64
+	filepath.WalkDir(path, func(_ string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return nil
+		}
+		if info, err := d.Info(); err == nil && !d.IsDir() {
+			size += info.Size()
+		}
+		return nil
+	})
+	return size
+}
+
+// git runs a git command accessing repoURL in dir and returns its standard
+// output.
+func (c *Cache) git(ctx context.Context, dir, repoURL string, args ...string) (string, error) {
+	cmd := exec.CommandContext(ctx, "git", args...)
+	cmd.Dir = dir
+	// Never hang waiting for credentials on a private or missing repository
+	cmd.Env = append(os.Environ(), "GIT_TERMINAL_PROMPT=0")
+
+	if c.creds != nil {
+		username, token, err := c.creds(ctx, repoURL)
+		if err != nil {
+			return "", fmt.Errorf("failed to get credentials: %v", err)
+		}
+		if token != "" {
+			if username == "" {
+				username = "x-access-token"
+			}
+			// Pass the token through the environment rather than the
+			// command line, where other users could see it
+			auth := base64.StdEncoding.EncodeToString([]byte(username + ":" + token))
+			cmd.Env = append(cmd.Env,
+				"GIT_CONFIG_COUNT=1",
+				"GIT_CONFIG_KEY_0=http.extraHeader",
+				"GIT_CONFIG_VALUE_0=Authorization: Basic "+auth)
+		}
+	}
+
+	output, err := cmd.Output()
+	if err != nil {
+		if exitErr, ok := err.(*exec.ExitError); ok && len(exitErr.Stderr) > 0 {
+			return "", fmt.Errorf("%v: %s", err, strings.TrimSpace(string(exitErr.Stderr)))
+		}
+		return "", err
+	}
+	return string(output), nil
+}
@@ -0,0 +1,92 @@
+package profilecache
+
+import (
+	"context"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// testRepo creates a git repository with one commit on its main branch,
+// tagged v1, and returns its file:// URL and the commit's SHA.
+func testRepo(t *testing.T) (string, string) {
+	t.Helper()
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skip("git is not installed")
+	}
+	dir := t.TempDir()
+	run := func(args ...string) string {
+		cmd := exec.Command("git", args...)
+		cmd.Dir = dir
+		cmd.Env = append(os.Environ(), "GIT_AUTHOR_NAME=test", "GIT_AUTHOR_EMAIL=test@example.com",
+			"GIT_COMMITTER_NAME=test", "GIT_COMMITTER_EMAIL=test@example.com")
+		output, err := cmd.Output()
+		if err != nil {
+			t.Fatalf("git %s: %v", strings.Join(args, " "), err)
+		}
+		return strings.TrimSpace(string(output))
+	}
+	run("init", "--quiet", "--initial-branch", "main")
+	if err := os.WriteFile(filepath.Join(dir, "inspec.yml"), []byte("name: test\nversion: 1.0.0\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	run("add", "inspec.yml")
+	run("commit", "--quiet", "-m", "Add profile")
+	run("tag", "v1")
+	return "file://" + dir, run("rev-parse", "HEAD")
+}
+
+func TestResolveRefAndGet(t *testing.T) {
+	repoURL, sha := testRepo(t)
+	c, err := New(t.TempDir(), 1<<20)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, ref := range []string{"", "main", "v1", sha} {
+		got, err := c.ResolveRef(context.Background(), repoURL, ref)
+		if err != nil {
+			t.Fatalf("ResolveRef(%q): %v", ref, err)
+		}
+		if got != sha {
+			t.Errorf("ResolveRef(%q) = %s, want %s", ref, got, sha)
+		}
+	}
+
+	path, release, err := c.Get(context.Background(), repoURL, sha)
+	if err != nil {
+		t.Fatalf("Get: %v", err)
+	}
+	defer release()
+	if _, err := os.Stat(filepath.Join(path, "inspec.yml")); err != nil {
+		t.Errorf("checkout has no inspec.yml: %v", err)
+	}
+}
+
+func TestRejectsOptions(t *testing.T) {
+	marker := filepath.Join(t.TempDir(), "pwned")
+	c, err := New(t.TempDir(), 1<<20)
+	if err != nil {
+		t.Fatal(err)
+	}
+	sha := strings.Repeat("a", 40)
+
+	tests := []struct{ repoURL, ref string }{
+		{"--upload-pack=touch " + marker, ""},
+		{"-u", "main"},
+		{"https://github.com/dev-sec/linux-baseline", "--upload-pack=touch " + marker},
+	}
+	for _, tt := range tests {
+		if _, err := c.ResolveRef(context.Background(), tt.repoURL, tt.ref); err == nil || !strings.Contains(err.Error(), "invalid") {
+			t.Errorf("ResolveRef(%q, %q) error = %v, want it rejected", tt.repoURL, tt.ref, err)
+		}
+	}
+	if _, _, err := c.Get(context.Background(), "--upload-pack=touch "+marker, sha); err == nil {
+		t.Error("Get accepted an option as the repository")
+	}
+	if _, err := os.Stat(marker); err == nil {
+		t.Error("git ran the command given as the repository")
+	}
+}
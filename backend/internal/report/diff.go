@@ -0,0 +1,98 @@
+package report
+
+import (
+	"sort"
+
+	"github.com/ahasunos/caas/backend/internal/models"
+)
+
+// controlKey identifies a control across runs.
+type controlKey struct {
+	profile string
+	id      string
+}
+
+// Diff compares the control results of two runs, reporting the changes
+// needed to go from the first run to the second. Within each category the
+// controls with the highest impact come first.
+func Diff(from, to models.Run) models.RunDiff {
+	diff := models.RunDiff{
+		From:         from.ID,
+		To:           to.ID,
+		NewlyFailing: []models.ControlChange{},
+		NewlyPassing: []models.ControlChange{},
+		NewlySkipped: []models.ControlChange{},
+		Added:        []models.ControlChange{},
+		Removed:      []models.ControlChange{},
+	}
+
+	before := indexControls(from)
+	after := indexControls(to)
+
+	for key, a := range after {
+		change := models.ControlChange{
+			Profile: key.profile,
+			ID:      a.ID,
+			Title:   a.Title,
+			Impact:  a.Impact,
+			After:   a.Status,
+		}
+
+		b, ok := before[key]
+		if !ok {
+			diff.Added = append(diff.Added, change)
+			continue
+		}
+		change.Before = b.Status
+
+		switch {
+		case b.Status == a.Status:
+			diff.Unchanged++
+		case a.Status == models.StatusFailed:
+			diff.NewlyFailing = append(diff.NewlyFailing, change)
+		case a.Status == models.StatusPassed:
+			diff.NewlyPassing = append(diff.NewlyPassing, change)
+		default:
+			diff.NewlySkipped = append(diff.NewlySkipped, change)
+		}
+	}
+
+	for key, b := range before {
+		if _, ok := after[key]; !ok {
+			diff.Removed = append(diff.Removed, models.ControlChange{
+				Profile: key.profile,
+				ID:      b.ID,
+				Title:   b.Title,
+				Impact:  b.Impact,
+				Before:  b.Status,
+			})
+		}
+	}
+
+	for _, changes := range [][]models.ControlChange{diff.NewlyFailing, diff.NewlyPassing, diff.NewlySkipped, diff.Added, diff.Removed} {
+		sortChanges(changes)
+	}
+	return diff
+}
+
+func indexControls(run models.Run) map[controlKey]models.ControlResult {
+	controls := make(map[controlKey]models.ControlResult)
+	for _, p := range run.Profiles {
+		for _, c := range p.Controls {
+			controls[controlKey{p.Name, c.ID}] = c
+		}
+	}
+	return controls
+}
+
+func sortChanges(changes []models.ControlChange) {
+	sort.Slice(changes, func(i, j int) bool {
+		if changes[i].Impact != changes[j].Impact {
+			return changes[i].Impact > changes[j].Impact
+		}
+		if changes[i].Profile != changes[j].Profile {
+			return changes[i].Profile < changes[j].Profile
+		}
+		return changes[i].ID < changes[j].ID
+	})
+}
@@ -0,0 +1,128 @@
+package report
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/ahasunos/caas/backend/internal/models"
+)
+
+// run builds a run of one profile whose controls have the given statuses
+// and impacts.
+func run(id int, profile string, controls ...models.ControlResult) models.Run {
+	return models.Run{ID: id, Profiles: []models.ProfileResult{{Name: profile, Controls: controls}}}
+}
+
+func control(id, status string, impact float64) models.ControlResult {
+	return models.ControlResult{ID: id, Title: id + " title", Status: status, Impact: impact}
+}
+
+func ids(changes []models.ControlChange) []string {
+	list := []string{}
+	for _, c := range changes {
+		list = append(list, c.ID)
+	}
+	return list
+}
+
+func TestDiff(t *testing.T) {
+	from := run(1, "linux",
+		control("unchanged", models.StatusPassed, 1),
+		control("breaks", models.StatusPassed, 0.5),
+		control("breaks-critical", models.StatusSkipped, 1),
+		control("fixed", models.StatusFailed, 0.7),
+		control("skipped", models.StatusFailed, 0.3),
+		control("gone", models.StatusPassed, 0.1),
+	)
+	to := run(2, "linux",
+		control("unchanged", models.StatusPassed, 1),
+		control("breaks", models.StatusFailed, 0.5),
+		control("breaks-critical", models.StatusFailed, 1),
+		control("fixed", models.StatusPassed, 0.7),
+		control("skipped", models.StatusSkipped, 0.3),
+		control("new", models.StatusFailed, 0.9),
+	)
+
+	diff := Diff(from, to)
+	if diff.From != 1 || diff.To != 2 {
+		t.Errorf("diff is from %d to %d, want from 1 to 2", diff.From, diff.To)
+	}
+	for name, got := range map[string][]models.ControlChange{
+		"newly failing": diff.NewlyFailing,
+		"newly passing": diff.NewlyPassing,
+		"newly skipped": diff.NewlySkipped,
+		"added":         diff.Added,
+		"removed":       diff.Removed,
+	} {
+		want := map[string][]string{
+			"newly failing": {"breaks-critical", "breaks"},
+			"newly passing": {"fixed"},
+			"newly skipped": {"skipped"},
+			"added":         {"new"},
+			"removed":       {"gone"},
+		}[name]
+		if !reflect.DeepEqual(ids(got), want) {
+			t.Errorf("%s = %v, want %v", name, ids(got), want)
+		}
+	}
+	if diff.Unchanged != 1 {
+		t.Errorf("unchanged = %d, want 1", diff.Unchanged)
+	}
+
+	breaks := diff.NewlyFailing[1]
+	want := models.ControlChange{Profile: "linux", ID: "breaks", Title: "breaks title", Impact: 0.5, Before: models.StatusPassed, After: models.StatusFailed}
+	if breaks != want {
+		t.Errorf("change = %+v, want %+v", breaks, want)
+	}
+	if added := diff.Added[0]; added.Before != "" || added.After != models.StatusFailed {
+		t.Errorf("added change = %+v, want only an after status", added)
+	}
+	if removed := diff.Removed[0]; removed.Before != models.StatusPassed || removed.After != "" {
+		t.Errorf("removed change = %+v, want only a before status", removed)
+	}
+}
+
+func TestDiffKeysControlsByProfile(t *testing.T) {
+	from := models.Run{Profiles: []models.ProfileResult{
+		{Name: "a", Controls: []models.ControlResult{control("shared", models.StatusPassed, 1)}},
+		{Name: "b", Controls: []models.ControlResult{control("shared", models.StatusPassed, 1)}},
+	}}
+	to := models.Run{Profiles: []models.ProfileResult{
+		{Name: "a", Controls: []models.ControlResult{control("shared", models.StatusPassed, 1)}},
+		{Name: "b", Controls: []models.ControlResult{control("shared", models.StatusFailed, 1)}},
+	}}
+
+	diff := Diff(from, to)
+	if len(diff.NewlyFailing) != 1 || diff.NewlyFailing[0].Profile != "b" {
+		t.Errorf("newly failing = %+v, want shared of profile b", diff.NewlyFailing)
+	}
+	if diff.Unchanged != 1 {
+		t.Errorf("unchanged = %d, want 1", diff.Unchanged)
+	}
+}
+
+func TestDiffSortsByImpactProfileAndID(t *testing.T) {
+	to := models.Run{Profiles: []models.ProfileResult{
+		{Name: "b", Controls: []models.ControlResult{control("z", models.StatusFailed, 0.5), control("a", models.StatusFailed, 0.5)}},
+		{Name: "a", Controls: []models.ControlResult{control("m", models.StatusFailed, 0.5), control("low", models.StatusFailed, 0.1), control("high", models.StatusFailed, 1)}},
+	}}
+
+	got := []string{}
+	for _, c := range Diff(models.Run{}, to).Added {
+		got = append(got, c.Profile+"/"+c.ID)
+	}
+	want := []string{"a/high", "a/m", "b/a", "b/z", "a/low"}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("added = %v, want %v", got, want)
+	}
+}
+
+func TestDiffEmpty(t *testing.T) {
+	diff := Diff(models.Run{}, models.Run{})
+	// Empty categories are encoded as [] rather than null
+	for _, changes := range [][]models.ControlChange{diff.NewlyFailing, diff.NewlyPassing, diff.NewlySkipped, diff.Added, diff.Removed} {
+		if changes == nil || len(changes) != 0 {
+			t.Errorf("changes = %#v, want an empty slice", changes)
+		}
+	}
+}
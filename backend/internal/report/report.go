@@ -0,0 +1,55 @@
+// Package report decodes the output of InSpec's JSON reporter.
+package report
+
+import (
+	"encoding/json"
+	"fmt"
+
+	"github.com/ahasunos/caas/backend/internal/models"
+)
+
+// Parse decodes an InSpec JSON report, derives the status of every control
+// from its test results and fills in the run summary.
+func Parse(data []byte) (*models.Run, error) {
+	var run models.Run
+	if err := json.Unmarshal(data, &run); err != nil {
+		return nil, fmt.Errorf("failed to decode InSpec report: %v", err)
+	}
+
+	run.Summary = models.RunSummary{}
+	for i := range run.Profiles {
+		controls := run.Profiles[i].Controls
+		for j := range controls {
+			controls[j].Status = ControlStatus(controls[j].Results)
+			run.Summary.Total++
+			switch controls[j].Status {
+			case models.StatusPassed:
+				run.Summary.Passed++
+			case models.StatusFailed:
+				run.Summary.Failed++
+			default:
+				run.Summary.Skipped++
+			}
+		}
+	}
+
+	return &run, nil
+}
+
+// ControlStatus derives a control's status from its test results: failed if
+// any test failed, passed if at least one test passed, skipped otherwise.
+// Tests that errored, or have a status InSpec doesn't document, count as
+// failed so that a control that crashed isn't hidden among the skipped.
+func ControlStatus(results []models.TestResult) string {
+	status := models.StatusSkipped
+	for _, r := range results {
+		switch r.Status {
+		case models.StatusPassed:
+			status = models.StatusPassed
+		case models.StatusSkipped:
+		default:
+			return models.StatusFailed
+		}
+	}
+	return status
+}
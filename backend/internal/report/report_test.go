@@ -0,0 +1,97 @@
+package report
+
+import (
+	"os"
+	"testing"
+
+	"github.com/ahasunos/caas/backend/internal/models"
+)
+
+func TestParse(t *testing.T) {
+	data, err := os.ReadFile("testdata/report.json")
+	if err != nil {
+		t.Fatal(err)
+	}
+	run, err := Parse(data)
+	if err != nil {
+		t.Fatalf("Parse: %v", err)
+	}
+
+	if run.Version != "5.22.3" || run.Platform.Name != "ubuntu" || run.Platform.TargetID != "web-1" {
+		t.Errorf("run = %+v, want version 5.22.3 on ubuntu web-1", run)
+	}
+	if len(run.Profiles) != 2 {
+		t.Fatalf("got %d profiles, want 2", len(run.Profiles))
+	}
+
+	want := map[string]string{
+		"os-01":   models.StatusPassed,
+		"os-02":   models.StatusFailed,
+		"os-03":   models.StatusSkipped,
+		"os-04":   models.StatusSkipped,
+		"sshd-01": models.StatusPassed,
+	}
+	for _, p := range run.Profiles {
+		for _, c := range p.Controls {
+			if c.Status != want[c.ID] {
+				t.Errorf("status of %s = %q, want %q", c.ID, c.Status, want[c.ID])
+			}
+		}
+	}
+
+	wantSummary := models.RunSummary{Total: 5, Passed: 2, Failed: 1, Skipped: 2}
+	if run.Summary != wantSummary {
+		t.Errorf("summary = %+v, want %+v", run.Summary, wantSummary)
+	}
+}
+
+func TestParseOverridesReportedStatus(t *testing.T) {
+	run, err := Parse([]byte(`{"profiles": [{"name": "p", "controls": [
+		{"id": "c", "status": "passed", "results": [{"status": "failed"}]}
+	]}], "summary": {"total": 99}}`))
+	if err != nil {
+		t.Fatalf("Parse: %v", err)
+	}
+	if got := run.Profiles[0].Controls[0].Status; got != models.StatusFailed {
+		t.Errorf("status = %q, want failed", got)
+	}
+	if want := (models.RunSummary{Total: 1, Failed: 1}); run.Summary != want {
+		t.Errorf("summary = %+v, want %+v", run.Summary, want)
+	}
+}
+
+func TestParseInvalid(t *testing.T) {
+	for _, data := range []string{"", "not json", `{"profiles": "nope"}`} {
+		if run, err := Parse([]byte(data)); err == nil {
+			t.Errorf("Parse(%q) = %+v, want error", data, run)
+		}
+	}
+}
+
+func TestControlStatus(t *testing.T) {
+	tests := []struct {
+		name    string
+		results []string
+		want    string
+	}{
+		{"no results", nil, models.StatusSkipped},
+		{"all passed", []string{"passed", "passed"}, models.StatusPassed},
+		{"one failed", []string{"passed", "failed", "passed"}, models.StatusFailed},
+		{"failed before skipped", []string{"failed", "skipped"}, models.StatusFailed},
+		{"passed and skipped", []string{"skipped", "passed"}, models.StatusPassed},
+		{"all skipped", []string{"skipped", "skipped"}, models.StatusSkipped},
+		{"error", []string{"passed", "error"}, models.StatusFailed},
+		{"unknown status", []string{"skipped", "crashed"}, models.StatusFailed},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var results []models.TestResult
+			for _, s := range tt.results {
+				results = append(results, models.TestResult{Status: s})
+			}
+			if got := ControlStatus(results); got != tt.want {
+				t.Errorf("ControlStatus(%v) = %q, want %q", tt.results, got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,133 @@
+// Package scheduler runs InSpec executions with a bounded level of
+// concurrency while making sure a single target is never scanned twice at
+// the same time.
+package scheduler
+
+import (
+	"strings"
+	"sync"
+)
+
+// Task is a unit of work run against a target host.
+type Task struct {
+	// ID identifies the task, usually the ID of the job it belongs to.
+	ID int
+	// Target is the host the task runs against. Tasks sharing a target are
+	// run one after another.
+	Target string
+	// Run performs the work. It is called on its own goroutine.
+	Run func()
+}
+
+// Stats is a snapshot of the scheduler's load.
+type Stats struct {
+	MaxConcurrent int `json:"max_concurrent"`
+	Running       int `json:"running"`
+	Queued        int `json:"queued"`
+}
+
+// Scheduler queues tasks in submission order and starts them as soon as a
+// slot is free and no other task is running against the same target. A task
+// blocked by its target does not hold back the tasks queued behind it.
+type Scheduler struct {
+	mu      sync.Mutex
+	max     int
+	queue   []Task
+	running map[string]int // target -> ID of the task running against it
+}
+
+// New returns a Scheduler that runs at most maxConcurrent tasks at once.
+func New(maxConcurrent int) *Scheduler {
+	if maxConcurrent < 1 {
+		maxConcurrent = 1
+	}
+	return &Scheduler{
+		max:     maxConcurrent,
+		running: make(map[string]int),
+	}
+}
+
+// Submit queues a task and starts it if capacity allows.
+func (s *Scheduler) Submit(t Task) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.queue = append(s.queue, t)
+	s.dispatch()
+}
+
+// Stats returns the current queue depth and number of running tasks.
+func (s *Scheduler) Stats() Stats {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return Stats{
+		MaxConcurrent: s.max,
+		Running:       len(s.running),
+		Queued:        len(s.queue),
+	}
+}
+
+// Position returns the 1-based position of a queued task, or 0 if the task
+// is not waiting in the queue.
+func (s *Scheduler) Position(id int) int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for i, t := range s.queue {
+		if t.ID == id {
+			return i + 1
+		}
+	}
+	return 0
+}
+
+// Remove drops a task from the queue before it has started. It reports
+// whether the task was found; a task that is already running is not affected.
+func (s *Scheduler) Remove(id int) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for i, t := range s.queue {
+		if t.ID == id {
+			s.queue = append(s.queue[:i], s.queue[i+1:]...)
+			return true
+		}
+	}
+	return false
+}
+
+// dispatch starts queued tasks while there is spare capacity. The caller
+// must hold s.mu.
+func (s *Scheduler) dispatch() {
+	for i := 0; i < len(s.queue) && len(s.running) < s.max; {
+		t := s.queue[i]
+		target := targetKey(t.Target)
+		if _, busy := s.running[target]; busy {
+			i++
+			continue
+		}
+
+		s.queue = append(s.queue[:i], s.queue[i+1:]...)
+		s.running[target] = t.ID
+		go s.run(t, target)
+	}
+}
+
+func (s *Scheduler) run(t Task, target string) {
+	defer func() {
+		s.mu.Lock()
+		defer s.mu.Unlock()
+
+		delete(s.running, target)
+		s.dispatch()
+	}()
+
+	t.Run()
+}
+
+// targetKey normalizes host names so that differently cased spellings of
+// the same host are serialized together.
+func targetKey(target string) string {
+	return strings.ToLower(strings.TrimSpace(target))
+}
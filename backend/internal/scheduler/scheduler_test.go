@@ -0,0 +1,126 @@
+package scheduler
+
+import (
+	"testing"
+	"time"
+)
+
+// blocker is a task body that reports when it starts and runs until it is
+// released.
+type blocker struct {
+	started chan int
+	release map[int]chan struct{}
+}
+
+func newBlocker() *blocker {
+	return &blocker{started: make(chan int, 16), release: make(map[int]chan struct{})}
+}
+
+func (b *blocker) task(id int, target string) Task {
+	release := make(chan struct{})
+	b.release[id] = release
+	return Task{ID: id, Target: target, Run: func() {
+		b.started <- id
+		<-release
+	}}
+}
+
+// expectStarted waits for the tasks with the given IDs to start, in any
+// order, as tasks start on goroutines of their own.
+func (b *blocker) expectStarted(t *testing.T, ids ...int) {
+	t.Helper()
+	want := make(map[int]bool)
+	for _, id := range ids {
+		want[id] = true
+	}
+	for range ids {
+		select {
+		case got := <-b.started:
+			if !want[got] {
+				t.Fatalf("task %d started, want %v", got, ids)
+			}
+			delete(want, got)
+		case <-time.After(time.Second):
+			t.Fatalf("tasks %v didn't start", ids)
+		}
+	}
+}
+
+// expectIdle checks that no other task starts.
+func (b *blocker) expectIdle(t *testing.T) {
+	t.Helper()
+	select {
+	case got := <-b.started:
+		t.Fatalf("task %d started, want none", got)
+	case <-time.After(50 * time.Millisecond):
+	}
+}
+
+func TestSameTargetRunsOneAtATime(t *testing.T) {
+	s := New(4)
+	b := newBlocker()
+
+	s.Submit(b.task(1, "web-1"))
+	b.expectStarted(t, 1)
+	s.Submit(b.task(2, " WEB-1"))
+	s.Submit(b.task(3, "web-2"))
+	// Task 2 waits for its target without holding back task 3
+	b.expectStarted(t, 3)
+	b.expectIdle(t)
+	if got := s.Position(2); got != 1 {
+		t.Errorf("Position(2) = %d, want 1", got)
+	}
+
+	close(b.release[1])
+	b.expectStarted(t, 2)
+	close(b.release[2])
+	close(b.release[3])
+}
+
+func TestMaxConcurrent(t *testing.T) {
+	s := New(2)
+	b := newBlocker()
+
+	for id, target := range []string{"a", "b", "c"} {
+		s.Submit(b.task(id+1, target))
+	}
+	b.expectStarted(t, 1, 2)
+	b.expectIdle(t)
+	if got, want := s.Stats(), (Stats{MaxConcurrent: 2, Running: 2, Queued: 1}); got != want {
+		t.Errorf("Stats() = %+v, want %+v", got, want)
+	}
+
+	close(b.release[2])
+	b.expectStarted(t, 3)
+	close(b.release[1])
+	close(b.release[3])
+}
+
+func TestRemoveQueuedTask(t *testing.T) {
+	s := New(1)
+	b := newBlocker()
+
+	s.Submit(b.task(1, "a"))
+	b.expectStarted(t, 1)
+	s.Submit(b.task(2, "b"))
+	s.Submit(b.task(3, "c"))
+
+	if s.Remove(1) {
+		t.Error("Remove of the running task = true, want false")
+	}
+	if !s.Remove(2) {
+		t.Error("Remove of a queued task = false, want true")
+	}
+	if s.Remove(2) {
+		t.Error("Remove of a removed task = true, want false")
+	}
+	if got := s.Position(3); got != 1 {
+		t.Errorf("Position(3) = %d, want 1", got)
+	}
+
+	close(b.release[1])
+	// The removed task never runs
+	b.expectStarted(t, 3)
+	close(b.release[3])
+	b.expectIdle(t)
+}
@@ -0,0 +1,165 @@
+package sources
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"net/url"
+	"os"
+	"path"
+	"path/filepath"
+	"slices"
+	"strings"
+
+	"github.com/ahasunos/caas/backend/internal/metadata"
+	"github.com/ahasunos/caas/backend/internal/models"
+	"github.com/ahasunos/caas/backend/internal/profilecache"
+)
+
+// GitOptions configures a source of git repositories.
+type GitOptions struct {
+	Name string
+	// Repositories are the URLs of the repositories, which may be local
+	// (file://) or on any host git can clone from.
+	Repositories []string
+	// Username and Token authenticate to HTTPS repositories.
+	Username string
+	Token    string
+}
+
+// Git adds a fixed list of git repositories to the catalog. Their
+// inspec.yml files are read from checkouts of their default branch in the
+// profile cache.
+type Git struct {
+	opts  GitOptions
+	cache *profilecache.Cache
+}
+
+// NewGit returns a source of the repositories in opts, checked out through
+// cache.
+func NewGit(opts GitOptions, cache *profilecache.Cache) *Git {
+	return &Git{opts: opts, cache: cache}
+}
+
+func (g *Git) Name() string { return g.opts.Name }
+func (g *Git) Type() string { return TypeGit }
+func (g *Git) Git() bool    { return true }
+
+// Discover reads the metadata of every repository. Repositories that can't
+// be reached are skipped, and left as they are in the catalog.
+func (g *Git) Discover(ctx context.Context, start int, fn func(next int, profiles []models.Profile) error) (Stats, error) {
+	var stats Stats
+	for _, repoURL := range g.opts.Repositories {
+		stats.Repositories++
+		profile, err := g.profile(ctx, repoURL)
+		if err != nil {
+			var goneErr *GoneError
+			if !errors.As(err, &goneErr) {
+				log.Printf("Could not check git repository %s: %v", repoURL, err)
+			}
+			continue
+		}
+		stats.Profiles++
+		if err := fn(0, []models.Profile{profile}); err != nil {
+			return stats, err
+		}
+	}
+	return stats, nil
+}
+
+// Check looks a profile up again. Repositories that were taken off the
+// source's list are gone.
+func (g *Git) Check(ctx context.Context, profile models.Profile) (models.Profile, error) {
+	if !g.Owns(profile.URL) {
+		return profile, gone("repository no longer configured")
+	}
+	return g.profile(ctx, profile.URL)
+}
+
+// profile reads the metadata of the repository's default branch.
+func (g *Git) profile(ctx context.Context, repoURL string) (models.Profile, error) {
+	sha, err := g.cache.ResolveRef(ctx, repoURL, "")
+	if err != nil {
+		return models.Profile{}, err
+	}
+	path, release, err := g.cache.Get(ctx, repoURL, sha)
+	if err != nil {
+		return models.Profile{}, err
+	}
+	defer release()
+
+	profile, err := readProfile(path, repoName(repoURL))
+	if err != nil {
+		return models.Profile{}, err
+	}
+	profile.URL = repoURL
+	profile.Owner = repoOwner(repoURL)
+	return profile, nil
+}
+
+func (g *Git) Owns(repoURL string) bool {
+	return slices.Contains(g.opts.Repositories, repoURL)
+}
+
+func (g *Git) Credentials(ctx context.Context, repoURL string) (string, string, error) {
+	return g.opts.Username, g.opts.Token, nil
+}
+
+// repoName returns the name of the repository at repoURL.
+func repoName(repoURL string) string {
+	name := strings.TrimSuffix(strings.TrimSuffix(repoURL, "/"), ".git")
+	return name[strings.LastIndexAny(name, "/:")+1:]
+}
+
+// repoOwner returns the path of the user, organization or group owning the
+// repository at repoURL on its host, or an empty string for local
+// repositories.
+func repoOwner(repoURL string) string {
+	u, err := url.Parse(repoURL)
+	if err != nil || u.Scheme == "file" || u.Host == "" {
+		return ""
+	}
+	owner, _ := path.Split(strings.Trim(u.Path, "/"))
+	return strings.TrimSuffix(owner, "/")
+}
+
+// readProfile returns the catalog profile of the profile in dir, named after
+// its metadata or else after name. It returns a *GoneError if dir has no
+// inspec.yml file.
+func readProfile(dir, name string) (models.Profile, error) {
+	if _, err := os.Stat(filepath.Join(dir, metadata.FileName)); errors.Is(err, os.ErrNotExist) {
+		return models.Profile{}, gone("inspec.yml not found")
+	} else if err != nil {
+		return models.Profile{}, err
+	}
+
+	profile := models.Profile{Name: name, Status: models.ProfileActive}
+	meta, err := metadata.ReadDir(dir)
+	if err != nil {
+		log.Printf("Could not read metadata of %s: %v", dir, err)
+		return profile, nil
+	}
+	profile.Metadata = &meta
+	if meta.Name != "" {
+		profile.Name = meta.Name
+	}
+	profile.Description = meta.Summary
+	if profile.Description == "" {
+		profile.Description = meta.Title
+	}
+	return profile, nil
+}
+
+// dirProfile returns the profile in dir, reporting a missing directory as
+// gone.
+func dirProfile(dir string) (models.Profile, error) {
+	if _, err := os.Stat(dir); errors.Is(err, os.ErrNotExist) {
+		return models.Profile{}, gone("directory not found")
+	} else if err != nil {
+		return models.Profile{}, fmt.Errorf("failed to read %s: %v", dir, err)
+	}
+	profile, err := readProfile(dir, filepath.Base(dir))
+	profile.URL = dir
+	return profile, err
+}
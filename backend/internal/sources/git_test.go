@@ -0,0 +1,19 @@
+package sources
+
+import "testing"
+
+func TestRepoOwner(t *testing.T) {
+	tests := map[string]string{
+		"https://github.com/dev-sec/linux-baseline":         "dev-sec",
+		"https://gitlab.example.com/group/sub/baseline.git": "group/sub",
+		"ssh://git@git.example.com/compliance/baseline.git": "compliance",
+		"https://git.example.com/baseline.git":              "",
+		"file:///srv/git/windows-baseline.git":              "",
+		"git@github.com:dev-sec/linux-baseline.git":         "",
+	}
+	for repoURL, want := range tests {
+		if got := repoOwner(repoURL); got != want {
+			t.Errorf("repoOwner(%q) = %q, want %q", repoURL, got, want)
+		}
+	}
+}
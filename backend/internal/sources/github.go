@@ -0,0 +1,93 @@
+package sources
+
+import (
+	"context"
+	"errors"
+	"strings"
+
+	"github.com/ahasunos/caas/backend/internal/github"
+	"github.com/ahasunos/caas/backend/internal/models"
+)
+
+// GitHub discovers profiles on a GitHub or GitHub Enterprise Server host, by
+// the discovery rules of its client.
+type GitHub struct {
+	client *github.Client
+
+	// found holds the repository IDs of the profiles the last discovery
+	// found, and complete whether that discovery went through all
+	// listings from the start.
+	found    map[int64]bool
+	complete bool
+}
+
+// NewGitHub returns a source discovering profiles through client.
+func NewGitHub(client *github.Client) *GitHub {
+	return &GitHub{client: client}
+}
+
+// Client returns the client of the source's host.
+func (g *GitHub) Client() *github.Client {
+	return g.client
+}
+
+func (g *GitHub) Name() string { return g.client.Name() }
+func (g *GitHub) Type() string { return TypeGitHub }
+func (g *GitHub) Git() bool    { return true }
+
+// CheckpointKey describes the page size and listings that discovery
+// positions refer to.
+func (g *GitHub) CheckpointKey() string {
+	return g.client.DiscoveryKey()
+}
+
+// Discover pages through the client's listings starting at position start,
+// and resumes at the page after the last one handed to fn.
+func (g *GitHub) Discover(ctx context.Context, start int, fn func(next int, profiles []models.Profile) error) (Stats, error) {
+	found := make(map[int64]bool)
+	g.found, g.complete = found, false
+
+	stats, err := g.client.FetchProfilesFromGitHub(ctx, start, func(next int, profiles []models.Profile) error {
+		for _, profile := range profiles {
+			found[profile.RepoID] = true
+		}
+		return fn(next, profiles)
+	})
+	g.complete = err == nil && start <= 1
+	return stats, err
+}
+
+// Check looks a profile up by its URL, following renames and transfers of
+// its repository. Profiles matching an exclude pattern are gone, and so are
+// profiles the last discovery didn't find if it listed every repository
+// matching the client's rules.
+func (g *GitHub) Check(ctx context.Context, profile models.Profile) (models.Profile, error) {
+	current, err := g.client.FetchProfileDetailsFromGitHub(ctx, profile.URL)
+	if errors.Is(err, github.ErrRepositoryNotFound) {
+		return profile, gone("repository not found")
+	}
+	if err != nil {
+		return profile, err
+	}
+	if g.client.Excluded(strings.TrimPrefix(current.URL, g.client.WebURL()+"/")) {
+		return profile, gone("excluded by discovery rules")
+	}
+	if g.complete && g.client.Exhaustive() && !g.found[current.RepoID] {
+		return profile, gone("no longer matched by discovery rules")
+	}
+
+	current.Metadata, err = g.client.FetchMetadata(ctx, current.URL)
+	if errors.Is(err, github.ErrNoInSpecYML) {
+		return profile, gone("inspec.yml not found")
+	}
+	return current, err
+}
+
+func (g *GitHub) Owns(url string) bool {
+	return g.client.Owns(url)
+}
+
+func (g *GitHub) Credentials(ctx context.Context, url string) (string, string, error) {
+	token, err := g.client.Token(ctx)
+	return "", token, err
+}
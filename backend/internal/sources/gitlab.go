@@ -0,0 +1,235 @@
+package sources
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+
+	"github.com/ahasunos/caas/backend/internal/metadata"
+	"github.com/ahasunos/caas/backend/internal/models"
+)
+
+// GitLabOptions configures a GitLab source.
+type GitLabOptions struct {
+	Name string
+	// URL is the base URL of the instance, e.g. https://gitlab.com.
+	URL string
+	// Groups, Topics and Search select the projects that are checked for
+	// an inspec.yml file: the projects of the groups and their subgroups,
+	// the projects with any of the topics and the projects matching the
+	// search.
+	Groups []string
+	Topics []string
+	Search string
+	// Token authenticates API requests and checkouts.
+	Token string
+	// HTTPClient sends the API requests; a client with a one minute
+	// timeout is used if it is nil.
+	HTTPClient *http.Client
+}
+
+// GitLab discovers profiles among the projects of a GitLab instance.
+type GitLab struct {
+	opts GitLabOptions
+	http *http.Client
+}
+
+// gitLabProject is a project as returned by the GitLab API.
+type gitLabProject struct {
+	ID            int64  `json:"id"`
+	Name          string `json:"name"`
+	Description   string `json:"description"`
+	WebURL        string `json:"web_url"`
+	StarCount     int    `json:"star_count"`
+	Archived      bool   `json:"archived"`
+	DefaultBranch string `json:"default_branch"`
+	Namespace     struct {
+		FullPath string `json:"full_path"`
+	} `json:"namespace"`
+	// ForkedFromProject is set for forks.
+	ForkedFromProject *struct {
+		WebURL string `json:"web_url"`
+	} `json:"forked_from_project"`
+}
+
+// NewGitLab returns a GitLab source.
+func NewGitLab(opts GitLabOptions) *GitLab {
+	opts.URL = strings.TrimSuffix(opts.URL, "/")
+	g := &GitLab{opts: opts, http: opts.HTTPClient}
+	if g.http == nil {
+		g.http = newHTTPClient()
+	}
+	return g
+}
+
+func (g *GitLab) Name() string { return g.opts.Name }
+func (g *GitLab) Type() string { return TypeGitLab }
+func (g *GitLab) Git() bool    { return true }
+
+// Discover lists the selected projects page by page. Projects selected more
+// than once are checked once.
+func (g *GitLab) Discover(ctx context.Context, start int, fn func(next int, profiles []models.Profile) error) (Stats, error) {
+	var stats Stats
+	seen := make(map[int64]bool)
+
+	var lists []string
+	for _, group := range g.opts.Groups {
+		lists = append(lists, fmt.Sprintf("%s/groups/%s/projects?include_subgroups=true&order_by=id&sort=asc", g.api(), url.PathEscape(group)))
+	}
+	for _, topic := range g.opts.Topics {
+		lists = append(lists, fmt.Sprintf("%s/projects?topic=%s&order_by=id&sort=asc", g.api(), url.QueryEscape(topic)))
+	}
+	if g.opts.Search != "" {
+		lists = append(lists, fmt.Sprintf("%s/projects?search=%s&order_by=id&sort=asc", g.api(), url.QueryEscape(g.opts.Search)))
+	}
+
+	for _, list := range lists {
+		for page := "1"; page != ""; {
+			var projects []gitLabProject
+			header, err := g.getJSON(ctx, fmt.Sprintf("%s&per_page=100&page=%s", list, page), &projects)
+			if err != nil {
+				return stats, err
+			}
+			stats.Pages++
+			page = header.Get("X-Next-Page")
+
+			var profiles []models.Profile
+			for _, project := range projects {
+				if seen[project.ID] {
+					stats.Duplicates++
+					continue
+				}
+				seen[project.ID] = true
+				stats.Repositories++
+
+				profile, err := g.profile(ctx, project)
+				if err != nil {
+					var goneErr *GoneError
+					if !errors.As(err, &goneErr) {
+						log.Printf("Could not check GitLab project %s: %v", project.WebURL, err)
+					}
+					continue
+				}
+				profiles = append(profiles, profile)
+			}
+			stats.Profiles += len(profiles)
+			if err := fn(0, profiles); err != nil {
+				return stats, err
+			}
+		}
+	}
+	return stats, nil
+}
+
+// Check looks a profile up by its project ID, which stays the same when the
+// project is renamed or transferred.
+func (g *GitLab) Check(ctx context.Context, profile models.Profile) (models.Profile, error) {
+	id := strconv.FormatInt(profile.RepoID, 10)
+	if profile.RepoID == 0 {
+		path, ok := strings.CutPrefix(profile.URL, g.opts.URL+"/")
+		if !ok {
+			return profile, fmt.Errorf("%s is not a project of %s", profile.URL, g.opts.URL)
+		}
+		id = url.PathEscape(path)
+	}
+
+	var project gitLabProject
+	_, err := g.getJSON(ctx, fmt.Sprintf("%s/projects/%s", g.api(), id), &project)
+	if errors.Is(err, errNotFound) {
+		return profile, gone("project not found")
+	}
+	if err != nil {
+		return profile, err
+	}
+	return g.profile(ctx, project)
+}
+
+// profile returns the catalog profile of a project, or a *GoneError if the
+// project has no inspec.yml file.
+func (g *GitLab) profile(ctx context.Context, project gitLabProject) (models.Profile, error) {
+	if project.DefaultBranch == "" {
+		return models.Profile{}, gone("repository is empty")
+	}
+
+	fileURL := fmt.Sprintf("%s/projects/%d/repository/files/%s/raw?ref=%s", g.api(), project.ID, metadata.FileName, url.QueryEscape(project.DefaultBranch))
+	status, _, body, err := get(ctx, g.http, fileURL, g.header())
+	if err != nil {
+		return models.Profile{}, fmt.Errorf("failed to fetch inspec.yml: %v", err)
+	}
+	switch status {
+	case http.StatusOK:
+	case http.StatusNotFound:
+		return models.Profile{}, gone("inspec.yml not found")
+	default:
+		return models.Profile{}, fmt.Errorf("unexpected status code %d from GitLab API", status)
+	}
+
+	profile := models.Profile{
+		Name:        project.Name,
+		URL:         project.WebURL,
+		Description: project.Description,
+		Stars:       project.StarCount,
+		RepoID:      project.ID,
+		Owner:       project.Namespace.FullPath,
+		Status:      models.ProfileActive,
+	}
+	if project.Archived {
+		profile.Status = models.ProfileArchived
+	}
+	if project.ForkedFromProject != nil {
+		profile.Fork = true
+		profile.ParentURL = project.ForkedFromProject.WebURL
+	}
+	if meta, err := metadata.Parse(body); err == nil {
+		profile.Metadata = &meta
+	} else {
+		log.Printf("Could not parse inspec.yml of %s: %v", project.WebURL, err)
+	}
+	return profile, nil
+}
+
+func (g *GitLab) Owns(repoURL string) bool {
+	return strings.HasPrefix(repoURL, g.opts.URL+"/")
+}
+
+// Credentials returns the source's token, which GitLab accepts with any
+// username over HTTPS.
+func (g *GitLab) Credentials(ctx context.Context, repoURL string) (string, string, error) {
+	return "oauth2", g.opts.Token, nil
+}
+
+func (g *GitLab) api() string {
+	return g.opts.URL + "/api/v4"
+}
+
+func (g *GitLab) header() map[string]string {
+	if g.opts.Token == "" {
+		return nil
+	}
+	return map[string]string{"PRIVATE-TOKEN": g.opts.Token}
+}
+
+// getJSON decodes the response to a GitLab API request into v. It returns
+// errNotFound for a 404 response.
+func (g *GitLab) getJSON(ctx context.Context, apiURL string, v interface{}) (http.Header, error) {
+	status, header, body, err := get(ctx, g.http, apiURL, g.header())
+	if err != nil {
+		return nil, fmt.Errorf("failed to query GitLab API: %v", err)
+	}
+	if status == http.StatusNotFound {
+		return nil, errNotFound
+	}
+	if status != http.StatusOK {
+		return nil, fmt.Errorf("unexpected status code %d from GitLab API", status)
+	}
+	if err := json.Unmarshal(body, v); err != nil {
+		return nil, fmt.Errorf("failed to decode response from GitLab: %v", err)
+	}
+	return header, nil
+}
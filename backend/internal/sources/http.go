@@ -0,0 +1,45 @@
+package sources
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"time"
+)
+
+// userAgent identifies the service to the APIs sources talk to.
+const userAgent = "InSpecService"
+
+// errNotFound is returned for API requests answered with 404 Not Found.
+var errNotFound = errors.New("not found")
+
+// newHTTPClient returns the client sources send API requests with.
+func newHTTPClient() *http.Client {
+	return &http.Client{Timeout: time.Minute}
+}
+
+// get sends a GET request with the given headers and returns the status,
+// headers and body of the response.
+func get(ctx context.Context, client *http.Client, url string, header map[string]string) (int, http.Header, []byte, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return 0, nil, nil, fmt.Errorf("failed to create request: %v", err)
+	}
+	req.Header.Set("User-Agent", userAgent)
+	for k, v := range header {
+		req.Header.Set(k, v)
+	}
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return 0, nil, nil, err
+	}
+	defer resp.Body.Close()
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return 0, nil, nil, fmt.Errorf("failed to read response: %v", err)
+	}
+	return resp.StatusCode, resp.Header, body, nil
+}
@@ -0,0 +1,76 @@
+package sources
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/ahasunos/caas/backend/internal/metadata"
+	"github.com/ahasunos/caas/backend/internal/models"
+)
+
+// Local adds the profiles in a directory on the API server to the catalog:
+// the directory itself if it is a profile, or else each of its
+// subdirectories that is one. Catalog entries carry the absolute path of
+// the profile, which InSpec runs in place.
+type Local struct {
+	name string
+	dir  string
+}
+
+// NewLocal returns a source of the profiles in dir.
+func NewLocal(name, dir string) *Local {
+	return &Local{name: name, dir: filepath.Clean(dir)}
+}
+
+func (l *Local) Name() string { return l.name }
+func (l *Local) Type() string { return TypeLocal }
+func (l *Local) Git() bool    { return false }
+
+func (l *Local) Discover(ctx context.Context, start int, fn func(next int, profiles []models.Profile) error) (Stats, error) {
+	var stats Stats
+
+	dirs := []string{l.dir}
+	if _, err := os.Stat(filepath.Join(l.dir, metadata.FileName)); errors.Is(err, os.ErrNotExist) {
+		entries, err := os.ReadDir(l.dir)
+		if err != nil {
+			return stats, fmt.Errorf("failed to read profile directory %s: %v", l.dir, err)
+		}
+		dirs = dirs[:0]
+		for _, entry := range entries {
+			if entry.IsDir() && !strings.HasPrefix(entry.Name(), ".") {
+				dirs = append(dirs, filepath.Join(l.dir, entry.Name()))
+			}
+		}
+	}
+
+	var profiles []models.Profile
+	for _, dir := range dirs {
+		stats.Repositories++
+		profile, err := dirProfile(dir)
+		if err != nil {
+			continue
+		}
+		profiles = append(profiles, profile)
+	}
+	stats.Profiles = len(profiles)
+	return stats, fn(0, profiles)
+}
+
+func (l *Local) Check(ctx context.Context, profile models.Profile) (models.Profile, error) {
+	if !l.Owns(profile.URL) {
+		return profile, gone("directory no longer configured")
+	}
+	return dirProfile(profile.URL)
+}
+
+func (l *Local) Owns(path string) bool {
+	return path == l.dir || strings.HasPrefix(path, l.dir+string(filepath.Separator))
+}
+
+func (l *Local) Credentials(ctx context.Context, path string) (string, string, error) {
+	return "", "", nil
+}
@@ -0,0 +1,74 @@
+// Package sources discovers the profiles the catalog is populated from:
+// GitHub and GitLab hosts, lists of git repositories, Chef Supermarkets and
+// local directories.
+package sources
+
+import (
+	"context"
+
+	"github.com/ahasunos/caas/backend/internal/github"
+	"github.com/ahasunos/caas/backend/internal/models"
+)
+
+// Source types.
+const (
+	TypeGitHub      = "github"
+	TypeGitLab      = "gitlab"
+	TypeGit         = "git"
+	TypeSupermarket = "supermarket"
+	TypeLocal       = "local"
+)
+
+// Stats counts what a discovery found. Sources that don't page through
+// search results leave Pages and Duplicates at zero.
+type Stats = github.DiscoveryStats
+
+// ProfileSource is a source of catalog profiles.
+type ProfileSource interface {
+	// Name identifies the source. Catalog profiles are tagged with the name
+	// of the source they were found on.
+	Name() string
+	// Type is one of the source types.
+	Type() string
+	// Discover finds the profiles of the source and hands them to fn in
+	// batches. Sources that can resume an interrupted discovery start at
+	// batch start and pass fn the position of the following batch; others
+	// always start at the beginning and pass 0.
+	Discover(ctx context.Context, start int, fn func(next int, profiles []models.Profile) error) (Stats, error)
+	// Check looks up a catalog profile of the source that discovery didn't
+	// return, and returns its current state. It returns a *GoneError if the
+	// profile no longer exists.
+	Check(ctx context.Context, profile models.Profile) (models.Profile, error)
+	// Owns reports whether the profile at url is on the source. Sources
+	// whose URLs don't tell them apart report false.
+	Owns(url string) bool
+	// Git reports whether the profiles of the source are git repositories,
+	// which are checked out through the profile cache at a recorded commit.
+	// Other profiles are handed to InSpec as they are.
+	Git() bool
+	// Credentials returns the username and token to access the git
+	// repository at url with. See profilecache.Credentials.
+	Credentials(ctx context.Context, url string) (username, token string, err error)
+}
+
+// Resumable is implemented by sources that can resume an interrupted
+// discovery. The positions they pass to Discover's callback are only valid
+// under the discovery settings CheckpointKey describes, so a checkpoint
+// recorded under another key is discarded.
+type Resumable interface {
+	CheckpointKey() string
+}
+
+// GoneError is returned by Check for a profile that no longer exists on
+// its source.
+type GoneError struct {
+	Reason string
+}
+
+func (e *GoneError) Error() string {
+	return "profile gone: " + e.Reason
+}
+
+func gone(reason string) error {
+	return &GoneError{Reason: reason}
+}
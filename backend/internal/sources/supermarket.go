@@ -0,0 +1,103 @@
+package sources
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/ahasunos/caas/backend/internal/models"
+)
+
+// supermarketPageSize is the number of tools requested per page.
+const supermarketPageSize = 100
+
+// Supermarket adds the compliance profiles published on a Chef Supermarket
+// to the catalog. Catalog entries carry supermarket://<owner>/<name> URLs,
+// which InSpec fetches itself.
+type Supermarket struct {
+	name string
+	url  string
+	http *http.Client
+}
+
+// supermarketTools is a page of the Supermarket's tools API.
+type supermarketTools struct {
+	Start int `json:"start"`
+	Total int `json:"total"`
+	Items []struct {
+		Name        string `json:"tool_name"`
+		Owner       string `json:"tool_owner"`
+		Description string `json:"tool_description"`
+	} `json:"items"`
+}
+
+// NewSupermarket returns a source of the compliance profiles of the
+// Supermarket at baseURL.
+func NewSupermarket(name, baseURL string) *Supermarket {
+	return &Supermarket{name: name, url: strings.TrimSuffix(baseURL, "/"), http: newHTTPClient()}
+}
+
+func (s *Supermarket) Name() string { return s.name }
+func (s *Supermarket) Type() string { return TypeSupermarket }
+func (s *Supermarket) Git() bool    { return false }
+
+func (s *Supermarket) Discover(ctx context.Context, start int, fn func(next int, profiles []models.Profile) error) (Stats, error) {
+	var stats Stats
+	for offset := 0; ; offset += supermarketPageSize {
+		toolsURL := fmt.Sprintf("%s/api/v1/tools?type=compliance_profile&start=%d&items=%d", s.url, offset, supermarketPageSize)
+		status, _, body, err := get(ctx, s.http, toolsURL, map[string]string{"Accept": "application/json"})
+		if err != nil {
+			return stats, fmt.Errorf("failed to list Supermarket tools: %v", err)
+		}
+		if status != http.StatusOK {
+			return stats, fmt.Errorf("unexpected status code %d from Supermarket API", status)
+		}
+		var page supermarketTools
+		if err := json.Unmarshal(body, &page); err != nil {
+			return stats, fmt.Errorf("failed to decode response from Supermarket: %v", err)
+		}
+		stats.Pages++
+
+		profiles := make([]models.Profile, 0, len(page.Items))
+		for _, tool := range page.Items {
+			profiles = append(profiles, models.Profile{
+				Name:        tool.Name,
+				URL:         fmt.Sprintf("supermarket://%s/%s", tool.Owner, tool.Name),
+				Description: tool.Description,
+				Owner:       tool.Owner,
+				Status:      models.ProfileActive,
+			})
+		}
+		stats.Repositories += len(profiles)
+		stats.Profiles += len(profiles)
+		if err := fn(0, profiles); err != nil {
+			return stats, err
+		}
+
+		if len(page.Items) == 0 || offset+supermarketPageSize >= page.Total {
+			return stats, nil
+		}
+	}
+}
+
+// Check reports profiles the tools API no longer lists as gone, as
+// discovery lists all of them. Profiles of other sources are left alone.
+func (s *Supermarket) Check(ctx context.Context, profile models.Profile) (models.Profile, error) {
+	if profile.Source != s.name {
+		return profile, fmt.Errorf("%s is not a profile of %s", profile.URL, s.name)
+	}
+	return profile, gone("no longer listed on the Supermarket")
+}
+
+// Owns reports no profile as the Supermarket's: supermarket:// URLs don't
+// name the Supermarket they were listed on, so its profiles are only known
+// by the source recorded with them when they were discovered.
+func (s *Supermarket) Owns(profileURL string) bool {
+	return false
+}
+
+func (s *Supermarket) Credentials(ctx context.Context, profileURL string) (string, string, error) {
+	return "", "", nil
+}
@@ -0,0 +1,28 @@
+package sources
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/ahasunos/caas/backend/internal/models"
+)
+
+func TestSupermarketKeepsToItsProfiles(t *testing.T) {
+	public := NewSupermarket("public", "https://supermarket.chef.io")
+	internal := NewSupermarket("internal", "https://supermarket.example.com")
+
+	const profileURL = "supermarket://dev-sec/ssh-baseline"
+	if public.Owns(profileURL) || internal.Owns(profileURL) {
+		t.Errorf("Owns(%q) = true, want false for both Supermarkets", profileURL)
+	}
+
+	profile := models.Profile{URL: profileURL, Source: "public"}
+	var goneErr *GoneError
+	if _, err := public.Check(context.Background(), profile); !errors.As(err, &goneErr) {
+		t.Errorf("Check of its own profile error = %v, want it gone", err)
+	}
+	if _, err := internal.Check(context.Background(), profile); err == nil || errors.As(err, &goneErr) {
+		t.Errorf("Check of another source's profile error = %v, want it refused", err)
+	}
+}
@@ -0,0 +1,134 @@
+// Package stream keeps the output of running jobs in memory, line by line,
+// so that clients can follow it live and resume after reconnecting.
+package stream
+
+import (
+	"bytes"
+	"sync"
+	"time"
+)
+
+// Log is an append-only log of output lines. It implements io.Writer;
+// incomplete lines are held back until their newline arrives or the log is
+// closed.
+type Log struct {
+	mu      sync.Mutex
+	lines   []string
+	partial []byte
+	closed  bool
+	// changed is closed and replaced whenever lines are added or the log is
+	// closed, waking up every waiting reader.
+	changed chan struct{}
+}
+
+func newLog() *Log {
+	return &Log{changed: make(chan struct{})}
+}
+
+// Write appends output to the log.
+func (l *Log) Write(p []byte) (int, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	l.partial = append(l.partial, p...)
+	added := false
+	for {
+		i := bytes.IndexByte(l.partial, '\n')
+		if i < 0 {
+			break
+		}
+		l.lines = append(l.lines, string(l.partial[:i]))
+		l.partial = l.partial[i+1:]
+		added = true
+	}
+	if added {
+		l.notify()
+	}
+	return len(p), nil
+}
+
+// Since returns the lines after the first n, whether the log is closed, and
+// a channel that is closed once there is something new to read.
+func (l *Log) Since(n int) (lines []string, closed bool, changed <-chan struct{}) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if n < len(l.lines) {
+		lines = append(lines, l.lines[n:]...)
+	}
+	return lines, l.closed, l.changed
+}
+
+func (l *Log) close() {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if l.closed {
+		return
+	}
+	if len(l.partial) > 0 {
+		l.lines = append(l.lines, string(l.partial))
+		l.partial = nil
+	}
+	l.closed = true
+	l.notify()
+}
+
+// notify wakes up waiting readers. The caller must hold l.mu.
+func (l *Log) notify() {
+	close(l.changed)
+	l.changed = make(chan struct{})
+}
+
+// Registry holds the logs of jobs by job ID. Closed logs are kept for a
+// retention period so that clients connecting just after a job finished can
+// still read them from memory.
+type Registry struct {
+	mu        sync.Mutex
+	logs      map[int]*Log
+	retention time.Duration
+}
+
+// NewRegistry returns a Registry that forgets closed logs after retention.
+func NewRegistry(retention time.Duration) *Registry {
+	return &Registry{
+		logs:      make(map[int]*Log),
+		retention: retention,
+	}
+}
+
+// Open creates the log of a job, replacing any previous one.
+func (r *Registry) Open(id int) *Log {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	l := newLog()
+	r.logs[id] = l
+	return l
+}
+
+// Get returns the log of a job, or nil if it is unknown or has expired.
+func (r *Registry) Get(id int) *Log {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	return r.logs[id]
+}
+
+// Close marks the log of a job as complete and schedules its removal.
+func (r *Registry) Close(id int) {
+	l := r.Get(id)
+	if l == nil {
+		return
+	}
+	l.close()
+
+	time.AfterFunc(r.retention, func() {
+		r.mu.Lock()
+		defer r.mu.Unlock()
+
+		if r.logs[id] == l {
+			delete(r.logs, id)
+		}
+	})
+}
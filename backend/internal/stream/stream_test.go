@@ -0,0 +1,106 @@
+package stream
+
+import (
+	"slices"
+	"testing"
+	"time"
+)
+
+func TestLogLines(t *testing.T) {
+	l := newLog()
+	l.Write([]byte("first\nsec"))
+	l.Write([]byte("ond\nthi"))
+
+	lines, closed, _ := l.Since(0)
+	if want := []string{"first", "second"}; !slices.Equal(lines, want) || closed {
+		t.Errorf("Since(0) = %q, %v, want %q and open", lines, closed, want)
+	}
+	// Readers resume after the lines they already have
+	if lines, _, _ := l.Since(1); !slices.Equal(lines, []string{"second"}) {
+		t.Errorf("Since(1) = %q, want the second line", lines)
+	}
+	if lines, _, _ := l.Since(5); lines != nil {
+		t.Errorf("Since(5) = %q, want none", lines)
+	}
+
+	// Closing flushes the incomplete line
+	l.close()
+	lines, closed, _ = l.Since(2)
+	if !slices.Equal(lines, []string{"thi"}) || !closed {
+		t.Errorf("Since(2) after close = %q, %v, want the partial line and closed", lines, closed)
+	}
+}
+
+func TestLogWakesReaders(t *testing.T) {
+	l := newLog()
+	_, _, changed := l.Since(0)
+
+	l.Write([]byte("no newline yet"))
+	select {
+	case <-changed:
+		t.Fatal("readers woken by an incomplete line")
+	default:
+	}
+
+	l.Write([]byte("\n"))
+	select {
+	case <-changed:
+	case <-time.After(time.Second):
+		t.Fatal("readers not woken by a new line")
+	}
+
+	_, _, changed = l.Since(1)
+	l.close()
+	select {
+	case <-changed:
+	case <-time.After(time.Second):
+		t.Fatal("readers not woken by close")
+	}
+}
+
+func TestRegistry(t *testing.T) {
+	r := NewRegistry(20 * time.Millisecond)
+	if r.Get(1) != nil {
+		t.Fatal("Get of an unknown job returned a log")
+	}
+
+	l := r.Open(1)
+	l.Write([]byte("line\n"))
+	if r.Get(1) != l {
+		t.Fatal("Get didn't return the open log")
+	}
+
+	// Closed logs can still be replayed for the retention period
+	r.Close(1)
+	if got := r.Get(1); got != l {
+		t.Fatal("closed log forgotten before the retention period")
+	}
+	if lines, closed, _ := l.Since(0); !slices.Equal(lines, []string{"line"}) || !closed {
+		t.Errorf("Since(0) = %q, %v, want the line and closed", lines, closed)
+	}
+	deadline := time.Now().Add(time.Second)
+	for r.Get(1) != nil {
+		if time.Now().After(deadline) {
+			t.Fatal("closed log kept after the retention period")
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+
+	r.Close(2) // unknown logs are ignored
+}
+
+func TestRegistryReopen(t *testing.T) {
+	r := NewRegistry(10 * time.Millisecond)
+	old := r.Open(1)
+	r.Close(1)
+	// A job run again gets a fresh log, which the expiry of the old one
+	// must not remove
+	fresh := r.Open(1)
+	if fresh == old {
+		t.Fatal("Open returned the closed log")
+	}
+	time.Sleep(50 * time.Millisecond)
+	if r.Get(1) != fresh {
+		t.Error("reopened log removed when the old one expired")
+	}
+}
@@ -0,0 +1,78 @@
+// Package workspace provides private, per-run working directories for
+// InSpec executions so that credentials of concurrent runs never share a
+// path.
+package workspace
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+)
+
+// prefix is the name prefix of every workspace directory, used to find
+// directories left behind by a crashed process.
+const prefix = "caas-run-"
+
+// Workspace is a randomly named directory only readable by the current user.
+type Workspace struct {
+	Dir string
+}
+
+// New creates a new workspace in the system temporary directory. The caller
+// must call Close once the run is over, typically with defer.
+func New() (*Workspace, error) {
+	dir, err := os.MkdirTemp("", prefix+"*")
+	if err != nil {
+		return nil, fmt.Errorf("failed to create workspace: %v", err)
+	}
+	// MkdirTemp already uses 0700, but be explicit in case of an unusual umask
+	if err := os.Chmod(dir, 0700); err != nil {
+		os.RemoveAll(dir)
+		return nil, fmt.Errorf("failed to restrict workspace permissions: %v", err)
+	}
+	return &Workspace{Dir: dir}, nil
+}
+
+// WriteKey stores a private key in a randomly named 0600 file inside the
+// workspace and returns its path.
+func (w *Workspace) WriteKey(key []byte) (string, error) {
+	f, err := os.CreateTemp(w.Dir, "key-*.pem")
+	if err != nil {
+		return "", fmt.Errorf("failed to create key file: %v", err)
+	}
+	defer f.Close()
+
+	if err := f.Chmod(0600); err != nil {
+		return "", fmt.Errorf("failed to restrict key file permissions: %v", err)
+	}
+	if _, err := f.Write(key); err != nil {
+		return "", fmt.Errorf("failed to write key file: %v", err)
+	}
+	return f.Name(), f.Close()
+}
+
+// Path returns the path of name inside the workspace.
+func (w *Workspace) Path(name string) string {
+	return filepath.Join(w.Dir, name)
+}
+
+// Close removes the workspace and everything in it.
+func (w *Workspace) Close() error {
+	return os.RemoveAll(w.Dir)
+}
+
+// RemoveStale deletes workspaces left in the temporary directory by a
+// previous process that did not exit cleanly. It must only be called before
+// any run of the current process has started.
+func RemoveStale() (int, error) {
+	dirs, err := filepath.Glob(filepath.Join(os.TempDir(), prefix+"*"))
+	if err != nil {
+		return 0, err
+	}
+	for _, dir := range dirs {
+		if err := os.RemoveAll(dir); err != nil {
+			return 0, fmt.Errorf("failed to remove stale workspace %s: %v", dir, err)
+		}
+	}
+	return len(dirs), nil
+}
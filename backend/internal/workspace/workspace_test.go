@@ -0,0 +1,100 @@
+package workspace
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestWorkspace(t *testing.T) {
+	t.Setenv("TMPDIR", t.TempDir())
+
+	w, err := New()
+	if err != nil {
+		t.Fatal(err)
+	}
+	info, err := os.Stat(w.Dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if perm := info.Mode().Perm(); perm != 0700 {
+		t.Errorf("workspace mode = %v, want 0700", perm)
+	}
+
+	path, err := w.WriteKey([]byte("secret"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if filepath.Dir(path) != w.Dir {
+		t.Errorf("key written to %s, want inside %s", path, w.Dir)
+	}
+	info, err = os.Stat(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if perm := info.Mode().Perm(); perm != 0600 {
+		t.Errorf("key mode = %v, want 0600", perm)
+	}
+	if data, _ := os.ReadFile(path); string(data) != "secret" {
+		t.Errorf("key = %q, want %q", data, "secret")
+	}
+
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := os.Stat(w.Dir); !os.IsNotExist(err) {
+		t.Errorf("workspace still exists after Close: %v", err)
+	}
+}
+
+func TestWorkspacesDontShareKeys(t *testing.T) {
+	t.Setenv("TMPDIR", t.TempDir())
+
+	a, err := New()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer a.Close()
+	b, err := New()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer b.Close()
+
+	keyA, _ := a.WriteKey([]byte("a"))
+	keyB, _ := b.WriteKey([]byte("b"))
+	if a.Dir == b.Dir || keyA == keyB {
+		t.Errorf("workspaces share %s and %s", a.Dir, keyA)
+	}
+}
+
+func TestRemoveStale(t *testing.T) {
+	tmp := t.TempDir()
+	t.Setenv("TMPDIR", tmp)
+
+	for range 2 {
+		w, err := New()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err := w.WriteKey([]byte("secret")); err != nil {
+			t.Fatal(err)
+		}
+	}
+	other := filepath.Join(tmp, "other")
+	if err := os.Mkdir(other, 0700); err != nil {
+		t.Fatal(err)
+	}
+
+	n, err := RemoveStale()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n != 2 {
+		t.Errorf("RemoveStale removed %d workspaces, want 2", n)
+	}
+	left, _ := filepath.Glob(filepath.Join(tmp, "*"))
+	if len(left) != 1 || left[0] != other {
+		t.Errorf("left %v, want only %s", left, other)
+	}
+}
@@ -1,26 +1,109 @@
 package main
 
 import (
+	"context"
 	"fmt"
 	"log"
 
 	"github.com/ahasunos/caas/backend/internal/api"
+	"github.com/ahasunos/caas/backend/internal/catalog"
+	"github.com/ahasunos/caas/backend/internal/config"
+	"github.com/ahasunos/caas/backend/internal/cron"
 	"github.com/ahasunos/caas/backend/internal/db"
+	"github.com/ahasunos/caas/backend/internal/executor"
+	"github.com/ahasunos/caas/backend/internal/github"
+	"github.com/ahasunos/caas/backend/internal/profilecache"
+	"github.com/ahasunos/caas/backend/internal/scheduler"
+	"github.com/ahasunos/caas/backend/internal/sources"
+	"github.com/ahasunos/caas/backend/internal/workspace"
 
 	_ "github.com/ahasunos/caas/backend/docs" // Import docs
 	swaggerFiles "github.com/swaggo/files"
 	ginSwagger "github.com/swaggo/gin-swagger"
 )
 
+// @title InSpec Cloud API
+// @version 1.0
+// @description This is an API for InSpec Cloud.
+// @host localhost:8080
+// @BasePath /
+// @securityDefinitions.apikey AdminToken
+// @in header
+// @name Authorization
+// @description Admin API token set by ADMIN_TOKEN, as "Bearer <token>".
 func main() {
+	cfg := config.Load()
+	if cfg.RequireApprovedProfiles && cfg.AdminToken == "" {
+		log.Fatal("REQUIRE_APPROVED_PROFILES is set but ADMIN_TOKEN is not, so no profile could be approved for execution; set ADMIN_TOKEN or REQUIRE_APPROVED_PROFILES=false")
+	}
+
 	// Initialize database
 	err := db.InitDB()
 	if err != nil {
 		log.Fatalf("Failed to connect to the database: %v", err)
 	}
 
+	// Jobs that were in flight when the server last stopped can never finish
+	if n, err := db.FailInterruptedJobs(cfg.InstanceID); err != nil {
+		log.Printf("Failed to clean up interrupted jobs: %v", err)
+	} else if n > 0 {
+		log.Printf("Marked %d interrupted jobs as failed", n)
+	}
+	if n, err := db.FailInterruptedSyncRuns(); err != nil {
+		log.Printf("Failed to clean up interrupted catalog syncs: %v", err)
+	} else if n > 0 {
+		log.Printf("Marked %d interrupted catalog syncs as failed", n)
+	}
+
+	// Credentials of runs killed with the previous process must not linger
+	if n, err := workspace.RemoveStale(); err != nil {
+		log.Printf("Failed to remove stale workspaces: %v", err)
+	} else if n > 0 {
+		log.Printf("Removed %d stale workspaces", n)
+	}
+
+	cache, err := profilecache.New(cfg.ProfileCacheDir, cfg.ProfileCacheMaxBytes)
+	if err != nil {
+		log.Fatalf("Failed to open the profile cache: %v", err)
+	}
+
+	srcs, schedules := profileSources(cfg, cache)
+	profileCatalog := catalog.NewSyncer(cache, srcs)
+	// Private profiles are checked out with the credentials of their source
+	cache.SetCredentials(profileCatalog.Credentials)
+
+	// Sources with a schedule of their own are synced on it, all others on
+	// SYNC_SCHEDULE
+	var unscheduled []string
+	for _, src := range srcs {
+		expr, ok := schedules[src.Name()]
+		if !ok {
+			unscheduled = append(unscheduled, src.Name())
+			continue
+		}
+		schedule, err := cron.Parse(expr)
+		if err != nil {
+			log.Fatalf("Invalid schedule of source %s: %v", src.Name(), err)
+		}
+		go profileCatalog.Schedule(context.Background(), schedule, cfg.SyncJitter, src.Name())
+	}
+	if cfg.SyncSchedule != "" && len(unscheduled) > 0 {
+		schedule, err := cron.Parse(cfg.SyncSchedule)
+		if err != nil {
+			log.Fatalf("Invalid SYNC_SCHEDULE: %v", err)
+		}
+		if len(unscheduled) == len(srcs) {
+			unscheduled = nil
+		}
+		go profileCatalog.Schedule(context.Background(), schedule, cfg.SyncJitter, unscheduled...)
+	}
+
 	// Setup router
-	r := api.SetupRouter()
+	r := api.SetupRouter(cfg, api.Services{
+		Scheduler: scheduler.New(cfg.MaxConcurrentScans),
+		Executor:  executor.NewCLI(cfg.Engines, cfg.ChefLicenseKey),
+		Catalog:   profileCatalog,
+	})
 
 	// Serve static files for Swagger JSON
 	r.Static("/docs", "./docs")
@@ -36,3 +119,67 @@ func main() {
 	// Run the server
 	r.Run(":8080")
 }
+
+// profileSources sets up the catalog sources configured in cfg. It also
+// returns the schedules of the sources that have their own, by name.
+func profileSources(cfg config.Config, cache *profilecache.Cache) ([]sources.ProfileSource, map[string]string) {
+	var srcs []sources.ProfileSource
+	schedules := make(map[string]string)
+	add := func(src sources.ProfileSource, schedule string) {
+		srcs = append(srcs, src)
+		if schedule != "" {
+			schedules[src.Name()] = schedule
+		}
+	}
+
+	for _, src := range cfg.Sources.GitHub {
+		gh, err := github.NewClient(github.Options{
+			Name:              src.Name,
+			APIURL:            src.APIURL,
+			WebURL:            src.WebURL,
+			SearchQuery:       src.SearchQuery,
+			Orgs:              src.Orgs,
+			Users:             src.Users,
+			Topics:            src.Topics,
+			Repos:             src.Repos,
+			Exclude:           src.Exclude,
+			Token:             src.Token,
+			AppID:             src.AppID,
+			AppInstallationID: src.AppInstallationID,
+			AppPrivateKey:     src.AppPrivateKey,
+			PageSize:          cfg.GitHubPageSize,
+			Workers:           cfg.GitHubWorkers,
+			MaxRetries:        cfg.GitHubMaxRetries,
+			MaxWait:           cfg.GitHubMaxWait,
+		})
+		if err != nil {
+			log.Fatalf("Failed to set up the GitHub client for %s: %v", src.Name, err)
+		}
+		add(sources.NewGitHub(gh), src.Schedule)
+	}
+	for _, src := range cfg.Sources.GitLab {
+		add(sources.NewGitLab(sources.GitLabOptions{
+			Name:   src.Name,
+			URL:    src.URL,
+			Groups: src.Groups,
+			Topics: src.Topics,
+			Search: src.Search,
+			Token:  src.Token,
+		}), src.Schedule)
+	}
+	for _, src := range cfg.Sources.Git {
+		add(sources.NewGit(sources.GitOptions{
+			Name:         src.Name,
+			Repositories: src.Repositories,
+			Username:     src.Username,
+			Token:        src.Token,
+		}, cache), src.Schedule)
+	}
+	for _, src := range cfg.Sources.Supermarket {
+		add(sources.NewSupermarket(src.Name, src.URL), src.Schedule)
+	}
+	for _, src := range cfg.Sources.Local {
+		add(sources.NewLocal(src.Name, src.Path), src.Schedule)
+	}
+	return srcs, schedules
+}